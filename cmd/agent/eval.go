@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/vhbfernandes/fitbit-agent/pkg/config"
+	"github.com/vhbfernandes/fitbit-agent/pkg/eval"
+	"github.com/vhbfernandes/fitbit-agent/pkg/llm"
+	"github.com/vhbfernandes/fitbit-agent/pkg/registry"
+)
+
+var (
+	evalCorpus    string
+	evalProviders []string
+	evalPrompts   []string
+	evalMaxSteps  int
+	evalJSON      bool
+)
+
+var evalCmd = &cobra.Command{
+	Use:   "eval",
+	Short: "Evaluate prompts and providers against a corpus of utterances",
+	Long: `Runs every utterance in a corpus through each provider and system prompt variant
+with a dry-run tool registry, then scores whether the expected tool was called with
+the expected meal type, foods and calories. Nothing is logged to Fitbit.`,
+	Run: runEval,
+}
+
+func init() {
+	evalCmd.Flags().StringVar(&evalCorpus, "corpus", "eval/corpus.json", "path to the evaluation corpus")
+	evalCmd.Flags().StringSliceVar(&evalProviders, "providers", nil, "providers to compare (default is the configured provider)")
+	evalCmd.Flags().StringSliceVar(&evalPrompts, "prompts", nil, "system prompt files to compare (default is the configured prompt)")
+	evalCmd.Flags().IntVar(&evalMaxSteps, "max-steps", 3, "LLM calls allowed per case to reach the expected tool")
+	evalCmd.Flags().BoolVar(&evalJSON, "json", false, "print the full reports as JSON")
+
+	rootCmd.AddCommand(evalCmd)
+}
+
+func runEval(cmd *cobra.Command, args []string) {
+	corpus, err := eval.LoadCorpus(evalCorpus)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading corpus: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg := config.LoadConfig()
+	providers := evalProviders
+	if len(providers) == 0 {
+		providers = []string{cfg.LLMProvider}
+		if llmProvider != "" {
+			providers = []string{llmProvider}
+		}
+	}
+
+	prompts := []*config.SystemPrompt{cfg.SystemPrompt}
+	if len(evalPrompts) > 0 {
+		prompts = nil
+		for _, path := range evalPrompts {
+			prompt, err := config.LoadSystemPromptFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading system prompt %s: %v\n", path, err)
+				os.Exit(1)
+			}
+			prompts = append(prompts, prompt)
+		}
+	}
+
+	toolRegistry, err := registry.NewToolRegistry(cfg, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating tool registry: %v\n", err)
+		os.Exit(1)
+	}
+	dryRun := eval.NewDryRunRegistry(toolRegistry)
+
+	var reports []*eval.Report
+	for _, providerName := range providers {
+		for _, prompt := range prompts {
+			label := fmt.Sprintf("%s/%s", providerName, filepath.Base(prompt.GetPath()))
+
+			providerCfg := *cfg
+			providerCfg.LLMProvider = providerName
+			providerCfg.LLMFallbacks = nil
+			providerCfg.SystemPrompt = prompt
+
+			provider, err := llm.NewProviderFactory(&providerCfg, dryRun).CreateProvider()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️  Skipping %s: %v\n", label, err)
+				continue
+			}
+
+			fmt.Fprintf(os.Stderr, "🧪 Running %d cases against %s...\n", len(corpus.Cases), label)
+			reports = append(reports, eval.NewRunner(provider, dryRun, evalMaxSteps).Run(context.Background(), corpus, label))
+		}
+	}
+
+	if len(reports) == 0 {
+		fmt.Fprintln(os.Stderr, "❌ No provider could be evaluated")
+		os.Exit(1)
+	}
+
+	if evalJSON {
+		data, _ := json.MarshalIndent(reports, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Println()
+	eval.PrintComparison(os.Stdout, reports)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+	"github.com/vhbfernandes/fitbit-agent/pkg/input"
+	"github.com/vhbfernandes/fitbit-agent/pkg/registry"
+)
+
+// Exit codes of the log command
+const (
+	exitLogged        = 0 // the meal was logged
+	exitError         = 1 // the agent could not run, or the LLM failed
+	exitFailed        = 2 // a tool call failed or the agent gave up
+	exitNotApproved   = 3 // a change needed confirmation and was not approved
+	exitNothingLogged = 4 // the agent answered without changing anything, e.g. with a question
+	exitPartial       = 5 // something was logged but another tool call failed or was declined, or the agent gave up
+)
+
+// exitCodeError ends the program with code once the command has returned and
+// its deferred cleanup has run
+type exitCodeError struct {
+	code int
+}
+
+// Error implements the error interface
+func (e *exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+var (
+	logYes  bool
+	logJSON bool
+)
+
+var logCmd = &cobra.Command{
+	Use:   "log [description]",
+	Short: "Log a meal in one step without starting the chat",
+	Long: `Sends a single description to the agent, works on it until the agent is done and exits.
+The description is read from stdin when it is not given as arguments.
+
+Changes are confirmed on the terminal unless --yes is given. When there is no
+terminal to ask on, they are not approved.
+
+Exit codes:
+  0  the meal was logged
+  1  the agent could not start or the LLM failed
+  2  a tool call failed or the agent gave up
+  3  a change was not approved
+  4  nothing was logged, e.g. the agent asked a question instead
+  5  part of the meal was logged, but another tool call failed or was declined`,
+	RunE:          runLog,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+func init() {
+	logCmd.Flags().BoolVarP(&logYes, "yes", "y", false, "log without asking for confirmation")
+	logCmd.Flags().BoolVar(&logJSON, "json", false, "print the result as JSON")
+
+	rootCmd.AddCommand(logCmd)
+}
+
+// logResult is the JSON printed by log --json
+type logResult struct {
+	Status string `json:"status"`
+	*agent.TurnResult
+	Error string `json:"error,omitempty"`
+}
+
+func runLog(cmd *cobra.Command, args []string) error {
+	if llmProvider != "" {
+		os.Setenv("LLM_PROVIDER", llmProvider)
+	}
+	if systemPrompt != "" {
+		os.Setenv("SYSTEM_PROMPT_FILE", systemPrompt)
+	}
+
+	// Keep stdout for the JSON result, everything else goes to stderr
+	var output io.Writer = os.Stdout
+	if logJSON {
+		output = os.Stderr
+	}
+	finish := func(code int, status string, result *agent.TurnResult, err error) error {
+		if logJSON {
+			out := logResult{Status: status, TurnResult: result}
+			if err != nil {
+				out.Error = err.Error()
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			encoder.Encode(out)
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		}
+		if code != exitLogged {
+			return &exitCodeError{code: code}
+		}
+		return nil
+	}
+
+	description, fromStdin, err := readDescription(args)
+	if err != nil {
+		return finish(exitError, "error", nil, err)
+	}
+
+	// Confirm on the terminal when there is one that is not carrying the description
+	var confirmations agent.UserInputProvider = input.NewLinesInputProvider(nil)
+	if !fromStdin && isTerminal(os.Stdin) {
+		confirmations = input.NewConsoleInputProvider()
+	}
+
+	container, err := registry.NewContainer(llmProvider, systemPrompt, registry.ContainerOptions{
+		InputProvider: confirmations,
+		AutoApprove:   logYes,
+		Output:        output,
+	})
+	if err != nil {
+		return finish(exitError, "error", nil, err)
+	}
+	defer container.Close()
+
+	fitbitAgent := container.GetAgent()
+	if fitbitAgent == nil {
+		_, err := container.TryGetLLMProvider()
+		return finish(exitError, "error", nil, fmt.Errorf("cannot start agent: %w", err))
+	}
+
+	ctx, stop := handleSignals(fitbitAgent)
+	defer stop()
+
+	result, err := fitbitAgent.RunTurn(ctx, description)
+	if err != nil {
+		return finish(exitError, "error", result, err)
+	}
+
+	code, status := logStatus(result)
+	if code == exitNotApproved && !logJSON {
+		fmt.Fprintln(os.Stderr, "💡 Run with --yes to log without confirming")
+	}
+	return finish(code, status, result, nil)
+}
+
+// readDescription returns the meal description from the arguments or stdin
+func readDescription(args []string) (string, bool, error) {
+	if len(args) > 0 {
+		return strings.Join(args, " "), false, nil
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", true, fmt.Errorf("failed to read stdin: %w", err)
+	}
+	description := strings.TrimSpace(string(data))
+	if description == "" {
+		return "", true, fmt.Errorf("describe the meal as arguments or on stdin")
+	}
+	return description, true, nil
+}
+
+// logStatus maps the outcome of the turn to an exit code and status. A failed
+// call the agent then repeated successfully, e.g. with fixed arguments, does
+// not count as a failure.
+func logStatus(result *agent.TurnResult) (int, string) {
+	failed, declined := false, false
+	for i, toolResult := range result.ToolResults {
+		declined = declined || toolResult.Declined
+		failed = failed || toolResult.IsError && !succeededLater(result.ToolResults[i+1:], toolResult.Name)
+	}
+
+	switch {
+	case len(result.Actions) > 0 && (failed || declined || result.Stopped != ""):
+		return exitPartial, "partial"
+	case declined:
+		return exitNotApproved, "not_approved"
+	case result.Stopped != "":
+		return exitFailed, "failed"
+	case len(result.Actions) > 0:
+		return exitLogged, "logged"
+	case failed:
+		return exitFailed, "failed"
+	}
+	return exitNothingLogged, "nothing_logged"
+}
+
+// succeededLater reports whether a call to the named tool succeeded among results
+func succeededLater(results []agent.ToolResult, name string) bool {
+	for _, result := range results {
+		if result.Name == name && !result.IsError && !result.Declined {
+			return true
+		}
+	}
+	return false
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+func TestLogStatus(t *testing.T) {
+	logged := []agent.Action{{Tool: "fitbit_log_meal"}}
+
+	testCases := []struct {
+		name     string
+		result   agent.TurnResult
+		wantCode int
+	}{
+		{
+			name:     "Meal logged",
+			result:   agent.TurnResult{ToolResults: []agent.ToolResult{{Name: "fitbit_log_meal"}}, Actions: logged},
+			wantCode: exitLogged,
+		},
+		{
+			name: "Failed call fixed by the agent",
+			result: agent.TurnResult{ToolResults: []agent.ToolResult{
+				{Name: "fitbit_log_meal", IsError: true},
+				{Name: "fitbit_log_meal"},
+			}, Actions: logged},
+			wantCode: exitLogged,
+		},
+		{
+			name: "Another call failed",
+			result: agent.TurnResult{ToolResults: []agent.ToolResult{
+				{Name: "fitbit_log_meal"},
+				{Name: "save_meal_locally", IsError: true},
+			}, Actions: logged},
+			wantCode: exitPartial,
+		},
+		{
+			name:     "Agent gave up after logging",
+			result:   agent.TurnResult{ToolResults: []agent.ToolResult{{Name: "fitbit_log_meal"}}, Actions: logged, Stopped: "too many steps"},
+			wantCode: exitPartial,
+		},
+		{
+			name:     "Call failed",
+			result:   agent.TurnResult{ToolResults: []agent.ToolResult{{Name: "fitbit_log_meal", IsError: true}}},
+			wantCode: exitFailed,
+		},
+		{
+			name:     "Change declined",
+			result:   agent.TurnResult{ToolResults: []agent.ToolResult{{Name: "fitbit_log_meal", Declined: true}}},
+			wantCode: exitNotApproved,
+		},
+		{
+			name: "Change declined after another was logged",
+			result: agent.TurnResult{ToolResults: []agent.ToolResult{
+				{Name: "fitbit_log_meal"},
+				{Name: "fitbit_log_meal", Declined: true},
+			}, Actions: logged},
+			wantCode: exitPartial,
+		},
+		{
+			name:     "Question instead of a meal",
+			result:   agent.TurnResult{Reply: "What did you eat?"},
+			wantCode: exitNothingLogged,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if code, status := logStatus(&tc.result); code != tc.wantCode {
+				t.Errorf("Expected exit code %d but got %d (%s)", tc.wantCode, code, status)
+			}
+		})
+	}
+}
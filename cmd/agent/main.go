@@ -1,15 +1,20 @@
 package main
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
-	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+	"github.com/vhbfernandes/fitbit-agent/pkg/cassette"
 	"github.com/vhbfernandes/fitbit-agent/pkg/config"
+	"github.com/vhbfernandes/fitbit-agent/pkg/input"
+	"github.com/vhbfernandes/fitbit-agent/pkg/llm"
 	"github.com/vhbfernandes/fitbit-agent/pkg/registry"
+	"github.com/vhbfernandes/fitbit-agent/pkg/session"
 )
 
 var (
@@ -17,6 +22,10 @@ var (
 	configFile   string
 	verbose      bool
 	systemPrompt string
+	recordDir    string
+	replayDir    string
+	resume       bool
+	sessionID    string
 )
 
 var rootCmd = &cobra.Command{
@@ -54,10 +63,14 @@ var createSystemPromptCmd = &cobra.Command{
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVarP(&llmProvider, "provider", "p", "", "LLM provider (deepseek, gemini)")
+	rootCmd.PersistentFlags().StringVarP(&llmProvider, "provider", "p", "", "LLM provider (deepseek, gemini, scripted)")
 	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "config file (default is $HOME/.fitbit-agent.yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().StringVarP(&systemPrompt, "system-prompt", "s", "", "path to system prompt file")
+	rootCmd.PersistentFlags().StringVar(&recordDir, "record", "", "record LLM and Fitbit HTTP traffic and user input to a cassette directory")
+	rootCmd.Flags().BoolVar(&resume, "resume", false, "resume the most recent conversation")
+	rootCmd.Flags().StringVar(&sessionID, "session", "", "resume the conversation with this session ID")
+	rootCmd.PersistentFlags().StringVar(&replayDir, "replay", "", "replay LLM and Fitbit HTTP traffic and user input from a cassette directory")
 
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(demoCmd)
@@ -99,13 +112,38 @@ func runAgent(cmd *cobra.Command, args []string) {
 		os.Setenv("SYSTEM_PROMPT_FILE", systemPrompt)
 	}
 
+	// Record or replay HTTP traffic if requested
+	inputProvider, transport, replayer, err := setupCassette()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting up cassette: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Start a new conversation or pick up an earlier one
+	session, err := openSession()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening session: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Create dependency injection container
-	container, err := registry.NewContainer(llmProvider, systemPrompt)
+	container, err := registry.NewContainer(llmProvider, systemPrompt, registry.ContainerOptions{
+		InputProvider: inputProvider,
+		Store:         session,
+		Transport:     transport,
+	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating container: %v\n", err)
 		os.Exit(1)
 	}
 
+	// Stop the MCP servers the container started, also when exiting early
+	defer container.Close()
+	exit := func(code int) {
+		container.Close()
+		os.Exit(code)
+	}
+
 	// Get the configured agent
 	agent := container.GetAgent()
 	if agent == nil {
@@ -127,11 +165,11 @@ func runAgent(cmd *cobra.Command, args []string) {
 				fmt.Println("    2. Get API key from: https://makersuite.google.com/app/apikey")
 			}
 
-			os.Exit(1)
+			exit(1)
 		}
 
 		fmt.Fprintf(os.Stderr, "❌ Agent initialization failed for unknown reason\n")
-		os.Exit(1)
+		exit(1)
 	}
 
 	if verbose {
@@ -147,23 +185,90 @@ func runAgent(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	// Ctrl-C cancels the request in progress; when idle it stops the agent
+	ctx, stop := handleSignals(agent)
+	defer stop()
+
 	// Run the agent
-	if err := agent.Run(context.Background()); err != nil {
+	if err := agent.Run(ctx); err != nil {
 		// Check if this is a recoverable error (API issues, etc.)
 		if isRecoverableAgentError(err) {
 			fmt.Fprintf(os.Stderr, "❌ Agent stopped due to recoverable error: %v\n", err)
 			fmt.Println("\n💡 The agent stopped gracefully. You can restart it when the issue is resolved.")
-			os.Exit(0) // Exit gracefully, not as a crash
+			exit(0) // Exit gracefully, not as a crash
 		}
 
 		// For non-recoverable errors, log and exit with error code
 		fmt.Fprintf(os.Stderr, "❌ Agent encountered a fatal error: %v\n", err)
-		os.Exit(1)
+		exit(1)
+	}
+
+	if replayer != nil && replayer.Remaining() > 0 {
+		fmt.Printf("⚠️  Replay finished with %d recorded interactions unused - the session diverged from the recording\n", replayer.Remaining())
 	}
 
+	if len(session.Messages) > 0 {
+		fmt.Printf("💾 Conversation saved, resume it with: fitbit-agent --session %s\n", session.ID)
+	}
 	fmt.Println("Goodbye! Keep up the healthy eating! 🥗")
 }
 
+// openSession returns the session requested by --resume or --session, or a new one
+func openSession() (*session.Session, error) {
+	store := session.NewStore(session.DefaultDir())
+
+	switch {
+	case sessionID != "":
+		return store.Get(sessionID)
+	case resume:
+		latest, err := store.Latest()
+		if errors.Is(err, session.ErrNotFound) {
+			fmt.Println("📂 No earlier conversation to resume, starting a new one")
+			break
+		}
+		return latest, err
+	}
+
+	return store.Create(config.LoadConfig().LLMProvider), nil
+}
+
+// setupCassette creates the record or replay transport requested by flags for
+// the LLM and Fitbit clients, and returns the input provider to use instead of
+// the console, if any
+func setupCassette() (agent.UserInputProvider, http.RoundTripper, *cassette.Replayer, error) {
+	switch {
+	case recordDir != "" && replayDir != "":
+		return nil, nil, nil, fmt.Errorf("--record and --replay cannot be used together")
+
+	case recordDir != "":
+		recorder, err := cassette.NewRecorder(recordDir, http.DefaultTransport)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		fmt.Printf("🎙️  Recording session to %s\n", recordDir)
+		return cassette.NewInputRecorder(recordDir, input.NewConsoleInputProvider()), recorder, nil, nil
+
+	case replayDir != "":
+		replayer, err := cassette.NewReplayer(replayDir)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		fmt.Printf("▶️  Replaying session from %s\n", replayDir)
+
+		lines, err := cassette.LoadInput(replayDir)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if len(lines) == 0 {
+			// No recorded input, let the user type instead
+			return nil, replayer, replayer, nil
+		}
+		return input.NewLinesInputProvider(lines), replayer, replayer, nil
+	}
+
+	return nil, nil, nil, nil
+}
+
 func runDemo(cmd *cobra.Command, args []string) {
 	// Override config with CLI flags
 	if llmProvider != "" {
@@ -178,11 +283,12 @@ func runDemo(cmd *cobra.Command, args []string) {
 	fmt.Printf("Configuration: LLM Provider = %s\n", cfg.LLMProvider)
 
 	// Create container (tools will be registered)
-	container, err := registry.NewContainer(cfg.LLMProvider, "")
+	container, err := registry.NewContainer(cfg.LLMProvider, "", registry.ContainerOptions{})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating container: %v\n", err)
 		os.Exit(1)
 	}
+	defer container.Close()
 
 	fmt.Println("\n📦 Available Tools:")
 	for _, tool := range container.GetToolRegistry().GetAllTools() {
@@ -228,37 +334,18 @@ func runDemo(cmd *cobra.Command, args []string) {
 
 // isRecoverableAgentError checks if an agent error is recoverable (user can retry)
 func isRecoverableAgentError(err error) bool {
-	errStr := strings.ToLower(err.Error())
-
-	// Check for common recoverable errors
-	recoverableKeywords := []string{
-		"quota",
-		"rate limit",
-		"429",
-		"api key",
-		"401",
-		"403",
-		"service unavailable",
-		"502",
-		"503",
-		"504",
-		"timeout",
-		"network",
-		"connection",
-		"temporary",
-	}
-
-	for _, keyword := range recoverableKeywords {
-		if strings.Contains(errStr, keyword) {
-			return true
-		}
-	}
-
-	return false
+	return llm.IsRecoverable(err)
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+
+	// Commands report their exit code as an error, so their deferred cleanup runs first
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.code)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vhbfernandes/fitbit-agent/pkg/config"
+	"github.com/vhbfernandes/fitbit-agent/pkg/mcp"
+	"github.com/vhbfernandes/fitbit-agent/pkg/registry"
+)
+
+var (
+	mcpHTTPAddr string
+	mcpToken    string
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve the tools over the Model Context Protocol",
+	Long: `Lets other AI clients use the Fitbit and storage tools over MCP.
+
+By default messages are exchanged on stdin and stdout, so the client can start
+the command itself. With --http the tools are served at POST /mcp instead, and
+requests must send "Authorization: Bearer <token>" with the token from --token
+or AGENT_API_TOKEN.`,
+	Args: cobra.NoArgs,
+	Run:  runMCP,
+}
+
+func init() {
+	mcpCmd.Flags().StringVar(&mcpHTTPAddr, "http", "", "serve over HTTP on this address instead of stdio, e.g. :8081")
+	mcpCmd.Flags().StringVar(&mcpToken, "token", "", "bearer token HTTP clients must send (default from AGENT_API_TOKEN)")
+
+	rootCmd.AddCommand(mcpCmd)
+}
+
+func runMCP(cmd *cobra.Command, args []string) {
+	// Only the built-in tools and plugins are served, not those of other MCP servers
+	cfg := config.LoadConfig()
+	toolRegistry, err := registry.NewToolRegistry(cfg, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating tools: %v\n", err)
+		os.Exit(1)
+	}
+	middleware, err := registry.DefaultMiddleware(cfg, registry.NewToolMetrics())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating tools: %v\n", err)
+		os.Exit(1)
+	}
+	toolRegistry.Use(middleware...)
+	server := mcp.NewServer(toolRegistry, mcp.Implementation{Name: "fitbit-agent", Version: "1.0.0"})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if mcpHTTPAddr == "" {
+		if err := server.ServeStdio(ctx, os.Stdin, os.Stdout); err != nil && !errors.Is(err, context.Canceled) {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if mcpToken == "" {
+		mcpToken = cfg.APIToken
+	}
+	if mcpToken == "" {
+		fmt.Fprintln(os.Stderr, "❌ Set AGENT_API_TOKEN or pass --token so only you can use the tools")
+		os.Exit(1)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", server.HTTPHandler(mcpToken))
+	httpServer := &http.Server{Addr: mcpHTTPAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "🔌 Serving MCP tools at http://%s/mcp\n", mcpHTTPAddr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+}
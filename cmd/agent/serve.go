@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/vhbfernandes/fitbit-agent/pkg/config"
+	"github.com/vhbfernandes/fitbit-agent/pkg/input"
+	"github.com/vhbfernandes/fitbit-agent/pkg/registry"
+	"github.com/vhbfernandes/fitbit-agent/pkg/server"
+	"github.com/vhbfernandes/fitbit-agent/pkg/session"
+)
+
+var (
+	serveAddr  string
+	serveToken string
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the agent over HTTP",
+	Long: `Exposes the agent as an HTTP API so meals can be logged from phones and dashboards.
+
+Every request except GET /api/health needs the header "Authorization: Bearer <token>",
+where the token comes from --token or AGENT_API_TOKEN.
+
+Endpoints:
+  POST /api/chat             send {"session_id", "message", "approve"} to a conversation;
+                             with "Accept: text/event-stream" the reply is streamed
+  GET  /api/sessions/{id}    show a conversation
+  GET  /api/tools            list the tool endpoints
+  GET  /api/metrics          show tool call counts, errors and durations
+  POST /api/tools/log_meal   log a meal to Fitbit
+  POST /api/tools/summary    show the meals logged on a date
+  POST /api/tools/lookup     look up the calories of a food`,
+	Args: cobra.NoArgs,
+	Run:  runServe,
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "", "address to listen on (default from AGENT_SERVER_ADDR or :8080)")
+	serveCmd.Flags().StringVar(&serveToken, "token", "", "bearer token clients must send (default from AGENT_API_TOKEN)")
+
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) {
+	if llmProvider != "" {
+		os.Setenv("LLM_PROVIDER", llmProvider)
+	}
+	if systemPrompt != "" {
+		os.Setenv("SYSTEM_PROMPT_FILE", systemPrompt)
+	}
+
+	cfg := config.LoadConfig()
+	if serveAddr == "" {
+		serveAddr = cfg.ServerAddr
+	}
+	if serveToken == "" {
+		serveToken = cfg.APIToken
+	}
+	if serveToken == "" {
+		fmt.Fprintln(os.Stderr, "❌ Set AGENT_API_TOKEN or pass --token so only you can use the API")
+		os.Exit(1)
+	}
+
+	// Nobody is at the terminal, so requests must approve changes themselves
+	container, err := registry.NewContainer(llmProvider, systemPrompt, registry.ContainerOptions{
+		InputProvider: input.NewLinesInputProvider(nil),
+		Output:        io.Discard,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing container: %v\n", err)
+		os.Exit(1)
+	}
+	defer container.Close()
+
+	apiServer, err := server.NewServer(container, session.NewStore(session.DefaultDir()), serveToken)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		container.Close()
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("🌐 Serving the Fitbit Agent API on %s\n", serveAddr)
+	if err := apiServer.ListenAndServe(ctx, serveAddr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		container.Close()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+	"github.com/vhbfernandes/fitbit-agent/pkg/session"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "List saved conversations",
+	Long: `Conversations are saved under ~/.fitbit-agent/sessions so they can be resumed
+with --resume (the most recent one) or --session <id>.`,
+	Args: cobra.NoArgs,
+	Run:  runSessionsList,
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved conversations, most recent first",
+	Args:  cobra.NoArgs,
+	Run:   runSessionsList,
+}
+
+var sessionsShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Print a saved conversation",
+	Args:  cobra.ExactArgs(1),
+	Run:   runSessionsShow,
+}
+
+var sessionsDeleteCmd = &cobra.Command{
+	Use:   "delete <id>...",
+	Short: "Delete saved conversations",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   runSessionsDelete,
+}
+
+func init() {
+	sessionsCmd.AddCommand(sessionsListCmd)
+	sessionsCmd.AddCommand(sessionsShowCmd)
+	sessionsCmd.AddCommand(sessionsDeleteCmd)
+
+	rootCmd.AddCommand(sessionsCmd)
+}
+
+func runSessionsList(cmd *cobra.Command, args []string) {
+	sessions, err := session.NewStore(session.DefaultDir()).List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing sessions: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(sessions) == 0 {
+		fmt.Println("No saved conversations yet")
+		return
+	}
+
+	for _, s := range sessions {
+		fmt.Printf("%s  %s  %-8s %3d messages  %s\n",
+			s.ID, s.UpdatedAt.Format("2006-01-02 15:04"), s.Provider, len(s.Messages), s.Title())
+	}
+}
+
+func runSessionsShow(cmd *cobra.Command, args []string) {
+	s, err := session.NewStore(session.DefaultDir()).Get(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading session: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Session %s (%s), started %s\n\n", s.ID, s.Provider, s.CreatedAt.Format("2006-01-02 15:04"))
+	for _, msg := range s.Messages {
+		agent.PrintMessage(os.Stdout, msg)
+	}
+}
+
+func runSessionsDelete(cmd *cobra.Command, args []string) {
+	store := session.NewStore(session.DefaultDir())
+	for _, id := range args {
+		if err := store.Delete(id); err != nil {
+			fmt.Fprintf(os.Stderr, "Error deleting session: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("🗑️  Deleted session %s\n", id)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+// handleSignals returns a context cancelled on SIGTERM or on a Ctrl-C that
+// arrives while the agent is idle. A Ctrl-C during a request only interrupts
+// that request, so a second one is needed to quit.
+func handleSignals(a agent.Agent) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if sig == os.Interrupt && a.Interrupt() {
+					fmt.Fprintln(os.Stderr, "\n\u001b[93m⏹  Interrupted. Press Ctrl-C again to quit.\u001b[0m")
+					continue
+				}
+				fmt.Fprintln(os.Stderr)
+				cancel()
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
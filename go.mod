@@ -2,10 +2,12 @@ module github.com/vhbfernandes/fitbit-agent
 
 go 1.24.3
 
-require github.com/spf13/cobra v1.9.1
+require (
+	github.com/joho/godotenv v1.5.1
+	github.com/spf13/cobra v1.9.1
+)
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
 	github.com/spf13/pflag v1.0.6 // indirect
 )
@@ -0,0 +1,178 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Tools run directly by slash commands
+const (
+	summaryToolName = "view_daily_summary"
+	loginToolName   = "fitbit_login"
+)
+
+// ProviderSwitcher creates the LLM provider with the given name, for /provider
+type ProviderSwitcher func(name string) (LLMProvider, error)
+
+// commandHelp lists the slash commands in the order /help shows them
+var commandHelp = []struct {
+	usage       string
+	description string
+}{
+	{"/help", "Show this list"},
+	{"/tools", "List the available tools"},
+	{"/summary [date]", "Show the meals logged today or on a date (YYYY-MM-DD)"},
+	{"/undo", "Reverse the last meal logged or saved"},
+	{"/provider [name]", "Show or switch the LLM provider"},
+	{"/reset", "Start a new conversation"},
+	{"/login", "Log in to Fitbit"},
+	{"/history", "Show the conversation so far"},
+	{"/quit", "Exit"},
+}
+
+// isCommand reports whether the user typed a slash command rather than a message
+func isCommand(input string) bool {
+	input = strings.TrimSpace(input)
+	return len(input) > 1 && input[0] == '/' && input[1] != ' ' && input[1] != '/'
+}
+
+// runCommand executes a slash command against the tools and agent state without
+// calling the LLM. It reports whether the user asked to quit.
+func (a *InteractiveAgent) runCommand(ctx context.Context, input string, conversation *[]Message) bool {
+	fields := strings.Fields(strings.TrimSpace(input))
+	name, args := strings.ToLower(fields[0]), fields[1:]
+
+	switch name {
+	case "/help":
+		fmt.Fprintln(a.out, "Commands:")
+		for _, help := range commandHelp {
+			fmt.Fprintf(a.out, "  %-18s %s\n", help.usage, help.description)
+		}
+		fmt.Fprintln(a.out, "Anything else is sent to the assistant.")
+
+	case "/tools":
+		for _, tool := range a.toolRegistry.GetAllTools() {
+			fmt.Fprintf(a.out, "  - %s: %s\n", tool.Name(), tool.Description())
+		}
+
+	case "/summary":
+		params := map[string]string{}
+		if len(args) > 0 {
+			params["date"] = args[0]
+		}
+		a.runCommandTool(ctx, summaryToolName, params)
+
+	case "/undo":
+		a.undoLastAction(ctx, conversation)
+
+	case "/provider":
+		a.switchProvider(args)
+
+	case "/reset":
+		*conversation = []Message{}
+		fmt.Fprintln(a.out, "🧹 Started a new conversation")
+
+	case "/login":
+		a.runCommandTool(ctx, loginToolName, map[string]bool{"force_reauth": len(args) > 0 && args[0] == "force"})
+
+	case "/history":
+		if len(*conversation) == 0 {
+			fmt.Fprintln(a.out, "The conversation is empty")
+		}
+		for _, msg := range *conversation {
+			PrintMessage(a.out, msg)
+		}
+
+	case "/quit", "/exit":
+		return true
+
+	default:
+		fmt.Fprintf(a.out, "Unknown command %s, type /help to see the commands\n", name)
+	}
+
+	return false
+}
+
+// runCommandTool executes a tool for a slash command and prints its result
+func (a *InteractiveAgent) runCommandTool(ctx context.Context, name string, params interface{}) {
+	if _, found := a.toolRegistry.GetTool(name); !found {
+		fmt.Fprintf(a.out, "\u001b[91m❌ The %s tool is not available\u001b[0m\n", name)
+		return
+	}
+
+	input, _ := json.Marshal(params)
+	result, _ := a.executeTool(ctx, ToolCall{ID: "command", Name: name, Input: input})
+	if result.IsError {
+		fmt.Fprintf(a.out, "\u001b[91m❌ Tool Error\u001b[0m:\n%s\n", result.Content)
+	} else {
+		fmt.Fprintf(a.out, "\u001b[92m✅ Tool Success\u001b[0m:\n%s\n", result.Content)
+	}
+}
+
+// undoLastAction reverses the most recent journaled action and lets the LLM
+// know, so it does not think the meal is still logged
+func (a *InteractiveAgent) undoLastAction(ctx context.Context, conversation *[]Message) {
+	undone, err := a.journal.Undo(ctx, a.toolRegistry, 1)
+	for _, action := range undone {
+		fmt.Fprintf(a.out, "↩️  Undid: %s\n", action.Summary())
+		*conversation = append(*conversation, Message{
+			Role:    "assistant",
+			Content: "The user undid this action: " + action.Summary(),
+		})
+	}
+	if err != nil {
+		fmt.Fprintf(a.out, "\u001b[91m❌ %v\u001b[0m\n", err)
+	} else if len(undone) == 0 {
+		fmt.Fprintln(a.out, "Nothing to undo")
+	}
+}
+
+// switchProvider shows the current provider or replaces it with the named one
+func (a *InteractiveAgent) switchProvider(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(a.out, "Using %s\n", a.llmProvider.Name())
+		return
+	}
+	if a.newProvider == nil {
+		fmt.Fprintln(a.out, "Switching providers is not supported in this session")
+		return
+	}
+
+	provider, err := a.newProvider(args[0])
+	if err != nil {
+		fmt.Fprintf(a.out, "\u001b[91m❌ Could not switch to %s\u001b[0m: %v\n", args[0], err)
+		return
+	}
+
+	a.llmProvider = provider
+	a.history.llm = provider
+	fmt.Fprintf(a.out, "🔀 Now chatting with %s\n", provider.Name())
+}
+
+// PrintMessage prints a conversation message the way it appeared in the chat
+func PrintMessage(w io.Writer, msg Message) {
+	switch msg.Role {
+	case "user":
+		fmt.Fprintf(w, "\u001b[94mYou\u001b[0m: %v\n", msg.Content)
+	case "tool":
+		result, ok := msg.Content.(ToolResult)
+		if !ok {
+			return
+		}
+		if result.IsError {
+			fmt.Fprintf(w, "\u001b[91m❌ %s\u001b[0m: %s\n", result.Name, result.Content)
+		} else {
+			fmt.Fprintf(w, "\u001b[92m✅ %s\u001b[0m: %s\n", result.Name, result.Content)
+		}
+	default:
+		if content, ok := msg.Content.(string); ok && content != "" {
+			fmt.Fprintf(w, "\u001b[93mFitbit Agent\u001b[0m: %s\n", content)
+		}
+		for _, toolCall := range msg.ToolCalls {
+			fmt.Fprintf(w, "\u001b[92mtool\u001b[0m: %s(%s)\n", toolCall.Name, string(toolCall.Input))
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ActionPreview describes what a side-effecting tool call is about to change
+type ActionPreview struct {
+	Action   string        `json:"action"` // e.g. "Log to Fitbit"
+	Dates    []string      `json:"dates,omitempty"`
+	MealType string        `json:"meal_type,omitempty"`
+	Foods    []PreviewFood `json:"foods,omitempty"`
+}
+
+// PreviewFood is a single food in an ActionPreview
+type PreviewFood struct {
+	Name     string  `json:"name"`
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit"`
+	Calories float64 `json:"calories"`
+}
+
+// Calories returns the calories of one serving of the meal
+func (p *ActionPreview) Calories() float64 {
+	total := 0.0
+	for _, food := range p.Foods {
+		total += food.Calories
+	}
+	return total
+}
+
+// TotalCalories returns the calories across every date the action covers
+func (p *ActionPreview) TotalCalories() float64 {
+	if len(p.Dates) > 1 {
+		return p.Calories() * float64(len(p.Dates))
+	}
+	return p.Calories()
+}
+
+// confirmCalls asks the user to approve side-effecting tool calls before any of
+// them run. It returns the result to send back for each call that was not
+// approved, by index; approved calls are left to run as usual.
+func (a *InteractiveAgent) confirmCalls(ctx context.Context, toolCalls []ToolCall) map[int]ToolResult {
+	declined := map[int]ToolResult{}
+	for i, toolCall := range toolCalls {
+		preview := a.previewCall(toolCall)
+		if preview == nil {
+			continue
+		}
+
+		if a.autoApprove(preview) {
+			fmt.Fprintf(a.out, "\u001b[90m   ↳ %s auto-approved (~%.0f cal)\u001b[0m\n", strings.ToLower(preview.Action), preview.TotalCalories())
+			continue
+		}
+
+		a.printPreview(preview)
+		if reason := a.askApproval(ctx); reason != "" {
+			result := ToolResult{CallID: toolCall.ID, Name: toolCall.Name, Declined: true}
+			declined[i] = result.failed(reason)
+		}
+	}
+	return declined
+}
+
+// previewCall returns the preview of a side-effecting call, or nil if the call
+// does not need confirming. Calls that would fail validation are not previewed
+// as they are rejected before running anyway.
+func (a *InteractiveAgent) previewCall(toolCall ToolCall) *ActionPreview {
+	tool, found := a.toolRegistry.GetTool(toolCall.Name)
+	if !found || toolCall.ParseError != "" {
+		return nil
+	}
+	writer, ok := AsTool[SideEffectTool](tool)
+	if !ok || !writer.SideEffects() {
+		return nil
+	}
+
+	input, err := a.toolRegistry.ValidateInput(toolCall.Name, toolCall.Input)
+	if err != nil {
+		return nil
+	}
+	preview, err := writer.Preview(input)
+	if err != nil {
+		// Still ask, the call may change something even if it cannot say what
+		return &ActionPreview{Action: fmt.Sprintf("Run %s (no preview: %v)", toolCall.Name, err)}
+	}
+	return preview
+}
+
+// autoApprove reports whether the action may run without asking, because every
+// action is approved or it is a meal small enough
+func (a *InteractiveAgent) autoApprove(preview *ActionPreview) bool {
+	return a.approveAll || a.autoApproveCalories > 0 && len(preview.Foods) > 0 && preview.TotalCalories() < float64(a.autoApproveCalories)
+}
+
+// askApproval prompts until the user answers and explains to the LLM why the
+// call did not run, or returns "" if it was approved. Anything other than yes,
+// no or edit is taken as the requested change.
+func (a *InteractiveAgent) askApproval(ctx context.Context) string {
+	for {
+		fmt.Fprint(a.out, "Proceed? [y]es / [n]o / [e]dit: ")
+		answer, ok := a.inputProvider.GetInput(ctx)
+		if !ok {
+			return "Error: the user did not approve this action, nothing was changed."
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return ""
+		case "n", "no":
+			return "Error: the user declined this action, nothing was changed. Ask what they would like to do instead."
+		case "":
+			continue
+		case "e", "edit":
+			fmt.Fprint(a.out, "What should change? ")
+			if answer, ok = a.inputProvider.GetInput(ctx); !ok || strings.TrimSpace(answer) == "" {
+				return "Error: the user did not approve this action, nothing was changed."
+			}
+		}
+
+		return fmt.Sprintf("Error: the user asked for a change before this runs, nothing was changed yet: %q. "+
+			"Call the tool again with the corrected input.", strings.TrimSpace(answer))
+	}
+}
+
+// printPreview shows what a side-effecting call is about to change
+func (a *InteractiveAgent) printPreview(preview *ActionPreview) {
+	title := preview.Action
+	if preview.MealType != "" {
+		title += ": " + preview.MealType
+	}
+	if len(preview.Dates) > 0 {
+		title += " on " + strings.Join(preview.Dates, ", ")
+	}
+	fmt.Fprintf(a.out, "\u001b[96m📝 %s\u001b[0m\n", title)
+
+	for _, food := range preview.Foods {
+		fmt.Fprintf(a.out, "   - %s (%g %s): ~%.0f cal\n", food.Name, food.Quantity, food.Unit, food.Calories)
+	}
+	if len(preview.Foods) == 0 {
+		return
+	}
+	if len(preview.Dates) > 1 {
+		fmt.Fprintf(a.out, "   💯 Total: ~%.0f cal per day, ~%.0f cal over %d days\n", preview.Calories(), preview.TotalCalories(), len(preview.Dates))
+	} else {
+		fmt.Fprintf(a.out, "   💯 Total: ~%.0f cal\n", preview.Calories())
+	}
+}
@@ -0,0 +1,27 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// FollowUpError is returned by a tool that cannot finish until another tool has
+// run, such as logging a meal before the user has logged in to Fitbit. The agent
+// runs the follow-up tool itself and, when RetryOriginal is set and the follow-up
+// succeeds, runs the original call again, without a round-trip to the LLM.
+type FollowUpError struct {
+	Reason        string
+	Tool          string
+	Input         json.RawMessage
+	RetryOriginal bool
+}
+
+// Error implements the error interface
+func (e *FollowUpError) Error() string {
+	return fmt.Sprintf("%s: %s must run first", e.Reason, e.Tool)
+}
+
+// key identifies the follow-up so it runs once for all calls that need it
+func (e *FollowUpError) key() string {
+	return e.Tool + "\x00" + string(e.Input)
+}
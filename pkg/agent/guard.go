@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// maxIdenticalCalls is how often the same tool call may be made in one turn. A
+// second identical call is allowed so a meal can be retried after logging in.
+const maxIdenticalCalls = 2
+
+// turnGuard bounds the work the agent does for a single user message: the
+// number of LLM calls, identical tool calls and the wall-clock time spent. It
+// also lets the user interrupt the turn.
+type turnGuard struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	maxSteps    int
+	timeout     time.Duration
+	steps       int
+	calls       map[string]int
+	interrupted atomic.Bool
+}
+
+// newTurnGuard starts a turn. A maxSteps or timeout of 0 disables that limit.
+func newTurnGuard(parent context.Context, maxSteps int, timeout time.Duration) *turnGuard {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+
+	return &turnGuard{
+		ctx:      ctx,
+		cancel:   cancel,
+		maxSteps: maxSteps,
+		timeout:  timeout,
+		calls:    make(map[string]int),
+	}
+}
+
+// step records an LLM call and explains why the turn must stop, if it must
+func (g *turnGuard) step() string {
+	if reason := g.stopped(); reason != "" {
+		return reason
+	}
+
+	g.steps++
+	if g.maxSteps > 0 && g.steps > g.maxSteps {
+		return fmt.Sprintf("reached the limit of %d steps for a single request", g.maxSteps)
+	}
+	return ""
+}
+
+// stopped explains why the turn was cut short, if it was interrupted or ran out of time
+func (g *turnGuard) stopped() string {
+	if g.interrupted.Load() {
+		return "it was cancelled"
+	}
+	if errors.Is(g.ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Sprintf("the request took longer than %s", g.timeout)
+	}
+	return ""
+}
+
+// interrupt cancels the turn and reports whether it was still running
+func (g *turnGuard) interrupt() bool {
+	if g.interrupted.Swap(true) {
+		return false
+	}
+	g.cancel()
+	return true
+}
+
+// repeated records the tool calls and explains which one has been made too
+// often with the same input, if any
+func (g *turnGuard) repeated(toolCalls []ToolCall) string {
+	for _, toolCall := range toolCalls {
+		key := toolCall.Name + "\x00" + string(toolCall.Input)
+		g.calls[key]++
+		if g.calls[key] > maxIdenticalCalls {
+			return fmt.Sprintf("the model kept calling %s with the same input", toolCall.Name)
+		}
+	}
+	return ""
+}
+
+// release frees the turn's context
+func (g *turnGuard) release() {
+	g.cancel()
+}
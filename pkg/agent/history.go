@@ -0,0 +1,125 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// summaryPrefix marks the message that replaces summarized turns
+const summaryPrefix = "Summary of the earlier conversation:\n"
+
+// HistoryManager keeps the conversation within a token budget by dropping or
+// summarizing the oldest turns. The system prompt is added by providers on every
+// call and the current turn, including its latest tool results, is always kept.
+type HistoryManager struct {
+	llm       LLMProvider
+	maxTokens int
+	summarize bool
+}
+
+// NewHistoryManager creates a history manager. A maxTokens of 0 disables trimming.
+func NewHistoryManager(llm LLMProvider, maxTokens int, summarize bool) *HistoryManager {
+	return &HistoryManager{
+		llm:       llm,
+		maxTokens: maxTokens,
+		summarize: summarize,
+	}
+}
+
+// EstimateTokens approximates the number of tokens in the messages, using the
+// common rule of thumb of four characters per token
+func EstimateTokens(messages []Message) int {
+	chars := 0
+	for _, msg := range messages {
+		chars += len(msg.Role) + len(fmt.Sprintf("%s", msg.Content))
+	}
+	return (chars + 3) / 4
+}
+
+// Fit returns the conversation trimmed to the token budget. Old turns are removed
+// whole and, when summarization is enabled, replaced by a single summary message.
+func (h *HistoryManager) Fit(ctx context.Context, conversation []Message) []Message {
+	if h.maxTokens <= 0 || EstimateTokens(conversation) <= h.maxTokens {
+		return conversation
+	}
+
+	// Leave room for the summary that will replace the dropped turns
+	target := h.maxTokens
+	if h.summarize {
+		target -= h.maxTokens / 4
+	}
+
+	// Never cut into the current turn
+	protectedFrom := lastUserInputIndex(conversation)
+
+	cut := 0
+	for cut < protectedFrom && EstimateTokens(conversation[cut:]) > target {
+		cut = nextUserInputIndex(conversation, cut+1, protectedFrom)
+	}
+	if cut == 0 {
+		return conversation
+	}
+
+	dropped := conversation[:cut]
+	kept := append([]Message{}, conversation[cut:]...)
+
+	if h.summarize {
+		summary, err := h.summarizeMessages(ctx, dropped)
+		if err == nil && summary != "" {
+			return append([]Message{{Role: "user", Content: summaryPrefix + summary}}, kept...)
+		}
+	}
+
+	return kept
+}
+
+// summarizeMessages asks the LLM for a compact summary of the given messages
+func (h *HistoryManager) summarizeMessages(ctx context.Context, messages []Message) (string, error) {
+	var transcript strings.Builder
+	for _, msg := range messages {
+		transcript.WriteString(fmt.Sprintf("%s: %s\n", msg.Role, msg.Content))
+	}
+
+	request := []Message{{
+		Role: "user",
+		Content: "Summarize the following conversation in a few sentences so it can replace the original. " +
+			"Keep every food, quantity, calorie estimate, meal type and date that was logged or discussed. " +
+			"Reply with the summary only and do not call any tools.\n\n" + transcript.String(),
+	}}
+
+	response, err := h.llm.GenerateResponse(ctx, request)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(response.Content), nil
+}
+
+// isUserInput reports whether msg was typed by the user, as opposed to a
+// summary sent on the user's behalf
+func isUserInput(msg Message) bool {
+	if msg.Role != "user" {
+		return false
+	}
+	return !strings.HasPrefix(fmt.Sprintf("%s", msg.Content), summaryPrefix)
+}
+
+// lastUserInputIndex returns the index where the current turn starts
+func lastUserInputIndex(conversation []Message) int {
+	for i := len(conversation) - 1; i >= 0; i-- {
+		if isUserInput(conversation[i]) {
+			return i
+		}
+	}
+	return 0
+}
+
+// nextUserInputIndex returns the first turn start at or after from, or limit if there is none
+func nextUserInputIndex(conversation []Message, from, limit int) int {
+	for i := from; i < limit; i++ {
+		if isUserInput(conversation[i]) {
+			return i
+		}
+	}
+	return limit
+}
@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// summaryProvider answers every request with a fixed summary
+type summaryProvider struct {
+	calls int
+}
+
+func (p *summaryProvider) Name() string { return "summary" }
+
+func (p *summaryProvider) GenerateResponse(ctx context.Context, conversation []Message) (*Response, error) {
+	p.calls++
+	return &Response{Content: "User logged eggs for breakfast."}, nil
+}
+
+func buildConversation(turns int) []Message {
+	var conversation []Message
+	for i := 0; i < turns; i++ {
+		conversation = append(conversation,
+			Message{Role: "user", Content: fmt.Sprintf("I had meal number %d with lots of details %s", i, strings.Repeat("x", 200))},
+			Message{Role: "assistant", Content: fmt.Sprintf("Logged meal %d %s", i, strings.Repeat("y", 200))},
+		)
+	}
+	return conversation
+}
+
+func TestHistoryManagerFit(t *testing.T) {
+	t.Run("Within budget is unchanged", func(t *testing.T) {
+		conversation := buildConversation(2)
+		fitted := NewHistoryManager(&summaryProvider{}, 10000, true).Fit(context.Background(), conversation)
+		if len(fitted) != len(conversation) {
+			t.Errorf("Expected %d messages but got %d", len(conversation), len(fitted))
+		}
+	})
+
+	t.Run("Drops oldest turns and keeps latest tool results", func(t *testing.T) {
+		result := ToolResult{CallID: "call_0", Name: "fitbit_log_meal", Content: "logged"}
+		conversation := append(buildConversation(10), Message{Role: "tool", Content: result})
+		fitted := NewHistoryManager(&summaryProvider{}, 300, false).Fit(context.Background(), conversation)
+
+		if EstimateTokens(fitted) >= EstimateTokens(conversation) {
+			t.Fatalf("Expected history to shrink")
+		}
+		if !isUserInput(fitted[0]) {
+			t.Errorf("Expected history to start at a user turn, got %v", fitted[0])
+		}
+		if last := fitted[len(fitted)-1]; last.Content != result {
+			t.Errorf("Expected latest tool result to be kept, got %v", last)
+		}
+	})
+
+	t.Run("Summarizes dropped turns", func(t *testing.T) {
+		provider := &summaryProvider{}
+		fitted := NewHistoryManager(provider, 400, true).Fit(context.Background(), buildConversation(10))
+
+		if provider.calls != 1 {
+			t.Errorf("Expected one summarization call but got %d", provider.calls)
+		}
+		if !strings.HasPrefix(fmt.Sprintf("%s", fitted[0].Content), summaryPrefix) {
+			t.Errorf("Expected summary as first message, got %v", fitted[0])
+		}
+	})
+}
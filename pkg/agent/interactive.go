@@ -2,172 +2,540 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
+	"io"
+	"os"
+	"sync"
+	"time"
 )
 
+// Options configures optional agent behaviour
+type Options struct {
+	// MaxHistoryTokens is the token budget for the conversation history (0 disables trimming)
+	MaxHistoryTokens int
+	// SummarizeHistory summarizes trimmed turns with the LLM instead of dropping them
+	SummarizeHistory bool
+	// MaxRepairAttempts is how many times per turn the LLM is asked to resend
+	// tool arguments that were not valid JSON (0 disables re-asking)
+	MaxRepairAttempts int
+	// MaxParallelTools limits how many tool calls run at once (0 runs them one at a time)
+	MaxParallelTools int
+	// MaxStepsPerTurn limits the LLM calls made for a single user message (0 disables the limit)
+	MaxStepsPerTurn int
+	// TurnTimeout limits the time spent on a single user message (0 disables the limit)
+	TurnTimeout time.Duration
+	// Store saves the conversation after every turn and provides the one to resume (nil keeps it in memory)
+	Store ConversationStore
+	// SwitchProvider creates the provider named in /provider (nil disables switching)
+	SwitchProvider ProviderSwitcher
+	// AutoApproveCalories runs side-effecting calls below this many calories
+	// without asking (0 always asks)
+	AutoApproveCalories int
+	// Journal records side-effecting calls so they can be undone (nil creates one)
+	Journal *Journal
+	// AutoApprove runs every side-effecting call without asking
+	AutoApprove bool
+	// Output receives everything the agent prints (nil prints to stdout)
+	Output io.Writer
+}
+
 // Implementation of the main agent
 type InteractiveAgent struct {
-	llmProvider   LLMProvider
-	toolRegistry  ToolRegistry
-	inputProvider UserInputProvider
+	llmProvider         LLMProvider
+	toolRegistry        ToolRegistry
+	inputProvider       UserInputProvider
+	history             *HistoryManager
+	maxRepairs          int
+	maxParallel         int
+	maxSteps            int
+	turnTimeout         time.Duration
+	store               ConversationStore
+	newProvider         ProviderSwitcher
+	autoApproveCalories int
+	journal             *Journal
+	approveAll          bool
+	out                 io.Writer
+	active              *turnGuard // turn being worked on, nil while waiting for input
+	mu                  sync.Mutex
 }
 
 // NewInteractiveAgent creates a new interactive agent
-func NewInteractiveAgent(llm LLMProvider, registry ToolRegistry, input UserInputProvider) *InteractiveAgent {
+func NewInteractiveAgent(llm LLMProvider, registry ToolRegistry, input UserInputProvider, opts Options) *InteractiveAgent {
+	if opts.MaxParallelTools < 1 {
+		opts.MaxParallelTools = 1
+	}
+	if opts.Journal == nil {
+		opts.Journal = NewJournal()
+	}
+	if opts.Output == nil {
+		opts.Output = os.Stdout
+	}
+
 	return &InteractiveAgent{
-		llmProvider:   llm,
-		toolRegistry:  registry,
-		inputProvider: input,
+		llmProvider:         llm,
+		toolRegistry:        registry,
+		inputProvider:       input,
+		history:             NewHistoryManager(llm, opts.MaxHistoryTokens, opts.SummarizeHistory),
+		maxRepairs:          opts.MaxRepairAttempts,
+		maxParallel:         opts.MaxParallelTools,
+		maxSteps:            opts.MaxStepsPerTurn,
+		turnTimeout:         opts.TurnTimeout,
+		store:               opts.Store,
+		newProvider:         opts.SwitchProvider,
+		autoApproveCalories: opts.AutoApproveCalories,
+		journal:             opts.Journal,
+		approveAll:          opts.AutoApprove,
+		out:                 opts.Output,
 	}
 }
 
 // Run starts the interactive agent loop
 func (a *InteractiveAgent) Run(ctx context.Context) error {
-	conversation := []Message{}
+	conversation, err := a.loadConversation()
+	if err != nil {
+		return err
+	}
+	defer func() { a.saveConversation(conversation) }()
 
-	fmt.Printf("🥗 Welcome to Fitbit Agent! Chat with %s to log your meals (use 'ctrl-c' to quit)\n", a.llmProvider.Name())
-	fmt.Println("Try saying: 'I had scrambled eggs and toast for breakfast'")
+	fmt.Fprintf(a.out, "🥗 Welcome to Fitbit Agent! Chat with %s to log your meals (type /help for commands, 'ctrl-c' to quit)\n", a.llmProvider.Name())
+	if len(conversation) > 0 {
+		fmt.Fprintf(a.out, "📂 Resumed a conversation with %d earlier messages\n", len(conversation))
+	} else {
+		fmt.Fprintln(a.out, "Try saying: 'I had scrambled eggs and toast for breakfast'")
+	}
 
-	readUserInput := true
-	for {
-		if readUserInput {
-			fmt.Print("\u001b[94mYou\u001b[0m: ")
-			userInput, ok := a.inputProvider.GetInput()
-			if !ok {
+	for ctx.Err() == nil {
+		a.saveConversation(conversation)
+		fmt.Fprint(a.out, "\u001b[94mYou\u001b[0m: ")
+		userInput, ok := a.inputProvider.GetInput(ctx)
+		if !ok {
+			break
+		}
+
+		// Slash commands are handled here without calling the LLM
+		if isCommand(userInput) {
+			if a.runCommandTurn(ctx, userInput, &conversation) {
 				break
 			}
-
-			conversation = append(conversation, Message{
-				Role:    "user",
-				Content: userInput,
-			})
+			continue
 		}
 
-		response, err := a.llmProvider.GenerateResponse(ctx, conversation)
-		if err != nil {
-			// Check for specific API errors and handle gracefully
-			if a.isRecoverableError(err) {
-				fmt.Printf("\u001b[91m❌ %s API Error\u001b[0m: %s\n", a.llmProvider.Name(), err.Error())
-				fmt.Printf("\u001b[93m💡 Suggestion\u001b[0m: ")
-
-				if strings.Contains(err.Error(), "quota") {
-					fmt.Printf("You've exceeded your API quota. Please:\n")
-					fmt.Printf("   1. Check your billing plan\n")
-					fmt.Printf("   2. Wait for quota reset\n")
-					fmt.Printf("   3. Try using a different provider (deepseek with Ollama)\n")
-				} else if strings.Contains(err.Error(), "rate limit") {
-					fmt.Printf("API rate limited. Please wait a moment and try again.\n")
-				} else if strings.Contains(err.Error(), "API key") {
-					fmt.Printf("Invalid API key. Please check your %s_API_KEY environment variable.\n", strings.ToUpper(a.llmProvider.Name()))
-				} else if strings.Contains(err.Error(), "service unavailable") {
-					fmt.Printf("Service temporarily unavailable. Please try again later.\n")
-				} else {
-					fmt.Printf("Try again or switch to a different LLM provider.\n")
-				}
+		conversation = append(conversation, Message{
+			Role:    "user",
+			Content: userInput,
+		})
+
+		if _, err := a.runTurn(ctx, &conversation); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+
+			// Check for typed provider errors and handle gracefully
+			var recoverable RecoverableError
+			if errors.As(err, &recoverable) && recoverable.Recoverable() {
+				fmt.Fprintf(a.out, "\u001b[91m❌ %s API Error\u001b[0m: %s\n", a.llmProvider.Name(), err.Error())
+				fmt.Fprintf(a.out, "\u001b[93m💡 Suggestion\u001b[0m: %s\n", recoverable.Suggestion())
 
 				// Continue the conversation loop instead of crashing
-				fmt.Print("\nPress Enter to continue or Ctrl+C to quit...")
-				a.inputProvider.GetInput()
-				readUserInput = true
+				fmt.Fprint(a.out, "\nPress Enter to continue or Ctrl+C to quit...")
+				a.inputProvider.GetInput(ctx)
 				continue
 			}
 
 			// For non-recoverable errors, still return them
 			return fmt.Errorf("LLM error: %w", err)
 		}
+	}
 
-		// Add assistant response to conversation
-		conversation = append(conversation, Message{
-			Role:    "assistant",
-			Content: response.Content,
-		})
+	return nil
+}
 
-		// Display assistant response if there's text content
+// RunTurn sends a single message and works on it until the LLM answers without
+// calling more tools, without reading further input except to confirm
+// side-effecting calls. The conversation is loaded from and saved to the store.
+func (a *InteractiveAgent) RunTurn(ctx context.Context, userInput string) (*TurnResult, error) {
+	conversation, err := a.loadConversation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { a.saveConversation(conversation) }()
+
+	conversation = append(conversation, Message{
+		Role:    "user",
+		Content: userInput,
+	})
+	return a.runTurn(ctx, &conversation)
+}
+
+// runCommandTurn runs a slash command so that Ctrl-C can interrupt it, and
+// reports whether the user asked to quit
+func (a *InteractiveAgent) runCommandTurn(ctx context.Context, userInput string, conversation *[]Message) bool {
+	guard := newTurnGuard(ctx, 0, 0)
+	a.setActive(guard)
+	defer func() {
+		a.setActive(nil)
+		guard.release()
+	}()
+
+	return a.runCommand(guard.ctx, userInput, conversation)
+}
+
+// runTurn works on the last user message in the conversation, within the
+// turn's limits. It only returns an error when the LLM call fails.
+func (a *InteractiveAgent) runTurn(ctx context.Context, conversation *[]Message) (*TurnResult, error) {
+	guard := newTurnGuard(ctx, a.maxSteps, a.turnTimeout)
+	a.setActive(guard)
+	defer func() {
+		a.setActive(nil)
+		guard.release()
+	}()
+
+	result := &TurnResult{}
+	journaled := a.journal.Len()
+	err := a.work(guard, conversation, result)
+	result.Actions = a.journal.Since(journaled)
+	return result, err
+}
+
+// work calls the LLM and runs the tools it asks for until it answers without
+// tool calls or the turn has to stop
+func (a *InteractiveAgent) work(guard *turnGuard, conversation *[]Message, result *TurnResult) error {
+	repairAttempts := 0
+	for {
+		// Stop before calling the LLM again if the turn is over budget
+		if reason := guard.step(); reason != "" {
+			a.stopTurn(result, reason)
+			return nil
+		}
+
+		// Keep the history within the token budget before every call
+		*conversation = a.history.Fit(guard.ctx, *conversation)
+
+		response, err := a.generateResponse(guard.ctx, *conversation)
+		if err != nil {
+			if reason := guard.stopped(); reason != "" {
+				a.stopTurn(result, reason)
+				return nil
+			}
+			return err
+		}
 		if response.Content != "" {
-			fmt.Printf("\u001b[93mFitbit Agent\u001b[0m: %s\n", response.Content)
+			result.Reply = response.Content
 		}
 
-		// Execute any tool calls
-		toolResults := []string{}
-		for _, toolCall := range response.ToolCalls {
-			result := a.executeTool(ctx, toolCall)
-			toolResults = append(toolResults, result)
+		// Don't run the same calls over and over
+		if reason := guard.repeated(response.ToolCalls); reason != "" {
+			*conversation = append(*conversation, Message{Role: "assistant", Content: response.Content})
+			a.stopTurn(result, reason)
+			return nil
 		}
 
-		if len(toolResults) == 0 {
-			readUserInput = true
-			continue
+		// Add assistant response to conversation
+		*conversation = append(*conversation, Message{
+			Role:      "assistant",
+			Content:   response.Content,
+			ToolCalls: response.ToolCalls,
+		})
+
+		if len(response.ToolCalls) == 0 {
+			return nil
+		}
+
+		// Execute the tool calls, independent ones concurrently
+		if hasParseErrors(response.ToolCalls) {
+			repairAttempts++
 		}
+		toolResults := a.executeTools(guard.ctx, response.ToolCalls)
+		result.ToolResults = append(result.ToolResults, toolResults...)
 
 		// Display tool results to user and add them to conversation
-		readUserInput = false
-		for i, result := range toolResults {
-			// Check if it's an error or success
-			if strings.HasPrefix(result, "Error") {
-				fmt.Printf("\u001b[91m❌ Tool Error %d\u001b[0m:\n%s\n\n", i+1, result)
+		for i, toolResult := range toolResults {
+			if toolResult.IsError {
+				fmt.Fprintf(a.out, "\u001b[91m❌ Tool Error %d\u001b[0m:\n%s\n\n", i+1, toolResult.Content)
 			} else {
-				fmt.Printf("\u001b[92m✅ Tool Success %d\u001b[0m:\n%s\n\n", i+1, result)
+				fmt.Fprintf(a.out, "\u001b[92m✅ Tool Success %d\u001b[0m:\n%s\n\n", i+1, toolResult.Content)
 			}
 
-			// Add tool result to conversation with clear formatting for the LLM
-			conversation = append(conversation, Message{
-				Role:    "user",
-				Content: fmt.Sprintf("Tool result: %s", result),
+			*conversation = append(*conversation, Message{
+				Role:    "tool",
+				Content: toolResult,
 			})
+		}
 
-			// Check if tool result contains suggested tool calls
-			if strings.Contains(result, "TOOL_CALL:") {
-				fmt.Printf("\u001b[96m🔧 Tool suggested another action, processing...\u001b[0m\n")
-				readUserInput = false // Force LLM to process the suggested tool call
-			}
+		// Stop asking the LLM to fix its arguments once the attempts are used up
+		if repairAttempts > a.maxRepairs {
+			fmt.Fprintf(a.out, "\u001b[91m❌ The model could not produce valid tool arguments, please rephrase your request\u001b[0m\n")
+			result.Stopped = "the model could not produce valid tool arguments"
+			return nil
 		}
 	}
+}
 
-	return nil
+// Interrupt cancels the LLM or tool calls for the request the agent is working
+// on and returns to the prompt. It reports false if the agent is waiting for
+// input or the request was already interrupted.
+func (a *InteractiveAgent) Interrupt() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.active == nil {
+		return false
+	}
+	return a.active.interrupt()
 }
 
-// isRecoverableError checks if an error is recoverable (API quota, rate limits, etc.)
-func (a *InteractiveAgent) isRecoverableError(err error) bool {
-	errStr := strings.ToLower(err.Error())
-
-	// Check for common recoverable API errors
-	recoverableKeywords := []string{
-		"quota",
-		"rate limit",
-		"429",
-		"api key",
-		"401",
-		"403",
-		"service unavailable",
-		"502",
-		"503",
-		"504",
-		"timeout",
-		"temporary",
-	}
-
-	for _, keyword := range recoverableKeywords {
-		if strings.Contains(errStr, keyword) {
-			return true
+// setActive records the turn being worked on
+func (a *InteractiveAgent) setActive(guard *turnGuard) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.active = guard
+}
+
+// loadConversation returns the conversation to resume, if there is a store
+func (a *InteractiveAgent) loadConversation() ([]Message, error) {
+	if a.store == nil {
+		return []Message{}, nil
+	}
+
+	conversation, err := a.store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load conversation: %w", err)
+	}
+	if conversation == nil {
+		conversation = []Message{}
+	}
+	return conversation, nil
+}
+
+// saveConversation persists the conversation, warning rather than failing so
+// the session can carry on
+func (a *InteractiveAgent) saveConversation(conversation []Message) {
+	if a.store == nil {
+		return
+	}
+	if err := a.store.Save(conversation); err != nil {
+		fmt.Fprintf(a.out, "\u001b[93m⚠️  Could not save the conversation: %v\u001b[0m\n", err)
+	}
+}
+
+// stopTurn explains why the agent stopped working on the current request
+func (a *InteractiveAgent) stopTurn(result *TurnResult, reason string) {
+	result.Stopped = reason
+	fmt.Fprintf(a.out, "\u001b[93m⚠️  Stopped working on this request: %s.\u001b[0m\n", reason)
+	fmt.Fprintln(a.out, "   Check the tool results above, then rephrase or try again.")
+}
+
+// generateResponse asks the LLM for the next reply and displays it, streaming
+// tokens to the console as they arrive when the provider supports it
+func (a *InteractiveAgent) generateResponse(ctx context.Context, conversation []Message) (*Response, error) {
+	streamer, ok := a.llmProvider.(StreamingLLMProvider)
+	if !ok {
+		response, err := a.llmProvider.GenerateResponse(ctx, conversation)
+		if err != nil {
+			return nil, err
+		}
+		// Display assistant response if there's text content
+		if response.Content != "" {
+			fmt.Fprintf(a.out, "\u001b[93mFitbit Agent\u001b[0m: %s\n", response.Content)
+		}
+		if response.Provider != "" {
+			fmt.Fprintf(a.out, "\u001b[90m   ↳ answered by %s\u001b[0m\n", response.Provider)
 		}
+		return response, nil
 	}
 
-	return false
+	started := false
+	response, err := streamer.GenerateResponseStream(ctx, conversation, func(token string) {
+		if !started {
+			fmt.Fprint(a.out, "\u001b[93mFitbit Agent\u001b[0m: ")
+			started = true
+		}
+		fmt.Fprint(a.out, token)
+	})
+	if started {
+		fmt.Fprintln(a.out)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Report which provider answered when it may vary between turns
+	if response.Provider != "" {
+		fmt.Fprintf(a.out, "\u001b[90m   ↳ answered by %s\u001b[0m\n", response.Provider)
+	}
+	return response, nil
+}
+
+// executeTools runs the tool calls and returns their results in call order.
+// Side-effecting calls are confirmed with the user first. Up to maxParallel
+// calls run at once; exclusive tools run on their own. Follow-ups requested by
+// the tools run afterwards, one at a time.
+func (a *InteractiveAgent) executeTools(ctx context.Context, toolCalls []ToolCall) []ToolResult {
+	results := make([]ToolResult, len(toolCalls))
+	followUps := make([]*FollowUpError, len(toolCalls))
+	slots := make(chan struct{}, a.maxParallel)
+	var wg sync.WaitGroup
+
+	declined := a.confirmCalls(ctx, toolCalls)
+	for i, toolCall := range toolCalls {
+		if result, ok := declined[i]; ok {
+			results[i] = result
+			continue
+		}
+		if a.isExclusive(toolCall) {
+			wg.Wait()
+			results[i], followUps[i] = a.executeTool(ctx, toolCall)
+			continue
+		}
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, toolCall ToolCall) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i], followUps[i] = a.executeTool(ctx, toolCall)
+		}(i, toolCall)
+	}
+
+	wg.Wait()
+	a.runFollowUps(ctx, toolCalls, results, followUps)
+	return results
+}
+
+// runFollowUps runs each requested follow-up once and retries the calls that
+// asked for it, replacing their results
+func (a *InteractiveAgent) runFollowUps(ctx context.Context, toolCalls []ToolCall, results []ToolResult, followUps []*FollowUpError) {
+	outcomes := map[string]ToolResult{}
+
+	for i, followUp := range followUps {
+		if followUp == nil {
+			continue
+		}
+
+		outcome, ran := outcomes[followUp.key()]
+		if !ran {
+			fmt.Fprintf(a.out, "\u001b[96m🔗 %s, running %s first\u001b[0m\n", followUp.Reason, followUp.Tool)
+
+			var nested *FollowUpError
+			outcome, nested = a.executeTool(ctx, ToolCall{
+				ID:       toolCalls[i].ID + "_followup",
+				Name:     followUp.Tool,
+				Function: followUp.Tool,
+				Input:    followUp.Input,
+			})
+			if nested != nil {
+				outcome = outcome.failed(fmt.Sprintf("Error: %s could not run: %s", followUp.Tool, nested.Error()))
+			}
+			outcomes[followUp.key()] = outcome
+		}
+
+		if outcome.IsError || !followUp.RetryOriginal {
+			results[i] = results[i].failed(fmt.Sprintf("Error: %s. %s", followUp.Reason, outcome.Content))
+			continue
+		}
+
+		retry, again := a.executeTool(ctx, toolCalls[i])
+		if again != nil {
+			retry = retry.failed(fmt.Sprintf("Error: %s still failed after running %s: %s", toolCalls[i].Name, followUp.Tool, again.Reason))
+		}
+		if !ran {
+			retry.Content = outcome.Content + "\n\n" + retry.Content
+		}
+		results[i] = retry
+	}
+}
+
+// isExclusive reports whether the called tool must run on its own
+func (a *InteractiveAgent) isExclusive(toolCall ToolCall) bool {
+	tool, found := a.toolRegistry.GetTool(toolCall.Name)
+	if !found {
+		return false
+	}
+	exclusive, ok := AsTool[ExclusiveTool](tool)
+	return ok && exclusive.Exclusive()
 }
 
-func (a *InteractiveAgent) executeTool(ctx context.Context, toolCall ToolCall) string {
+// executeTool validates and runs a single tool call. A follow-up requested by
+// the tool is returned alongside its error result.
+func (a *InteractiveAgent) executeTool(ctx context.Context, toolCall ToolCall) (ToolResult, *FollowUpError) {
+	result := ToolResult{CallID: toolCall.ID, Name: toolCall.Name}
+
 	tool, found := a.toolRegistry.GetTool(toolCall.Name)
 	if !found {
-		return fmt.Sprintf("Error: tool '%s' not found", toolCall.Name)
+		return result.failed(fmt.Sprintf("Error: tool '%s' not found", toolCall.Name)), nil
+	}
+
+	if toolCall.ParseError != "" {
+		return result.failed(invalidArgumentsResult(tool, toolCall)), nil
+	}
+
+	input, err := a.toolRegistry.ValidateInput(toolCall.Name, toolCall.Input)
+	if err != nil {
+		return result.failed(fmt.Sprintf("Error: invalid input for tool '%s': %s. Fix these fields and call the tool again.", toolCall.Name, err.Error())), nil
 	}
 
-	fmt.Printf("\u001b[92mtool\u001b[0m: %s(%s)\n", toolCall.Name, string(toolCall.Input))
+	fmt.Fprintf(a.out, "\u001b[92mtool\u001b[0m: %s(%s)\n", toolCall.Name, string(input))
 
-	result, err := tool.Execute(ctx, toolCall.Input)
+	// Changes are journaled even if the call fails part way through
+	toolCtx, undo := withUndoRecorder(ctx)
+	output, err := tool.Execute(toolCtx, input)
+	a.journalCall(tool, input, undo)
 	if err != nil {
-		return fmt.Sprintf("Error executing tool '%s': %s", toolCall.Name, err.Error())
+		var followUp *FollowUpError
+		if errors.As(err, &followUp) {
+			return result.failed(fmt.Sprintf("Error: %s", followUp.Error())), followUp
+		}
+		return result.failed(fmt.Sprintf("Error executing tool '%s': %s", toolCall.Name, err.Error())), nil
 	}
 
+	result.Content = output
+	return result, nil
+}
+
+// ExecuteTool runs a tool directly, without the LLM and without asking for
+// confirmation. Its input is validated and any changes are journaled as usual.
+func (a *InteractiveAgent) ExecuteTool(ctx context.Context, name string, input json.RawMessage) ToolResult {
+	result, _ := a.executeTool(ctx, ToolCall{ID: "direct", Name: name, Input: input})
 	return result
 }
+
+// journalCall records the changes made by a tool call so they can be undone
+func (a *InteractiveAgent) journalCall(tool Tool, input json.RawMessage, undo *undoRecorder) {
+	if len(undo.steps) == 0 {
+		return
+	}
+
+	action := Action{Tool: tool.Name(), Time: time.Now(), Steps: undo.steps}
+	if writer, ok := AsTool[SideEffectTool](tool); ok {
+		action.Preview, _ = writer.Preview(input)
+	}
+	a.journal.Record(action)
+}
+
+// failed marks the result as an error with the given message
+func (r ToolResult) failed(message string) ToolResult {
+	r.Content = message
+	r.IsError = true
+	return r
+}
+
+// invalidArgumentsResult asks the LLM to resend a tool call whose arguments
+// could not be parsed, showing the schema they must follow
+func invalidArgumentsResult(tool Tool, toolCall ToolCall) string {
+	schema, _ := json.MarshalIndent(tool.InputSchema(), "", "  ")
+	return fmt.Sprintf("Error: the arguments for tool '%s' are not valid JSON (%s). "+
+		"Call the tool again with complete, valid JSON arguments matching this schema:\n%s",
+		toolCall.Name, toolCall.ParseError, schema)
+}
+
+// hasParseErrors reports whether any tool call has unparseable arguments
+func hasParseErrors(toolCalls []ToolCall) bool {
+	for _, toolCall := range toolCalls {
+		if toolCall.ParseError != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,539 @@
+package agent_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+	"github.com/vhbfernandes/fitbit-agent/pkg/llm"
+	"github.com/vhbfernandes/fitbit-agent/pkg/registry"
+)
+
+// scriptedInput replays user input lines and then reports end of input
+type scriptedInput struct {
+	lines []string
+}
+
+func (s *scriptedInput) GetInput(ctx context.Context) (string, bool) {
+	if len(s.lines) == 0 {
+		return "", false
+	}
+	line := s.lines[0]
+	s.lines = s.lines[1:]
+	return line, true
+}
+
+// recordingTool records every input it is executed with
+type recordingTool struct {
+	inputs []string
+	mu     sync.Mutex
+}
+
+func (t *recordingTool) Name() string        { return "record_meal" }
+func (t *recordingTool) Description() string { return "Records a meal" }
+func (t *recordingTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"meal_type": map[string]interface{}{"type": "string", "enum": []string{"breakfast", "lunch"}},
+		},
+		"required": []string{"meal_type"},
+	}
+}
+
+func (t *recordingTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inputs = append(t.inputs, string(input))
+	return "recorded breakfast", nil
+}
+
+func parseScript(t *testing.T, script string) *llm.Script {
+	t.Helper()
+	var s llm.Script
+	if err := json.Unmarshal([]byte(script), &s); err != nil {
+		t.Fatalf("invalid test script: %v", err)
+	}
+	return &s
+}
+
+func newTestAgent(provider agent.LLMProvider, tool agent.Tool, opts agent.Options, lines ...string) *agent.InteractiveAgent {
+	toolRegistry := registry.NewDefaultToolRegistry()
+	if tool != nil {
+		toolRegistry.RegisterTool(tool)
+	}
+	return agent.NewInteractiveAgent(provider, toolRegistry, &scriptedInput{lines: lines}, opts)
+}
+
+func TestInteractiveAgentRun(t *testing.T) {
+	testCases := []struct {
+		name      string
+		script    string
+		input     []string
+		opts      agent.Options
+		wantErr   bool
+		wantCalls int
+	}{
+		{
+			name: "Tool call is executed and its result sent back",
+			script: `{"turns": [
+				{"expect": {"message_count": 1, "contains": ["eggs"]},
+				 "content": "Logging it now",
+				 "tool_calls": [{"name": "record_meal", "input": {"meal_type": "breakfast"}}]},
+				{"expect": {"last_role": "tool", "contains": ["recorded breakfast"]},
+				 "content": "Your breakfast is logged!"}
+			]}`,
+			input:     []string{"I had eggs for breakfast"},
+			wantCalls: 1,
+		},
+		{
+			name: "Independent tool calls run in parallel and answer their call IDs",
+			script: `{"turns": [
+				{"tool_calls": [
+					{"name": "record_meal", "input": {"meal_type": "breakfast"}},
+					{"name": "record_meal", "input": {"meal_type": "lunch"}}
+				]},
+				{"expect": {"message_count": 4, "last_role": "tool"}, "content": "Both meals are logged!"}
+			]}`,
+			input:     []string{"I had eggs for breakfast and salad for lunch"},
+			opts:      agent.Options{MaxParallelTools: 2},
+			wantCalls: 2,
+		},
+		{
+			name: "Unknown tool error is reported to the LLM",
+			script: `{"turns": [
+				{"tool_calls": [{"name": "missing_tool"}]},
+				{"expect": {"contains": ["tool 'missing_tool' not found"]}, "content": "Sorry about that"}
+			]}`,
+			input: []string{"log my lunch"},
+		},
+		{
+			name: "Recoverable provider error keeps the session going",
+			script: `{"turns": [
+				{"error": "quota_exceeded"},
+				{"expect": {"contains": ["try again"]}, "content": "Hello!"}
+			]}`,
+			input: []string{"hello", "", "try again"},
+		},
+		{
+			name: "Invalid tool input is reported to the LLM",
+			script: `{"turns": [
+				{"tool_calls": [{"name": "record_meal", "input": {"meal_type": "brunch"}}]},
+				{"expect": {"contains": ["meal_type: must be one of breakfast, lunch"]}, "content": "Which meal was it?"}
+			]}`,
+			input: []string{"log my brunch"},
+		},
+		{
+			name: "Malformed tool arguments are repaired",
+			script: `{"turns": [
+				{"content": "TOOL_CALL: record_meal({'meal_type': 'breakfast', foods: [{'name': 'eggs'},]})"},
+				{"expect": {"last_role": "tool", "contains": ["recorded breakfast"]}, "content": "Logged!"}
+			]}`,
+			input:     []string{"I had eggs for breakfast"},
+			wantCalls: 1,
+		},
+		{
+			name: "Unrepairable tool arguments are re-asked with the schema",
+			script: `{"turns": [
+				{"content": "TOOL_CALL: record_meal({\"meal_type\" \"breakfast\"})"},
+				{"expect": {"contains": ["not valid JSON", "\"type\": \"object\""]},
+				 "tool_calls": [{"name": "record_meal", "input": {"meal_type": "breakfast"}}]},
+				{"content": "Logged!"}
+			]}`,
+			input:     []string{"I had eggs for breakfast"},
+			opts:      agent.Options{MaxRepairAttempts: 1},
+			wantCalls: 1,
+		},
+		{
+			name: "Re-asking stops after the repair attempts",
+			script: `{"turns": [
+				{"content": "TOOL_CALL: record_meal({\"meal_type\" \"breakfast\"})"},
+				{"content": "TOOL_CALL: record_meal({\"meal_type\" \"lunch\"})"}
+			]}`,
+			input: []string{"I had eggs for breakfast"},
+			opts:  agent.Options{MaxRepairAttempts: 1},
+		},
+		{
+			name: "Identical repeated tool calls stop the turn",
+			script: `{"turns": [
+				{"tool_calls": [{"name": "record_meal", "input": {"meal_type": "breakfast"}}]},
+				{"tool_calls": [{"name": "record_meal", "input": {"meal_type": "breakfast"}}]},
+				{"tool_calls": [{"name": "record_meal", "input": {"meal_type": "breakfast"}}]}
+			]}`,
+			input:     []string{"I had eggs for breakfast"},
+			wantCalls: 2,
+		},
+		{
+			name: "Step limit stops the turn",
+			script: `{"turns": [
+				{"tool_calls": [{"name": "record_meal", "input": {"meal_type": "breakfast"}}]},
+				{"tool_calls": [{"name": "record_meal", "input": {"meal_type": "lunch"}}]},
+				{"expect": {"message_count": 6, "last_role": "user", "contains": ["and dinner"]}, "content": "What did you have?"}
+			]}`,
+			input:     []string{"I had eggs for breakfast", "and dinner"},
+			opts:      agent.Options{MaxStepsPerTurn: 2},
+			wantCalls: 2,
+		},
+		{
+			name: "Slash commands are handled without the LLM",
+			script: `{"turns": [
+				{"content": "Noted"},
+				{"expect": {"message_count": 1, "contains": ["hello"]}, "content": "Hi!"}
+			]}`,
+			input: []string{"/help", "/tools", "I had eggs", "/undo", "/reset", "/history", "hello", "/quit", "never sent"},
+		},
+		{
+			name:    "Non-recoverable provider error stops the agent",
+			script:  `{"turns": [{"error": "invalid_request"}]}`,
+			input:   []string{"hello"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := llm.NewScriptedProvider(parseScript(t, tc.script))
+			tool := &recordingTool{}
+
+			err := newTestAgent(provider, tool, tc.opts, tc.input...).Run(context.Background())
+
+			if tc.wantErr && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+			if failures := provider.Failures(); len(failures) > 0 {
+				t.Errorf("Script expectations failed:\n%s", strings.Join(failures, "\n"))
+			}
+			if remaining := provider.Remaining(); remaining != 0 {
+				t.Errorf("Expected script to be fully replayed, %d turns left", remaining)
+			}
+			for _, conversation := range provider.Conversations() {
+				for _, msg := range conversation {
+					if result, ok := msg.Content.(agent.ToolResult); ok && !strings.HasPrefix(result.CallID, "call_") {
+						t.Errorf("Expected tool result to carry its call ID, got %+v", result)
+					}
+				}
+			}
+			if len(tool.inputs) != tc.wantCalls {
+				t.Errorf("Expected %d tool calls but got %d", tc.wantCalls, len(tool.inputs))
+			}
+		})
+	}
+}
+
+// loginTool marks the session as logged in
+type loginTool struct {
+	loggedIn bool
+	calls    int
+}
+
+func (t *loginTool) Name() string        { return "login" }
+func (t *loginTool) Description() string { return "Logs in" }
+func (t *loginTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+
+func (t *loginTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+	t.calls++
+	t.loggedIn = true
+	return "logged in", nil
+}
+
+// gatedTool requires a login follow-up before it records anything
+type gatedTool struct {
+	recordingTool
+	login *loginTool
+}
+
+func (t *gatedTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+	if !t.login.loggedIn {
+		return "", &agent.FollowUpError{Reason: "login required", Tool: "login", Input: json.RawMessage("{}"), RetryOriginal: true}
+	}
+	return t.recordingTool.Execute(ctx, input)
+}
+
+func TestInteractiveAgentFollowUp(t *testing.T) {
+	provider := llm.NewScriptedProvider(parseScript(t, `{"turns": [
+		{"tool_calls": [
+			{"name": "record_meal", "input": {"meal_type": "breakfast"}},
+			{"name": "record_meal", "input": {"meal_type": "lunch"}}
+		]},
+		{"expect": {"message_count": 4, "contains": ["recorded breakfast"]}, "content": "Both meals are logged!"}
+	]}`))
+	login := &loginTool{}
+	tool := &gatedTool{login: login}
+
+	toolRegistry := registry.NewDefaultToolRegistry()
+	toolRegistry.RegisterTool(login)
+	toolRegistry.RegisterTool(tool)
+	input := &scriptedInput{lines: []string{"eggs for breakfast and salad for lunch"}}
+
+	if err := agent.NewInteractiveAgent(provider, toolRegistry, input, agent.Options{}).Run(context.Background()); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if failures := provider.Failures(); len(failures) > 0 {
+		t.Errorf("Script expectations failed:\n%s", strings.Join(failures, "\n"))
+	}
+	if login.calls != 1 {
+		t.Errorf("Expected the login follow-up to run once but it ran %d times", login.calls)
+	}
+	if len(tool.inputs) != 2 {
+		t.Errorf("Expected both meals to be retried after login, got %d calls", len(tool.inputs))
+	}
+}
+
+// hangingTool interrupts the agent and then waits until it is cancelled
+type hangingTool struct {
+	recordingTool
+	agent       *agent.InteractiveAgent
+	interrupted bool
+}
+
+func (t *hangingTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+	t.interrupted = t.agent.Interrupt()
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestInteractiveAgentInterrupt(t *testing.T) {
+	provider := llm.NewScriptedProvider(parseScript(t, `{"turns": [
+		{"tool_calls": [{"name": "record_meal", "input": {"meal_type": "breakfast"}}]},
+		{"expect": {"contains": ["never mind"]}, "content": "No problem!"}
+	]}`))
+	tool := &hangingTool{}
+	tool.agent = newTestAgent(provider, tool, agent.Options{}, "I had eggs for breakfast", "never mind")
+
+	if err := tool.agent.Run(context.Background()); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if failures := provider.Failures(); len(failures) > 0 {
+		t.Errorf("Script expectations failed:\n%s", strings.Join(failures, "\n"))
+	}
+	if !tool.interrupted {
+		t.Errorf("Expected the running request to be interrupted")
+	}
+	if tool.agent.Interrupt() {
+		t.Errorf("Expected nothing to interrupt once the agent is idle")
+	}
+}
+
+// memoryStore keeps a conversation in memory in place of a session file
+type memoryStore struct {
+	conversation []agent.Message
+	saves        int
+}
+
+func (s *memoryStore) Load() ([]agent.Message, error) { return s.conversation, nil }
+
+func (s *memoryStore) Save(conversation []agent.Message) error {
+	s.conversation = conversation
+	s.saves++
+	return nil
+}
+
+func TestInteractiveAgentResume(t *testing.T) {
+	provider := llm.NewScriptedProvider(parseScript(t, `{"turns": [
+		{"expect": {"message_count": 3, "contains": ["same as yesterday"]}, "content": "Logging oatmeal again"}
+	]}`))
+	store := &memoryStore{conversation: []agent.Message{
+		{Role: "user", Content: "I had oatmeal for breakfast"},
+		{Role: "assistant", Content: "Logged your oatmeal"},
+	}}
+
+	toolRegistry := registry.NewDefaultToolRegistry()
+	input := &scriptedInput{lines: []string{"same as yesterday"}}
+	if err := agent.NewInteractiveAgent(provider, toolRegistry, input, agent.Options{Store: store}).Run(context.Background()); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if failures := provider.Failures(); len(failures) > 0 {
+		t.Errorf("Script expectations failed:\n%s", strings.Join(failures, "\n"))
+	}
+	if len(store.conversation) != 4 {
+		t.Errorf("Expected the new turn to be saved, got %d messages", len(store.conversation))
+	}
+}
+
+// writeTool is a recordingTool that needs confirming before it runs
+type writeTool struct {
+	recordingTool
+	previewErr error
+}
+
+func (t *writeTool) SideEffects() bool { return true }
+
+func (t *writeTool) Preview(input json.RawMessage) (*agent.ActionPreview, error) {
+	if t.previewErr != nil {
+		return nil, t.previewErr
+	}
+	return &agent.ActionPreview{
+		Action:   "Record",
+		Dates:    []string{"2025-08-14"},
+		MealType: "breakfast",
+		Foods:    []agent.PreviewFood{{Name: "eggs", Quantity: 2, Unit: "large", Calories: 140}},
+	}, nil
+}
+
+func TestInteractiveAgentConfirm(t *testing.T) {
+	script := `{"turns": [
+		{"tool_calls": [{"name": "record_meal", "input": {"meal_type": "breakfast"}}]},
+		{"expect": {"last_role": "tool", "contains": ["%s"]}, "content": "Done"}
+	]}`
+
+	testCases := []struct {
+		name        string
+		answers     []string
+		opts        agent.Options
+		previewErr  error
+		wantContent string
+		wantCalls   int
+	}{
+		{
+			name:        "Approved call runs",
+			answers:     []string{"y"},
+			wantContent: "recorded breakfast",
+			wantCalls:   1,
+		},
+		{
+			name:        "Declined call does not run",
+			answers:     []string{"", "n"},
+			wantContent: "the user declined this action",
+		},
+		{
+			name:        "Edit is sent back to the LLM",
+			answers:     []string{"e", "it was three eggs"},
+			wantContent: "it was three eggs",
+		},
+		{
+			name:        "Any other answer is taken as the edit",
+			answers:     []string{"no, only one egg"},
+			wantContent: "no, only one egg",
+		},
+		{
+			name:        "Small meals are auto-approved",
+			opts:        agent.Options{AutoApproveCalories: 200},
+			wantContent: "recorded breakfast",
+			wantCalls:   1,
+		},
+		{
+			name:        "Calls without a preview are still confirmed",
+			answers:     []string{"n"},
+			opts:        agent.Options{AutoApproveCalories: 200},
+			previewErr:  fmt.Errorf("record_meal crashed while previewing"),
+			wantContent: "the user declined this action",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := llm.NewScriptedProvider(parseScript(t, fmt.Sprintf(script, tc.wantContent)))
+			tool := &writeTool{previewErr: tc.previewErr}
+			lines := append([]string{"I had eggs for breakfast"}, tc.answers...)
+
+			if err := newTestAgent(provider, tool, tc.opts, lines...).Run(context.Background()); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if failures := provider.Failures(); len(failures) > 0 {
+				t.Errorf("Script expectations failed:\n%s", strings.Join(failures, "\n"))
+			}
+			if len(tool.inputs) != tc.wantCalls {
+				t.Errorf("Expected %d tool calls but got %d", tc.wantCalls, len(tool.inputs))
+			}
+		})
+	}
+}
+
+// undoableTool is a writeTool whose records can be undone
+type undoableTool struct {
+	writeTool
+	undone []string
+}
+
+func (t *undoableTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+	agent.RecordUndo(ctx, string(input))
+	return t.writeTool.Execute(ctx, input)
+}
+
+func (t *undoableTool) Undo(ctx context.Context, step json.RawMessage) error {
+	var input string
+	if err := json.Unmarshal(step, &input); err != nil {
+		return err
+	}
+	t.undone = append(t.undone, input)
+	return nil
+}
+
+func TestInteractiveAgentUndo(t *testing.T) {
+	provider := llm.NewScriptedProvider(parseScript(t, `{"turns": [
+		{"tool_calls": [{"name": "record_meal", "input": {"meal_type": "breakfast"}}]},
+		{"content": "Logged!"},
+		{"expect": {"message_count": 6, "contains": ["lunch instead"]}, "content": "Which foods?"}
+	]}`))
+	tool := &undoableTool{}
+	journal := agent.NewJournal()
+	opts := agent.Options{Journal: journal, AutoApproveCalories: 1000}
+
+	if err := newTestAgent(provider, tool, opts, "I had eggs for breakfast", "/undo", "/undo", "it was lunch instead").Run(context.Background()); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if failures := provider.Failures(); len(failures) > 0 {
+		t.Errorf("Script expectations failed:\n%s", strings.Join(failures, "\n"))
+	}
+	if len(tool.undone) != 1 || tool.undone[0] != `{"meal_type":"breakfast"}` {
+		t.Errorf("Expected the breakfast to be undone once, got %v", tool.undone)
+	}
+	if actions := journal.Last(1); len(actions) != 0 {
+		t.Errorf("Expected the journal to be empty after undo, got %v", actions)
+	}
+}
+
+func TestInteractiveAgentRunTurn(t *testing.T) {
+	script := `{"turns": [
+		{"tool_calls": [{"name": "record_meal", "input": {"meal_type": "breakfast"}}]},
+		{"content": "All done"}
+	]}`
+
+	testCases := []struct {
+		name         string
+		opts         agent.Options
+		wantActions  int
+		wantDeclined bool
+	}{
+		{
+			name:        "Approved changes are reported as actions",
+			opts:        agent.Options{AutoApprove: true},
+			wantActions: 1,
+		},
+		{
+			name:         "Changes are declined when nobody can confirm them",
+			wantDeclined: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := llm.NewScriptedProvider(parseScript(t, script))
+			tc.opts.Output = io.Discard
+
+			result, err := newTestAgent(provider, &undoableTool{}, tc.opts).RunTurn(context.Background(), "I had eggs for breakfast")
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if result.Reply != "All done" {
+				t.Errorf("Expected the last reply but got %q", result.Reply)
+			}
+			if len(result.Actions) != tc.wantActions {
+				t.Errorf("Expected %d actions but got %d", tc.wantActions, len(result.Actions))
+			}
+			if len(result.ToolResults) != 1 || result.ToolResults[0].Declined != tc.wantDeclined {
+				t.Errorf("Expected declined=%v, got %+v", tc.wantDeclined, result.ToolResults)
+			}
+		})
+	}
+}
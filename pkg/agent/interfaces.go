@@ -3,11 +3,16 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"time"
 )
 
 // Agent represents the main agent interface
 type Agent interface {
 	Run(ctx context.Context) error
+	// RunTurn works on a single message without starting the chat loop
+	RunTurn(ctx context.Context, userInput string) (*TurnResult, error)
+	// Interrupt cancels the request in progress, reporting false if there is none
+	Interrupt() bool
 }
 
 // LLMProvider represents any LLM service (Claude, OpenAI, etc.)
@@ -16,12 +21,31 @@ type LLMProvider interface {
 	Name() string
 }
 
+// StreamingLLMProvider is an LLMProvider that can emit tokens as they are generated.
+// onToken is called for every chunk of text; the returned Response holds the full
+// content and any tool calls parsed once the stream is complete.
+type StreamingLLMProvider interface {
+	LLMProvider
+	GenerateResponseStream(ctx context.Context, conversation []Message, onToken func(token string)) (*Response, error)
+}
+
+// RecoverableError is implemented by provider errors that should not end the
+// session, such as quota or availability problems
+type RecoverableError interface {
+	error
+	Recoverable() bool
+	Suggestion() string
+}
+
 // ToolRegistry manages available tools
 type ToolRegistry interface {
 	GetTool(name string) (Tool, bool)
 	GetAllTools() []Tool
 	RegisterTool(tool Tool)
 	GetToolDefinitions() []ToolDefinition
+	// ValidateInput checks input against the tool's InputSchema and returns it
+	// with values coerced to the declared types
+	ValidateInput(name string, input json.RawMessage) (json.RawMessage, error)
 }
 
 // Tool represents a single executable tool
@@ -32,24 +56,130 @@ type Tool interface {
 	Execute(ctx context.Context, input json.RawMessage) (string, error)
 }
 
-// Message represents a conversation message
+// ExclusiveTool is implemented by tools that must not run at the same time as
+// other tool calls, such as the interactive Fitbit login
+type ExclusiveTool interface {
+	Tool
+	Exclusive() bool
+}
+
+// TimedTool is implemented by tools that set their own time limit, such as
+// plugins with a timeout in their manifest. It replaces the global tool
+// timeout; zero means no limit.
+type TimedTool interface {
+	Tool
+	Timeout() time.Duration
+}
+
+// SideEffectTool is implemented by tools that change data outside the agent,
+// such as logging to Fitbit. Their calls are previewed and confirmed first.
+type SideEffectTool interface {
+	Tool
+	SideEffects() bool
+	// Preview describes what a call with the given input would change
+	Preview(input json.RawMessage) (*ActionPreview, error)
+}
+
+// WrappedTool is implemented by tools that add behaviour around another tool,
+// such as the registry's middleware
+type WrappedTool interface {
+	Tool
+	Unwrap() Tool
+}
+
+// AsTool finds the first tool in the chain of wrapped tools that is a T, the
+// way errors.As does for errors. Optional interfaces such as SideEffectTool are
+// checked with it so wrapping a tool does not hide them.
+func AsTool[T Tool](tool Tool) (T, bool) {
+	for tool != nil {
+		if t, ok := tool.(T); ok {
+			return t, true
+		}
+		wrapped, ok := tool.(WrappedTool)
+		if !ok {
+			break
+		}
+		tool = wrapped.Unwrap()
+	}
+
+	var zero T
+	return zero, false
+}
+
+// Message represents a conversation message. Messages with role "tool" carry a
+// ToolResult as their content.
 type Message struct {
-	Role    string      `json:"role"`
-	Content interface{} `json:"content"`
+	Role      string      `json:"role"`
+	Content   interface{} `json:"content"`
+	ToolCalls []ToolCall  `json:"tool_calls,omitempty"` // calls requested by an assistant message
+}
+
+// ToolResult is the outcome of a tool call, matched to the call by CallID
+type ToolResult struct {
+	CallID   string `json:"call_id"`
+	Name     string `json:"name"`
+	Content  string `json:"content"`
+	IsError  bool   `json:"is_error,omitempty"`
+	Declined bool   `json:"declined,omitempty"` // the user did not approve the call
+}
+
+// UnmarshalJSON restores the ToolResult content of tool messages, so a saved
+// conversation reads back the way it was written
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Role      string          `json:"role"`
+		Content   json.RawMessage `json:"content"`
+		ToolCalls []ToolCall      `json:"tool_calls,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	m.Role = raw.Role
+	m.ToolCalls = raw.ToolCalls
+	m.Content = nil
+	if len(raw.Content) == 0 {
+		return nil
+	}
+
+	if raw.Role == "tool" {
+		var result ToolResult
+		if err := json.Unmarshal(raw.Content, &result); err != nil {
+			return err
+		}
+		m.Content = result
+		return nil
+	}
+	return json.Unmarshal(raw.Content, &m.Content)
+}
+
+// String returns the result content, so tool messages print like plain ones
+func (r ToolResult) String() string {
+	return r.Content
+}
+
+// TurnResult is the outcome of the agent working on a single user message
+type TurnResult struct {
+	Reply       string       `json:"reply"`                  // last text the LLM answered with
+	ToolResults []ToolResult `json:"tool_results,omitempty"` // results of every tool call, in order
+	Actions     []Action     `json:"actions,omitempty"`      // changes made, which can be undone
+	Stopped     string       `json:"stopped,omitempty"`      // why the agent stopped before finishing
 }
 
 // Response represents an LLM response
 type Response struct {
 	Content   string     `json:"content"`
 	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Provider  string     `json:"provider,omitempty"` // set when the answering provider may vary
 }
 
 // ToolCall represents a tool invocation request from the LLM
 type ToolCall struct {
-	ID       string          `json:"id"`
-	Name     string          `json:"name"`
-	Function string          `json:"function"`
-	Input    json.RawMessage `json:"input"`
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	Function   string          `json:"function"`
+	Input      json.RawMessage `json:"input"`
+	ParseError string          `json:"parse_error,omitempty"` // set when the arguments were not valid JSON
 }
 
 // ToolDefinition represents the schema for a tool
@@ -59,7 +189,14 @@ type ToolDefinition struct {
 	InputSchema map[string]interface{} `json:"input_schema"`
 }
 
-// UserInputProvider provides user input functionality
+// ConversationStore persists the conversation so it can be resumed in a later session
+type ConversationStore interface {
+	Load() ([]Message, error)
+	Save(conversation []Message) error
+}
+
+// UserInputProvider provides user input functionality. GetInput returns false
+// at the end of input or once ctx is cancelled.
 type UserInputProvider interface {
-	GetInput() (string, bool)
+	GetInput(ctx context.Context) (string, bool)
 }
@@ -0,0 +1,157 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UndoableTool is a SideEffectTool that can reverse the changes it made. While
+// executing it calls RecordUndo with whatever it needs to reverse each change.
+type UndoableTool interface {
+	SideEffectTool
+	Undo(ctx context.Context, step json.RawMessage) error
+}
+
+// Action is a side-effecting tool call recorded in the journal so it can be undone
+type Action struct {
+	Tool    string            `json:"tool"`
+	Time    time.Time         `json:"time"`
+	Preview *ActionPreview    `json:"preview,omitempty"`
+	Steps   []json.RawMessage `json:"steps"` // one entry per change, in the order they were made
+}
+
+// Summary describes the action in a few words
+func (a Action) Summary() string {
+	if a.Preview == nil {
+		return a.Tool
+	}
+
+	summary := a.Preview.Action
+	if a.Preview.MealType != "" {
+		summary += " " + a.Preview.MealType
+	}
+	if len(a.Preview.Dates) > 0 {
+		summary += " on " + strings.Join(a.Preview.Dates, ", ")
+	}
+	if len(a.Preview.Foods) > 0 {
+		summary += fmt.Sprintf(" (~%.0f cal)", a.Preview.TotalCalories())
+	}
+	return summary
+}
+
+// Journal records the side-effecting actions taken by the agent, most recent last
+type Journal struct {
+	actions []Action
+	mu      sync.Mutex
+}
+
+// NewJournal creates an empty journal
+func NewJournal() *Journal {
+	return &Journal{}
+}
+
+// Record adds an action to the journal
+func (j *Journal) Record(action Action) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.actions = append(j.actions, action)
+}
+
+// Len returns the number of actions in the journal
+func (j *Journal) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.actions)
+}
+
+// Since returns the actions recorded after the first n, oldest first
+func (j *Journal) Since(n int) []Action {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if n >= len(j.actions) {
+		return nil
+	}
+	return append([]Action{}, j.actions[n:]...)
+}
+
+// Last returns up to n of the most recent actions, most recent first
+func (j *Journal) Last(n int) []Action {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var actions []Action
+	for i := len(j.actions) - 1; i >= 0 && len(actions) < n; i-- {
+		actions = append(actions, j.actions[i])
+	}
+	return actions
+}
+
+// Undo reverses up to count of the most recent actions, most recent first, and
+// returns the ones that were undone. It stops at the first change that cannot
+// be reversed, keeping what is left of that action in the journal.
+func (j *Journal) Undo(ctx context.Context, registry ToolRegistry, count int) ([]Action, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var undone []Action
+	for len(undone) < count && len(j.actions) > 0 {
+		action := &j.actions[len(j.actions)-1]
+
+		tool, found := registry.GetTool(action.Tool)
+		undoable, ok := AsTool[UndoableTool](tool)
+		if !found || !ok {
+			return undone, fmt.Errorf("%s cannot be undone", action.Summary())
+		}
+
+		for len(action.Steps) > 0 {
+			last := len(action.Steps) - 1
+			if err := undoable.Undo(ctx, action.Steps[last]); err != nil {
+				return undone, fmt.Errorf("failed to undo %s: %w", action.Summary(), err)
+			}
+			action.Steps = action.Steps[:last]
+		}
+
+		undone = append(undone, *action)
+		j.actions = j.actions[:len(j.actions)-1]
+	}
+	return undone, nil
+}
+
+// undoRecorderKey is the context key under which tools find the undo recorder
+type undoRecorderKey struct{}
+
+// undoRecorder collects the undo steps recorded during one tool call
+type undoRecorder struct {
+	steps []json.RawMessage
+	mu    sync.Mutex
+}
+
+// withUndoRecorder returns a context that collects the undo steps of a tool call
+func withUndoRecorder(ctx context.Context) (context.Context, *undoRecorder) {
+	recorder := &undoRecorder{}
+	return context.WithValue(ctx, undoRecorderKey{}, recorder), recorder
+}
+
+// RecordUndo records what an UndoableTool needs to reverse a change it just made.
+// step is marshalled to JSON and later passed back to the tool's Undo. It does
+// nothing when the tool is not run by the agent.
+func RecordUndo(ctx context.Context, step interface{}) {
+	recorder, ok := ctx.Value(undoRecorderKey{}).(*undoRecorder)
+	if !ok {
+		return
+	}
+
+	data, err := json.Marshal(step)
+	if err != nil {
+		return
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.steps = append(recorder.steps, data)
+}
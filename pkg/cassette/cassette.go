@@ -0,0 +1,118 @@
+package cassette
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// redacted replaces secrets before interactions are written to disk
+const redacted = "REDACTED"
+
+// Interaction is a single recorded HTTP request and its response
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the request half of an interaction
+type RecordedRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the response half of an interaction
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Metadata describes the session a cassette was recorded from
+type Metadata struct {
+	RecordedAt time.Time         `json:"recorded_at"`
+	Env        map[string]string `json:"env,omitempty"`
+}
+
+// metadataFile holds the cassette metadata inside the cassette directory
+const metadataFile = "cassette.json"
+
+// replayEnv lists environment variables restored on replay so tools take the same code paths
+var replayEnv = []string{"FITBIT_USER_ID", "LLM_PROVIDER", "LLM_MODEL", "GEMINI_MODEL"}
+
+// requestKey identifies which recorded interactions may answer a request
+func requestKey(method, rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return method + " " + rawURL
+	}
+	return method + " " + u.Host + u.Path
+}
+
+// redactURL removes API keys passed as query parameters
+func redactURL(u *url.URL) string {
+	clone := *u
+	query := clone.Query()
+	for key := range query {
+		if strings.EqualFold(key, "key") || strings.Contains(strings.ToLower(key), "token") {
+			query.Set(key, redacted)
+		}
+	}
+	clone.RawQuery = query.Encode()
+	return clone.String()
+}
+
+// redactHeaders removes credentials from headers
+func redactHeaders(headers http.Header) http.Header {
+	clone := headers.Clone()
+	for _, key := range []string{"Authorization", "X-Goog-Api-Key", "Cookie", "Set-Cookie"} {
+		if clone.Get(key) != "" {
+			clone.Set(key, redacted)
+		}
+	}
+	return clone
+}
+
+// loadInteractions reads all interactions from dir in recording order
+func loadInteractions(dir string) ([]Interaction, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "[0-9]*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	interactions := make([]Interaction, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		var interaction Interaction
+		if err := json.Unmarshal(data, &interaction); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		interactions = append(interactions, interaction)
+	}
+
+	return interactions, nil
+}
+
+// saveInteraction writes interaction n to dir
+func saveInteraction(dir string, n int, host string, interaction Interaction) error {
+	data, err := json.MarshalIndent(interaction, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal interaction: %w", err)
+	}
+
+	name := fmt.Sprintf("%04d-%s.json", n, strings.ReplaceAll(host, ":", "_"))
+	return os.WriteFile(filepath.Join(dir, name), data, 0600)
+}
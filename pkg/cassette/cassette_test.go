@@ -0,0 +1,109 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"path": "`+r.URL.Path+`"}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir, http.DefaultTransport)
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+
+	client := &http.Client{Transport: recorder}
+	for _, path := range []string{"/first?key=secret", "/second"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("recorded request failed: %v", err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	recorded, err := os.ReadFile(filepath.Join(dir, "0001-"+strings.ReplaceAll(strings.TrimPrefix(server.URL, "http://"), ":", "_")+".json"))
+	if err != nil {
+		t.Fatalf("expected first interaction on disk: %v", err)
+	}
+	if strings.Contains(string(recorded), "secret") {
+		t.Errorf("Expected API key to be redacted, got: %s", recorded)
+	}
+
+	// Replay without the server running
+	server.Close()
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatalf("NewReplayer failed: %v", err)
+	}
+
+	client = &http.Client{Transport: replayer}
+	for _, path := range []string{"/first", "/second"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("replayed request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if !strings.Contains(string(body), path) {
+			t.Errorf("Expected replayed body for %s, got: %s", path, body)
+		}
+	}
+
+	if _, err := client.Get(server.URL + "/first"); err == nil {
+		t.Errorf("Expected error once recorded interactions are used up")
+	}
+}
+
+func TestRecorderRedactsBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"access_token": "access-secret", "refresh_token": "refresh-secret", "user_id": "ABC"}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir, http.DefaultTransport)
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+
+	client := &http.Client{Transport: recorder}
+	form := "client_id=abc&client_secret=client-secret&code=auth-code&grant_type=authorization_code"
+	resp, err := client.Post(server.URL+"/oauth2/token", "application/x-www-form-urlencoded", strings.NewReader(form))
+	if err != nil {
+		t.Fatalf("recorded request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if !strings.Contains(string(body), "access-secret") {
+		t.Errorf("Expected the caller to get the real response, got: %s", body)
+	}
+
+	interactions, err := loadInteractions(dir)
+	if err != nil || len(interactions) != 1 {
+		t.Fatalf("expected one recorded interaction, got %d: %v", len(interactions), err)
+	}
+	recorded := interactions[0].Request.Body + interactions[0].Response.Body
+	for _, secret := range []string{"client-secret", "auth-code", "access-secret", "refresh-secret"} {
+		if strings.Contains(recorded, secret) {
+			t.Errorf("Expected %s to be redacted, got: %s", secret, recorded)
+		}
+	}
+	if !strings.Contains(recorded, "client_id=abc") || !strings.Contains(recorded, `"user_id":"ABC"`) {
+		t.Errorf("Expected values that are not secret to be kept, got: %s", recorded)
+	}
+}
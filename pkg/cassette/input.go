@@ -0,0 +1,70 @@
+package cassette
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+// inputFile holds the user input recorded alongside the HTTP interactions
+const inputFile = "input.jsonl"
+
+// InputRecorder wraps a UserInputProvider and records every line the user types
+type InputRecorder struct {
+	base agent.UserInputProvider
+	path string
+}
+
+// NewInputRecorder creates an input recorder writing to the cassette in dir
+func NewInputRecorder(dir string, base agent.UserInputProvider) *InputRecorder {
+	return &InputRecorder{
+		base: base,
+		path: filepath.Join(dir, inputFile),
+	}
+}
+
+// GetInput reads a line from the wrapped provider and records it
+func (r *InputRecorder) GetInput(ctx context.Context) (string, bool) {
+	line, ok := r.base.GetInput(ctx)
+	if !ok {
+		return line, ok
+	}
+
+	// One JSON string per line keeps arbitrary input unambiguous
+	encoded, _ := json.Marshal(line)
+	if f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600); err == nil {
+		fmt.Fprintf(f, "%s\n", encoded)
+		f.Close()
+	}
+
+	return line, ok
+}
+
+// LoadInput returns the user input recorded in the cassette in dir, if any
+func LoadInput(dir string) ([]string, error) {
+	f, err := os.Open(filepath.Join(dir, inputFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read recorded input: %w", err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line string
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("failed to parse recorded input: %w", err)
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
@@ -0,0 +1,142 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/redact"
+)
+
+// Recorder is an http.RoundTripper that captures every interaction to a cassette directory
+type Recorder struct {
+	dir  string
+	base http.RoundTripper
+	next int
+	mu   sync.Mutex
+}
+
+// NewRecorder creates a recorder writing to dir, sending requests through base
+func NewRecorder(dir string, base http.RoundTripper) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+
+	existing, err := loadInteractions(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("cassette directory %s already contains %d interactions", dir, len(existing))
+	}
+
+	meta := Metadata{RecordedAt: time.Now(), Env: map[string]string{}}
+	for _, key := range replayEnv {
+		if value := os.Getenv(key); value != "" {
+			meta.Env[key] = value
+		}
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal cassette metadata: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, metadataFile), data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write cassette metadata: %w", err)
+	}
+
+	return &Recorder{
+		dir:  dir,
+		base: base,
+	}, nil
+}
+
+// RoundTrip sends the request and records it once the response body has been read
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		reqBody = data
+		req.Body = io.NopCloser(bytes.NewReader(data))
+	}
+
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.next++
+	n := r.next
+	r.mu.Unlock()
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method:  req.Method,
+			URL:     redactURL(req.URL),
+			Headers: redactHeaders(req.Header),
+			Body:    redactBody(req.Header, reqBody),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    redactHeaders(resp.Header),
+		},
+	}
+
+	// Capture the body as the caller reads it so streamed responses still stream
+	resp.Body = &recordingBody{
+		ReadCloser: resp.Body,
+		save: func(body []byte) {
+			interaction.Response.Body = redactBody(resp.Header, body)
+			if err := saveInteraction(r.dir, n, req.URL.Host, interaction); err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️  Failed to record interaction: %v\n", err)
+			}
+		},
+	}
+
+	return resp, nil
+}
+
+// redactBody hides secrets in a form or JSON body, such as the code, client
+// secret and tokens exchanged during the Fitbit login
+func redactBody(headers http.Header, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if strings.HasPrefix(headers.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return redact.Form(string(body))
+	}
+	return redact.Text(string(body))
+}
+
+// recordingBody copies everything read from the response body and saves it on close
+type recordingBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	save func(body []byte)
+	once sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	// Drain what the caller did not read so the recording is complete
+	io.Copy(&b.buf, b.ReadCloser)
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.save(b.buf.Bytes()) })
+	return err
+}
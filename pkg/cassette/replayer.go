@@ -0,0 +1,100 @@
+package cassette
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Replayer is an http.RoundTripper that serves responses from a cassette directory.
+// Requests are matched on method, host and path, in the order they were recorded.
+type Replayer struct {
+	interactions []Interaction
+	used         []bool
+	mu           sync.Mutex
+}
+
+// NewReplayer loads the cassette in dir and restores the environment it was recorded with
+func NewReplayer(dir string) (*Replayer, error) {
+	interactions, err := loadInteractions(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(interactions) == 0 {
+		return nil, fmt.Errorf("no recorded interactions found in %s", dir)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, metadataFile)); err == nil {
+		var meta Metadata
+		if err := json.Unmarshal(data, &meta); err == nil {
+			for key, value := range meta.Env {
+				if os.Getenv(key) == "" {
+					os.Setenv(key, value)
+				}
+			}
+		}
+	}
+
+	// Recorded credentials are redacted, but tools still need a token to make requests
+	if os.Getenv("FITBIT_ACCESS_TOKEN") == "" {
+		os.Setenv("FITBIT_ACCESS_TOKEN", redacted)
+	}
+
+	return &Replayer{
+		interactions: interactions,
+		used:         make([]bool, len(interactions)),
+	}, nil
+}
+
+// RoundTrip returns the next unused recorded response for the request
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	key := requestKey(req.Method, req.URL.String())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.interactions {
+		if r.used[i] || requestKey(interaction.Request.Method, interaction.Request.URL) != key {
+			continue
+		}
+		r.used[i] = true
+
+		recorded := interaction.Response
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+			StatusCode:    recorded.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        recorded.Headers.Clone(),
+			Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+			ContentLength: int64(len(recorded.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("cassette: no recorded interaction left for %s", key)
+}
+
+// Remaining returns how many recorded interactions have not been replayed
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	remaining := 0
+	for _, used := range r.used {
+		if !used {
+			remaining++
+		}
+	}
+	return remaining
+}
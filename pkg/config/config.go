@@ -3,6 +3,9 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,8 +14,12 @@ import (
 type Config struct {
 	// LLM Configuration
 	GeminiAPIKey   string
+	GeminiModel    string
 	DeepSeekAPIKey string
-	LLMProvider    string // "deepseek", "gemini"
+	LLMProvider    string   // "deepseek", "gemini", "scripted"
+	LLMFallbacks   []string // providers to fail over to, in order
+	LLMMaxAttempts int      // attempts per LLM call when errors are transient
+	ScriptFile     string   // script replayed by the scripted provider
 
 	// Ollama Configuration
 	OllamaHost string
@@ -23,9 +30,30 @@ type Config struct {
 	FitbitRedirectURL  string
 
 	// Agent Configuration
-	MaxTokens    int64
-	Model        string
-	SystemPrompt *SystemPrompt
+	MaxTokens           int64 // token budget for the conversation history sent to the LLM
+	SummarizeHistory    bool  // summarize trimmed history instead of dropping it
+	MaxRepairAttempts   int   // times the LLM is asked to resend malformed tool arguments
+	MaxParallelTools    int   // tool calls run at the same time
+	MaxStepsPerTurn     int   // LLM calls allowed for a single user message
+	TurnTimeout         time.Duration
+	AutoApproveCalories int           // side-effecting calls below this many calories run without confirmation
+	ToolTimeout         time.Duration // time a single tool call may take
+	ToolLog             string        // file tool calls are logged to, "stderr", or empty to not log them
+	Model               string        // model served by Ollama
+	Generation          GenerationOptions
+	SystemPrompt        *SystemPrompt
+
+	// Server Configuration
+	ServerAddr string // address the serve command listens on
+	APIToken   string // bearer token required by the HTTP API
+
+	// MCP Configuration
+	MCPConfigFile string // external MCP servers whose tools are registered
+
+	// Plugin Configuration
+	PluginDir       string        // directory of executable plugins
+	PluginAllowlist []string      // plugins that may be loaded, by name
+	PluginTimeout   time.Duration // time a plugin may run unless its manifest says otherwise
 }
 
 // LoadConfig loads configuration from environment variables
@@ -51,16 +79,35 @@ func LoadConfig() *Config {
 	}
 
 	return &Config{
-		GeminiAPIKey:       os.Getenv("GEMINI_API_KEY"),
-		DeepSeekAPIKey:     os.Getenv("DEEPSEEK_API_KEY"),
-		LLMProvider:        getEnvWithDefault("LLM_PROVIDER", "deepseek"),
-		OllamaHost:         getEnvWithDefault("OLLAMA_HOST", "http://localhost:11434"),
-		FitbitClientID:     os.Getenv("FITBIT_CLIENT_ID"),
-		FitbitClientSecret: os.Getenv("FITBIT_CLIENT_SECRET"),
-		FitbitRedirectURL:  getEnvWithDefault("FITBIT_REDIRECT_URL", "http://localhost:8000/redirect"),
-		MaxTokens:          4096,
-		Model:              getEnvWithDefault("LLM_MODEL", "deepseek-r1:7b"),
-		SystemPrompt:       LoadSystemPrompt(),
+		GeminiAPIKey:        os.Getenv("GEMINI_API_KEY"),
+		GeminiModel:         getEnvWithDefault("GEMINI_MODEL", "gemini-1.5-flash"),
+		DeepSeekAPIKey:      os.Getenv("DEEPSEEK_API_KEY"),
+		LLMProvider:         getEnvWithDefault("LLM_PROVIDER", "deepseek"),
+		LLMFallbacks:        getEnvListWithDefault("LLM_FALLBACK_PROVIDERS", nil),
+		ScriptFile:          os.Getenv("SCRIPTED_LLM_FILE"),
+		LLMMaxAttempts:      getEnvIntWithDefault("LLM_MAX_ATTEMPTS", 3),
+		OllamaHost:          getEnvWithDefault("OLLAMA_HOST", "http://localhost:11434"),
+		FitbitClientID:      os.Getenv("FITBIT_CLIENT_ID"),
+		FitbitClientSecret:  os.Getenv("FITBIT_CLIENT_SECRET"),
+		FitbitRedirectURL:   getEnvWithDefault("FITBIT_REDIRECT_URL", "http://localhost:8000/redirect"),
+		MaxTokens:           int64(getEnvIntWithDefault("LLM_CONTEXT_TOKENS", 4096)),
+		SummarizeHistory:    getEnvWithDefault("LLM_SUMMARIZE_HISTORY", "true") == "true",
+		MaxRepairAttempts:   getEnvIntWithDefault("AGENT_MAX_REPAIR_ATTEMPTS", 2),
+		MaxParallelTools:    getEnvIntWithDefault("AGENT_MAX_PARALLEL_TOOLS", 4),
+		MaxStepsPerTurn:     getEnvIntWithDefault("AGENT_MAX_STEPS", 8),
+		TurnTimeout:         getEnvDurationWithDefault("AGENT_TURN_TIMEOUT", 2*time.Minute),
+		AutoApproveCalories: getEnvIntWithDefault("AGENT_AUTO_APPROVE_CALORIES", 0),
+		ToolTimeout:         getEnvDurationWithDefault("AGENT_TOOL_TIMEOUT", time.Minute),
+		ToolLog:             os.Getenv("AGENT_TOOL_LOG"),
+		Model:               getEnvWithDefault("LLM_MODEL", "deepseek-r1:7b"),
+		ServerAddr:          getEnvWithDefault("AGENT_SERVER_ADDR", ":8080"),
+		APIToken:            os.Getenv("AGENT_API_TOKEN"),
+		MCPConfigFile:       getEnvWithDefault("AGENT_MCP_CONFIG", filepath.Join(homeDir, ".fitbit-agent", "mcp.json")),
+		PluginDir:           getEnvWithDefault("AGENT_PLUGIN_DIR", filepath.Join(homeDir, ".fitbit-agent", "tools")),
+		PluginAllowlist:     getEnvListWithDefault("AGENT_PLUGINS", nil),
+		PluginTimeout:       getEnvDurationWithDefault("AGENT_PLUGIN_TIMEOUT", 30*time.Second),
+		Generation:          loadGenerationOptions(),
+		SystemPrompt:        LoadSystemPrompt(),
 	}
 }
 
@@ -70,3 +117,36 @@ func getEnvWithDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvIntWithDefault(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDurationWithDefault(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvListWithDefault(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
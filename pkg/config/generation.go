@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// defaultDeterministicSeed is used when deterministic mode is on and no seed is set
+const defaultDeterministicSeed int64 = 42
+
+// GenerationOptions controls how providers sample responses. Unset fields are
+// left to the provider's defaults.
+type GenerationOptions struct {
+	Temperature     *float64
+	TopP            *float64
+	MaxOutputTokens int
+	Stop            []string
+	Seed            *int64
+}
+
+// IsZero reports whether no option is set, so provider defaults apply
+func (o GenerationOptions) IsZero() bool {
+	return o.Temperature == nil && o.TopP == nil && o.MaxOutputTokens == 0 && len(o.Stop) == 0 && o.Seed == nil
+}
+
+// loadGenerationOptions reads generation options from environment variables.
+// LLM_DETERMINISTIC=true pins temperature to 0 and fixes the seed so meal
+// parsing is reproducible, e.g. for regression tests.
+func loadGenerationOptions() GenerationOptions {
+	opts := GenerationOptions{
+		Temperature:     getEnvFloat("LLM_TEMPERATURE"),
+		TopP:            getEnvFloat("LLM_TOP_P"),
+		MaxOutputTokens: getEnvIntWithDefault("LLM_MAX_OUTPUT_TOKENS", 0),
+		Stop:            getEnvListWithDefault("LLM_STOP", nil),
+	}
+
+	if value := os.Getenv("LLM_SEED"); value != "" {
+		if seed, err := strconv.ParseInt(value, 10, 64); err == nil {
+			opts.Seed = &seed
+		}
+	}
+
+	if os.Getenv("LLM_DETERMINISTIC") == "true" {
+		zero := 0.0
+		opts.Temperature = &zero
+		if opts.Seed == nil {
+			seed := defaultDeterministicSeed
+			opts.Seed = &seed
+		}
+	}
+
+	return opts
+}
+
+func getEnvFloat(key string) *float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+	return &parsed
+}
@@ -55,6 +55,18 @@ func LoadSystemPrompt() *SystemPrompt {
 	return sp
 }
 
+// LoadSystemPromptFile loads a system prompt from a specific file
+func LoadSystemPromptFile(path string) (*SystemPrompt, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &SystemPrompt{
+		content: strings.TrimSpace(string(content)),
+		path:    path,
+	}, nil
+}
+
 // GetContent returns the system prompt content
 func (sp *SystemPrompt) GetContent() string {
 	return sp.content
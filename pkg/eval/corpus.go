@@ -0,0 +1,56 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// defaultTolerance is the relative calorie error accepted when a case sets none
+const defaultTolerance = 0.3
+
+// Corpus is a set of utterances with the tool call each one should produce
+type Corpus struct {
+	Cases []Case `json:"cases"`
+}
+
+// Case is a single utterance and its expected outcome
+type Case struct {
+	Name      string      `json:"name"`
+	Utterance string      `json:"utterance"`
+	Expect    Expectation `json:"expect"`
+}
+
+// Expectation describes the tool call a case should produce. Empty fields are not scored.
+type Expectation struct {
+	Tool      string   `json:"tool"`
+	MealType  string   `json:"meal_type,omitempty"`
+	Foods     []string `json:"foods,omitempty"`     // substrings that must each match a logged food name
+	Calories  float64  `json:"calories,omitempty"`  // expected total calories per meal
+	Tolerance float64  `json:"tolerance,omitempty"` // relative calorie error allowed, e.g. 0.3
+	Days      int      `json:"days,omitempty"`
+}
+
+// LoadCorpus reads a corpus from a JSON file
+func LoadCorpus(path string) (*Corpus, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read corpus: %w", err)
+	}
+
+	var corpus Corpus
+	if err := json.Unmarshal(data, &corpus); err != nil {
+		return nil, fmt.Errorf("failed to parse corpus %s: %w", path, err)
+	}
+
+	for i, c := range corpus.Cases {
+		if c.Utterance == "" || c.Expect.Tool == "" {
+			return nil, fmt.Errorf("corpus case %d (%s): utterance and expect.tool are required", i+1, c.Name)
+		}
+		if c.Name == "" {
+			corpus.Cases[i].Name = c.Utterance
+		}
+	}
+
+	return &corpus, nil
+}
@@ -0,0 +1,65 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// PrintComparison writes a side-by-side table of reports, one column per provider
+// or prompt variant, followed by the failure details for every case that failed
+func PrintComparison(w io.Writer, reports []*Report) {
+	if len(reports) == 0 {
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	header := []string{"CASE"}
+	for _, report := range reports {
+		header = append(header, report.Label)
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	for i, c := range reports[0].Results {
+		row := []string{c.Case.Name}
+		for _, report := range reports {
+			status := "✅"
+			if !report.Results[i].Score.Passed {
+				status = "❌"
+			}
+			row = append(row, status)
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	passed := []string{"PASSED"}
+	latency := []string{"AVG LATENCY"}
+	for _, report := range reports {
+		total := len(report.Results)
+		percent := 0
+		if total > 0 {
+			percent = report.Passed() * 100 / total
+		}
+		passed = append(passed, fmt.Sprintf("%d/%d (%d%%)", report.Passed(), total, percent))
+		latency = append(latency, report.AverageLatency().Round(10*time.Millisecond).String())
+	}
+	fmt.Fprintln(tw, strings.Join(passed, "\t"))
+	fmt.Fprintln(tw, strings.Join(latency, "\t"))
+	tw.Flush()
+
+	for _, report := range reports {
+		var details []string
+		for _, result := range report.Results {
+			if result.Score.Passed {
+				continue
+			}
+			details = append(details, fmt.Sprintf("  - %s: %s", result.Case.Name, strings.Join(result.Score.Failures, "; ")))
+		}
+		if len(details) > 0 {
+			fmt.Fprintf(w, "\n❌ Failures for %s:\n%s\n", report.Label, strings.Join(details, "\n"))
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+// CaseResult is the outcome of running a single corpus case
+type CaseResult struct {
+	Case     Case             `json:"case"`
+	Score    Score            `json:"score"`
+	Calls    []agent.ToolCall `json:"calls,omitempty"`
+	Latency  time.Duration    `json:"latency"`
+	Error    string           `json:"error,omitempty"`
+	Response string           `json:"response,omitempty"`
+}
+
+// Report holds the results of running a corpus against one provider and prompt variant
+type Report struct {
+	Label   string       `json:"label"`
+	Results []CaseResult `json:"results"`
+}
+
+// Passed returns the number of passing cases
+func (r *Report) Passed() int {
+	passed := 0
+	for _, result := range r.Results {
+		if result.Score.Passed {
+			passed++
+		}
+	}
+	return passed
+}
+
+// AverageLatency returns the mean time spent per case
+func (r *Report) AverageLatency() time.Duration {
+	if len(r.Results) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, result := range r.Results {
+		total += result.Latency
+	}
+	return total / time.Duration(len(r.Results))
+}
+
+// Runner sends corpus utterances to a provider and scores the tool calls it makes
+type Runner struct {
+	provider agent.LLMProvider
+	registry agent.ToolRegistry
+	maxSteps int
+}
+
+// NewRunner creates a runner. The registry should be a DryRunRegistry so tools
+// the model calls along the way (e.g. calorie lookups) have no side effects.
+func NewRunner(provider agent.LLMProvider, registry agent.ToolRegistry, maxSteps int) *Runner {
+	if maxSteps < 1 {
+		maxSteps = 1
+	}
+	return &Runner{
+		provider: provider,
+		registry: registry,
+		maxSteps: maxSteps,
+	}
+}
+
+// Run evaluates every case in the corpus
+func (r *Runner) Run(ctx context.Context, corpus *Corpus, label string) *Report {
+	report := &Report{Label: label}
+	for _, c := range corpus.Cases {
+		report.Results = append(report.Results, r.runCase(ctx, c))
+	}
+	return report
+}
+
+// runCase lets the model take up to maxSteps turns to produce the expected call
+func (r *Runner) runCase(ctx context.Context, c Case) CaseResult {
+	result := CaseResult{Case: c}
+	start := time.Now()
+	defer func() { result.Latency = time.Since(start) }()
+
+	conversation := []agent.Message{{Role: "user", Content: c.Utterance}}
+	for step := 0; step < r.maxSteps; step++ {
+		response, err := r.provider.GenerateResponse(ctx, conversation)
+		if err != nil {
+			result.Error = err.Error()
+			result.Score = Score{Failures: []string{fmt.Sprintf("error: %v", err)}}
+			return result
+		}
+
+		result.Response = response.Content
+		result.Calls = append(result.Calls, response.ToolCalls...)
+
+		if len(response.ToolCalls) == 0 || hasCall(response.ToolCalls, c.Expect.Tool) {
+			break
+		}
+
+		// The model called another tool first, answer it and let it continue
+		conversation = append(conversation, agent.Message{Role: "assistant", Content: response.Content, ToolCalls: response.ToolCalls})
+		for _, call := range response.ToolCalls {
+			result := agent.ToolResult{CallID: call.ID, Name: call.Name}
+			if tool, found := r.registry.GetTool(call.Name); found {
+				result.Content, _ = tool.Execute(ctx, call.Input)
+			} else {
+				result.Content = fmt.Sprintf("Error: tool '%s' not found", call.Name)
+				result.IsError = true
+			}
+			conversation = append(conversation, agent.Message{Role: "tool", Content: result})
+		}
+	}
+
+	result.Score = ScoreCalls(c.Expect, result.Calls)
+	return result
+}
+
+// hasCall reports whether calls include the named tool
+func hasCall(calls []agent.ToolCall, name string) bool {
+	for _, call := range calls {
+		if call.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+// Score is the outcome of comparing tool calls against an expectation
+type Score struct {
+	Passed   bool     `json:"passed"`
+	Calories float64  `json:"calories,omitempty"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+// loggedMeal is the subset of a meal logging call that is scored
+type loggedMeal struct {
+	MealType  string           `json:"meal_type"`
+	Foods     []map[string]any `json:"foods"`
+	DaysCount any              `json:"days_count"`
+}
+
+// ScoreCalls checks whether calls contain the expected tool call with the
+// expected meal type, foods and calories
+func ScoreCalls(expect Expectation, calls []agent.ToolCall) Score {
+	var call *agent.ToolCall
+	for i := range calls {
+		if calls[i].Name == expect.Tool {
+			call = &calls[i]
+			break
+		}
+	}
+
+	if call == nil {
+		called := make([]string, len(calls))
+		for i, c := range calls {
+			called[i] = c.Name
+		}
+		return Score{Failures: []string{fmt.Sprintf("tool: expected %s, got [%s]", expect.Tool, strings.Join(called, ", "))}}
+	}
+
+	var meal loggedMeal
+	if err := json.Unmarshal(call.Input, &meal); err != nil {
+		if expect.MealType == "" && len(expect.Foods) == 0 && expect.Calories == 0 && expect.Days == 0 {
+			return Score{Passed: true}
+		}
+		return Score{Failures: []string{fmt.Sprintf("input: invalid JSON: %v", err)}}
+	}
+
+	score := Score{}
+
+	if expect.MealType != "" && !strings.EqualFold(strings.TrimSpace(meal.MealType), expect.MealType) {
+		score.Failures = append(score.Failures, fmt.Sprintf("meal_type: expected %s, got %q", expect.MealType, meal.MealType))
+	}
+
+	names := make([]string, 0, len(meal.Foods))
+	for _, food := range meal.Foods {
+		names = append(names, strings.ToLower(foodName(food)))
+		score.Calories += number(food["calories"])
+	}
+
+	for _, want := range expect.Foods {
+		found := false
+		for _, name := range names {
+			if strings.Contains(name, strings.ToLower(want)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			score.Failures = append(score.Failures, fmt.Sprintf("foods: %q not in [%s]", want, strings.Join(names, ", ")))
+		}
+	}
+
+	if expect.Calories > 0 {
+		tolerance := expect.Tolerance
+		if tolerance <= 0 {
+			tolerance = defaultTolerance
+		}
+		if math.Abs(score.Calories-expect.Calories) > tolerance*expect.Calories {
+			score.Failures = append(score.Failures, fmt.Sprintf("calories: expected %.0f±%.0f%%, got %.0f", expect.Calories, tolerance*100, score.Calories))
+		}
+	}
+
+	if expect.Days > 0 {
+		days := int(number(meal.DaysCount))
+		if days == 0 {
+			days = 1
+		}
+		if days != expect.Days {
+			score.Failures = append(score.Failures, fmt.Sprintf("days: expected %d, got %d", expect.Days, days))
+		}
+	}
+
+	score.Passed = len(score.Failures) == 0
+	return score
+}
+
+// foodName returns the name of a logged food from any of the accepted fields
+func foodName(food map[string]any) string {
+	for _, key := range []string{"name", "food_item", "item", "food"} {
+		if name, ok := food[key].(string); ok && name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// number converts a JSON number or numeric string to float64
+func number(value any) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case string:
+		if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return parsed
+		}
+	}
+	return 0
+}
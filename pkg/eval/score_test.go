@@ -0,0 +1,53 @@
+package eval
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+func TestScoreCalls(t *testing.T) {
+	expect := Expectation{Tool: "fitbit_log_meal", MealType: "breakfast", Foods: []string{"egg", "toast"}, Calories: 300, Tolerance: 0.2}
+
+	testCases := []struct {
+		name       string
+		calls      []agent.ToolCall
+		wantPassed bool
+	}{
+		{
+			name:       "Matching call",
+			calls:      []agent.ToolCall{{Name: "fitbit_log_meal", Input: json.RawMessage(`{"meal_type": "Breakfast", "foods": [{"name": "scrambled eggs", "calories": 140}, {"name": "toast", "calories": "160"}]}`)}},
+			wantPassed: true,
+		},
+		{
+			name:  "Wrong tool",
+			calls: []agent.ToolCall{{Name: "save_meal_locally", Input: json.RawMessage(`{}`)}},
+		},
+		{
+			name:  "Wrong meal type",
+			calls: []agent.ToolCall{{Name: "fitbit_log_meal", Input: json.RawMessage(`{"meal_type": "lunch", "foods": [{"name": "eggs", "calories": 140}, {"name": "toast", "calories": 160}]}`)}},
+		},
+		{
+			name:  "Missing food",
+			calls: []agent.ToolCall{{Name: "fitbit_log_meal", Input: json.RawMessage(`{"meal_type": "breakfast", "foods": [{"name": "eggs", "calories": 300}]}`)}},
+		},
+		{
+			name:  "Calories outside tolerance",
+			calls: []agent.ToolCall{{Name: "fitbit_log_meal", Input: json.RawMessage(`{"meal_type": "breakfast", "foods": [{"name": "eggs", "calories": 400}, {"name": "toast", "calories": 160}]}`)}},
+		},
+		{
+			name:  "No calls",
+			calls: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			score := ScoreCalls(expect, tc.calls)
+			if score.Passed != tc.wantPassed {
+				t.Errorf("Expected passed=%v but got %v (failures: %v)", tc.wantPassed, score.Passed, score.Failures)
+			}
+		})
+	}
+}
@@ -2,25 +2,43 @@ package input
 
 import (
 	"bufio"
+	"context"
 	"os"
+	"sync"
 )
 
 // ConsoleInputProvider provides user input from console
 type ConsoleInputProvider struct {
 	scanner *bufio.Scanner
+	lines   chan string
+	start   sync.Once
 }
 
 // NewConsoleInputProvider creates a new console input provider
 func NewConsoleInputProvider() *ConsoleInputProvider {
 	return &ConsoleInputProvider{
 		scanner: bufio.NewScanner(os.Stdin),
+		lines:   make(chan string),
 	}
 }
 
-// GetInput reads a line of input from the console
-func (c *ConsoleInputProvider) GetInput() (string, bool) {
-	if !c.scanner.Scan() {
+// GetInput reads a line of input from the console. Stdin is read in the
+// background so a cancelled context interrupts the wait for input.
+func (c *ConsoleInputProvider) GetInput(ctx context.Context) (string, bool) {
+	c.start.Do(func() { go c.readLines() })
+
+	select {
+	case line, ok := <-c.lines:
+		return line, ok
+	case <-ctx.Done():
 		return "", false
 	}
-	return c.scanner.Text(), true
+}
+
+// readLines forwards stdin lines until end of input
+func (c *ConsoleInputProvider) readLines() {
+	for c.scanner.Scan() {
+		c.lines <- c.scanner.Text()
+	}
+	close(c.lines)
 }
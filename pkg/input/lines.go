@@ -0,0 +1,26 @@
+package input
+
+import "context"
+
+// LinesInputProvider provides user input from a fixed list of lines
+type LinesInputProvider struct {
+	lines []string
+}
+
+// NewLinesInputProvider creates an input provider that replays lines in order
+func NewLinesInputProvider(lines []string) *LinesInputProvider {
+	return &LinesInputProvider{
+		lines: lines,
+	}
+}
+
+// GetInput returns the next line, reporting false once all lines are consumed
+// or ctx is cancelled
+func (l *LinesInputProvider) GetInput(ctx context.Context) (string, bool) {
+	if len(l.lines) == 0 || ctx.Err() != nil {
+		return "", false
+	}
+	line := l.lines[0]
+	l.lines = l.lines[1:]
+	return line, true
+}
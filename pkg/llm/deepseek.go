@@ -4,14 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"regexp"
 	"strings"
 
 	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+	"github.com/vhbfernandes/fitbit-agent/pkg/config"
+	"github.com/vhbfernandes/fitbit-agent/pkg/llm/toolproto"
 )
 
 // DeepSeekProvider implements the LLMProvider interface for DeepSeek via Ollama
@@ -21,16 +23,15 @@ type DeepSeekProvider struct {
 	model        string
 	client       *http.Client
 	systemPrompt string
+	options      config.GenerationOptions
 }
 
 // NewDeepSeekProvider creates a new DeepSeek LLM provider using Ollama
-func NewDeepSeekProvider(toolRegistry agent.ToolRegistry, systemPrompt string) *DeepSeekProvider {
-	ollamaHost := os.Getenv("OLLAMA_HOST")
+func NewDeepSeekProvider(ollamaHost, model string, toolRegistry agent.ToolRegistry, systemPrompt string, options config.GenerationOptions) *DeepSeekProvider {
 	if ollamaHost == "" {
 		ollamaHost = "http://localhost:11434"
 	}
 
-	model := os.Getenv("LLM_MODEL")
 	if model == "" {
 		model = "deepseek-r1:7b"
 	}
@@ -41,6 +42,7 @@ func NewDeepSeekProvider(toolRegistry agent.ToolRegistry, systemPrompt string) *
 		model:        model,
 		client:       &http.Client{},
 		systemPrompt: systemPrompt,
+		options:      options,
 	}
 }
 
@@ -51,9 +53,19 @@ func (d *DeepSeekProvider) Name() string {
 
 // OllamaRequest represents the request structure for Ollama API
 type OllamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
+	Model   string         `json:"model"`
+	Prompt  string         `json:"prompt"`
+	Stream  bool           `json:"stream"`
+	Options *OllamaOptions `json:"options,omitempty"`
+}
+
+// OllamaOptions represents the model parameters accepted by the Ollama API
+type OllamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	Seed        *int64   `json:"seed,omitempty"`
 }
 
 // OllamaResponse represents the response structure from Ollama API
@@ -65,38 +77,12 @@ type OllamaResponse struct {
 
 // GenerateResponse generates a response using DeepSeek via Ollama
 func (d *DeepSeekProvider) GenerateResponse(ctx context.Context, conversation []agent.Message) (*agent.Response, error) {
-	prompt := d.buildPrompt(conversation)
-
-	request := OllamaRequest{
-		Model:  d.model,
-		Prompt: prompt,
-		Stream: false,
-	}
-
-	requestBody, err := json.Marshal(request)
+	resp, err := d.doGenerate(ctx, conversation, false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	url := fmt.Sprintf("%s/api/generate", d.ollamaHost)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := d.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request to Ollama: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ollama API error (status %d): %s", resp.StatusCode, string(body))
-	}
-
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
@@ -108,7 +94,7 @@ func (d *DeepSeekProvider) GenerateResponse(ctx context.Context, conversation []
 	}
 
 	if ollamaResp.Error != "" {
-		return nil, fmt.Errorf("ollama error: %s", ollamaResp.Error)
+		return nil, d.bodyError(ollamaResp.Error)
 	}
 
 	toolCalls := d.ParseToolCalls(ollamaResp.Response)
@@ -119,211 +105,162 @@ func (d *DeepSeekProvider) GenerateResponse(ctx context.Context, conversation []
 	}, nil
 }
 
-func (d *DeepSeekProvider) buildPrompt(conversation []agent.Message) string {
-	var prompt string
-
-	// START WITH TOOL CALL REQUIREMENT - FIRST THING THE LLM SEES
-	tools := d.toolRegistry.GetAllTools()
-	if len(tools) > 0 {
-		prompt += "🚨🚨🚨 CRITICAL: YOU MUST USE TOOLS! 🚨🚨🚨\n"
-		prompt += "When user asks to log meals, you MUST use this EXACT format:\n"
-		prompt += "TOOL_CALL: fitbit_log_meal({\"meal_type\": \"breakfast\", \"foods\": [{\"name\": \"scrambled eggs\", \"quantity\": 2, \"unit\": \"large\", \"calories\": 140}]})\n\n"
-		prompt += "DO NOT just say 'I'll log it' - ACTUALLY CALL THE TOOL!\n\n"
+// GenerateResponseStream generates a response using DeepSeek via Ollama, calling
+// onToken for every chunk as Ollama streams it back
+func (d *DeepSeekProvider) GenerateResponseStream(ctx context.Context, conversation []agent.Message, onToken func(token string)) (*agent.Response, error) {
+	resp, err := d.doGenerate(ctx, conversation, true)
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	if d.systemPrompt != "" {
-		prompt += fmt.Sprintf("System: %s\n\n", d.systemPrompt)
-	}
+	// Ollama streams one JSON object per line until "done" is set
+	var content strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk OllamaResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, &APIError{Provider: d.Name(), Kind: ErrNetwork, Message: "the response stream ended before it was complete"}
+			}
+			return nil, fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
 
-	if len(tools) > 0 {
-		prompt += "🚨 AVAILABLE TOOLS:\n"
-		for _, tool := range tools {
-			prompt += fmt.Sprintf("- %s: %s\n", tool.Name(), tool.Description())
+		if chunk.Error != "" {
+			return nil, d.bodyError(chunk.Error)
 		}
-		prompt += "\n"
-	}
 
-	for _, msg := range conversation {
-		switch msg.Role {
-		case "user":
-			content := fmt.Sprintf("%s", msg.Content)
-			if strings.HasPrefix(content, "Tool result: ") {
-				result := strings.TrimPrefix(content, "Tool result: ")
-				prompt += fmt.Sprintf("Tool Result:\n%s\n\n", result)
-
-				// If tool result contains a suggested tool call, make it very explicit
-				if strings.Contains(result, "TOOL_CALL:") {
-					prompt += "🚨 The tool result above contains a suggested TOOL_CALL. You MUST execute it immediately using the exact format shown!\n"
-					prompt += "Copy the TOOL_CALL line exactly as written in the tool result.\n\n"
-				}
-			} else {
-				prompt += fmt.Sprintf("Human: %s\n", content)
-			}
-		case "assistant":
-			prompt += fmt.Sprintf("Assistant: %s\n", msg.Content)
+		if chunk.Response != "" {
+			content.WriteString(chunk.Response)
+			onToken(chunk.Response)
 		}
-	}
 
-	// END WITH TOOL CALL REQUIREMENT - LAST THING THE LLM SEES
-	if len(tools) > 0 {
-		prompt += "\n🚨🚨🚨 TOOL CALL FORMAT RULES - FOLLOW EXACTLY! 🚨🚨🚨\n"
-		prompt += "1. Make ONLY ONE tool call per response\n"
-		prompt += "2. Use EXACT format: TOOL_CALL: tool_name(json)\n"
-		prompt += "3. NO extra text after the closing parenthesis )\n"
-		prompt += "4. NO semicolons, commas, or explanations after )\n"
-		prompt += "5. JSON must be valid and complete\n"
-		prompt += "6. End the line immediately after the )\n"
-		prompt += "7. DO NOT repeat tool calls multiple times\n"
-		prompt += "Example: TOOL_CALL: fitbit_log_meal({\"meal_type\": \"breakfast\", \"foods\": [{\"name\": \"eggs\", \"quantity\": 2, \"unit\": \"large\", \"calories\": 140}]})\n"
-		prompt += "WRONG: TOOL_CALL: fitbit_log_meal({...}); followed by explanation\n"
-		prompt += "WRONG: Making multiple identical tool calls\n"
-		prompt += "RIGHT: TOOL_CALL: fitbit_log_meal({...})\n"
+		if chunk.Done {
+			break
+		}
 	}
 
-	prompt += "Assistant: "
-	return prompt
+	responseText := content.String()
+	return &agent.Response{
+		Content:   responseText,
+		ToolCalls: d.ParseToolCalls(responseText),
+	}, nil
 }
 
-func (d *DeepSeekProvider) ParseToolCalls(response string) []agent.ToolCall {
-	var toolCalls []agent.ToolCall
-
-	// Primary pattern: TOOL_CALL: tool_name(json) - find the tool call start and manually parse the content
-	re := regexp.MustCompile(`TOOL_CALL:\s*(\w+)\s*\(`)
-	matches := re.FindAllStringSubmatchIndex(response, -1)
-
-	for i, match := range matches {
-		if len(match) >= 4 {
-			toolName := response[match[2]:match[3]]
+// doGenerate sends a generate request to Ollama and returns the successful HTTP response
+func (d *DeepSeekProvider) doGenerate(ctx context.Context, conversation []agent.Message, stream bool) (*http.Response, error) {
+	prompt := d.buildPrompt(conversation)
 
-			// Find the opening parenthesis position
-			openParenPos := match[1] - 1 // match[1] is after the (, so -1 to get the ( position
+	request := OllamaRequest{
+		Model:   d.model,
+		Prompt:  prompt,
+		Stream:  stream,
+		Options: d.buildOptions(),
+	}
 
-			// Manually parse from the opening parenthesis to find the matching closing one
-			jsonContent := extractJSONManually(response, openParenPos)
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
 
-			// Clean up the JSON content - only remove trailing semicolons
-			jsonContent = fixCommonJSONIssues(jsonContent)
+	url := fmt.Sprintf("%s/api/generate", d.ollamaHost)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
 
-			if jsonContent == "" {
-				continue
-			}
+	req.Header.Set("Content-Type", "application/json")
 
-			var input json.RawMessage
-			if json.Valid([]byte(jsonContent)) {
-				input = json.RawMessage(jsonContent)
-			} else {
-				// If still invalid, wrap in input field for debugging
-				escapedInput := fmt.Sprintf("{\"input\": %q}", jsonContent)
-				input = json.RawMessage(escapedInput)
-			}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, NewTransportError(d.Name(), err)
+	}
 
-			toolCall := agent.ToolCall{
-				ID:       fmt.Sprintf("call_%d", i),
-				Name:     toolName,
-				Function: toolName,
-				Input:    input,
-			}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
 
-			toolCalls = append(toolCalls, toolCall)
-		}
-	} // Fallback patterns for other formats
-	if len(toolCalls) == 0 {
-		// Try: Call tool_name with {...}
-		re2 := regexp.MustCompile(`Call\s+(\w+)\s+with\s+({[^}]*})`)
-		matches2 := re2.FindAllStringSubmatch(response, -1)
-
-		for i, match := range matches2 {
-			if len(match) >= 3 {
-				toolName := match[1]
-				inputStr := match[2]
-
-				var input json.RawMessage
-				if json.Valid([]byte(inputStr)) {
-					input = json.RawMessage(inputStr)
-				} else {
-					escapedInput := fmt.Sprintf("{\"input\": %q}", inputStr)
-					input = json.RawMessage(escapedInput)
-				}
-
-				toolCall := agent.ToolCall{
-					ID:       fmt.Sprintf("call_%d", i),
-					Name:     toolName,
-					Function: toolName,
-					Input:    input,
-				}
-
-				toolCalls = append(toolCalls, toolCall)
-			}
+		// Ollama reports errors as {"error": "..."}; fall back to the raw body
+		message := string(body)
+		var ollamaResp OllamaResponse
+		if json.Unmarshal(body, &ollamaResp) == nil && ollamaResp.Error != "" {
+			message = ollamaResp.Error
 		}
+		return nil, NewHTTPError(d.Name(), resp.StatusCode, resp.Header, message)
 	}
 
-	return toolCalls
+	return resp, nil
 }
 
-// extractJSONManually extracts JSON content from a tool call, handling nested parentheses properly
-func extractJSONManually(text string, startPos int) string {
-	if startPos >= len(text) || text[startPos] != '(' {
-		return ""
+// invalidRequestText matches Ollama errors caused by the request itself, which
+// fail the same way if sent again
+var invalidRequestText = regexp.MustCompile(`(?i)not found|invalid|required|unsupported|unknown|exceeds`)
+
+// bodyError classifies an error Ollama reports in a successful response. Most
+// are temporary, like a model that is still loading or ran out of memory.
+func (d *DeepSeekProvider) bodyError(message string) error {
+	kind := ErrServiceDown
+	if invalidRequestText.MatchString(message) {
+		kind = ErrInvalidRequest
 	}
+	return &APIError{Provider: d.Name(), Kind: kind, Message: message}
+}
 
-	// Start after the opening parenthesis
-	pos := startPos + 1
-	parenCount := 1
-	inString := false
-	escaped := false
+// buildOptions converts the generation options to Ollama model parameters
+func (d *DeepSeekProvider) buildOptions() *OllamaOptions {
+	opts := d.options
+	if opts.IsZero() {
+		return nil
+	}
 
-	for pos < len(text) {
-		char := text[pos]
+	return &OllamaOptions{
+		Temperature: opts.Temperature,
+		TopP:        opts.TopP,
+		NumPredict:  opts.MaxOutputTokens,
+		Stop:        opts.Stop,
+		Seed:        opts.Seed,
+	}
+}
 
-		if escaped {
-			escaped = false
-			pos++
-			continue
-		}
+func (d *DeepSeekProvider) buildPrompt(conversation []agent.Message) string {
+	var prompt string
 
-		if char == '\\' {
-			escaped = true
-			pos++
-			continue
-		}
+	// START WITH TOOL CALL REQUIREMENT - FIRST THING THE LLM SEES
+	defs := d.toolRegistry.GetToolDefinitions()
+	prompt += toolproto.RenderPreamble(defs)
 
-		if char == '"' {
-			inString = !inString
-			pos++
-			continue
-		}
+	if d.systemPrompt != "" {
+		prompt += fmt.Sprintf("System: %s\n\n", d.systemPrompt)
+	}
 
-		if !inString {
-			if char == '(' {
-				parenCount++
-			} else if char == ')' {
-				parenCount--
-				if parenCount == 0 {
-					// Found the matching closing parenthesis
-					return strings.TrimSpace(text[startPos+1 : pos])
-				}
-			}
+	if tools := toolproto.RenderTools(defs); tools != "" {
+		prompt += tools + "\n"
+	}
+
+	for _, msg := range conversation {
+		switch msg.Role {
+		case "user":
+			prompt += fmt.Sprintf("Human: %s\n", msg.Content)
+		case "tool":
+			result, _ := msg.Content.(agent.ToolResult)
+			prompt += toolproto.RenderResult(result) + "\n\n"
+		case "assistant":
+			prompt += fmt.Sprintf("Assistant: %s\n", msg.Content)
 		}
+	}
 
-		pos++
+	// END WITH TOOL CALL REQUIREMENT - LAST THING THE LLM SEES
+	if rules := toolproto.RenderRules(defs); rules != "" {
+		prompt += "\n" + rules
 	}
 
-	// If we didn't find a matching closing parenthesis, return what we have
-	return strings.TrimSpace(text[startPos+1:])
+	prompt += "Assistant: "
+	return prompt
 }
 
-// fixCommonJSONIssues attempts to fix common JSON formatting issues
-func fixCommonJSONIssues(jsonStr string) string {
-	// Trim whitespace
-	jsonStr = strings.TrimSpace(jsonStr)
-
-	// Only remove trailing semicolons - don't be too aggressive
-	if strings.HasSuffix(jsonStr, ";") {
-		jsonStr = strings.TrimSuffix(jsonStr, ";")
-		jsonStr = strings.TrimSpace(jsonStr)
-	}
-
-	return jsonStr
+// ParseToolCalls extracts tool calls from a DeepSeek response
+func (d *DeepSeekProvider) ParseToolCalls(response string) []agent.ToolCall {
+	return toolproto.Parse(response, toolproto.Names(d.toolRegistry.GetToolDefinitions()))
 }
 
 func (d *DeepSeekProvider) ValidateConnection() error {
@@ -0,0 +1,188 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API error kinds shared by every provider. Provider errors wrap one of these
+// so callers can use errors.Is instead of matching on message text.
+var (
+	ErrQuotaExceeded  = errors.New("API quota exceeded")
+	ErrRateLimited    = errors.New("API rate limited")
+	ErrAPIKey         = errors.New("invalid API key")
+	ErrServiceDown    = errors.New("service unavailable")
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrNetwork        = errors.New("network error")
+	ErrTimeout        = errors.New("request timed out")
+)
+
+// APIError is returned by providers when a call to the LLM service fails
+type APIError struct {
+	Provider   string
+	Kind       error
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration
+	Err        error
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s: %v", e.Provider, e.Kind)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	} else if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap exposes both the error kind and the underlying transport error
+func (e *APIError) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+// Retryable reports whether the same request may succeed if sent again
+func (e *APIError) Retryable() bool {
+	switch e.Kind {
+	case ErrRateLimited, ErrServiceDown, ErrNetwork, ErrTimeout:
+		return true
+	}
+	return false
+}
+
+// Recoverable reports whether the user can keep chatting after this error
+func (e *APIError) Recoverable() bool {
+	return e.Kind != ErrInvalidRequest
+}
+
+// Suggestion returns a short hint telling the user how to resolve the error
+func (e *APIError) Suggestion() string {
+	switch e.Kind {
+	case ErrQuotaExceeded:
+		return "You've exceeded your API quota. Please:\n" +
+			"   1. Check your billing plan\n" +
+			"   2. Wait for quota reset\n" +
+			"   3. Try a different provider, or set LLM_FALLBACK_PROVIDERS=deepseek to fail over to Ollama automatically"
+	case ErrRateLimited:
+		return "API rate limited. Please wait a moment and try again."
+	case ErrAPIKey:
+		return fmt.Sprintf("Invalid API key. Please check the API key configured for %s.", e.Provider)
+	case ErrServiceDown:
+		return "Service temporarily unavailable. Please try again later."
+	case ErrNetwork, ErrTimeout:
+		return fmt.Sprintf("Could not reach %s. Please check your connection and try again.", e.Provider)
+	default:
+		return "Try again or switch to a different LLM provider."
+	}
+}
+
+// NewHTTPError classifies a non-2xx HTTP response from a provider
+func NewHTTPError(provider string, statusCode int, header http.Header, message string) *APIError {
+	apiErr := &APIError{
+		Provider:   provider,
+		StatusCode: statusCode,
+		Message:    strings.TrimSpace(message),
+		RetryAfter: parseRetryAfter(header),
+	}
+
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		apiErr.Kind = ErrRateLimited
+		if strings.Contains(strings.ToLower(message), "quota") {
+			apiErr.Kind = ErrQuotaExceeded
+		}
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		apiErr.Kind = ErrAPIKey
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusGatewayTimeout:
+		apiErr.Kind = ErrTimeout
+	case statusCode >= 500:
+		apiErr.Kind = ErrServiceDown
+	default:
+		apiErr.Kind = ErrInvalidRequest
+	}
+
+	if apiErr.Message == "" {
+		apiErr.Message = fmt.Sprintf("HTTP error %d", statusCode)
+	}
+
+	return apiErr
+}
+
+// NewTransportError classifies an error returned by http.Client.Do. Context
+// cancellation is returned unchanged since it was requested by the caller. The
+// query is removed from the request URL in the message, as it can carry an API key.
+func NewTransportError(provider string, err error) error {
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	kind := ErrNetwork
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		kind = ErrTimeout
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+			u.RawQuery = ""
+			err = &url.Error{Op: urlErr.Op, URL: u.String(), Err: urlErr.Err}
+		} else {
+			err = urlErr.Err
+		}
+	}
+
+	return &APIError{
+		Provider: provider,
+		Kind:     kind,
+		Err:      err,
+	}
+}
+
+// IsRetryable reports whether err is a transient provider error worth retrying
+func IsRetryable(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Retryable()
+}
+
+// IsRecoverable reports whether err is a provider error the user can recover from
+func IsRecoverable(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Recoverable()
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(header http.Header) time.Duration {
+	if header == nil {
+		return 0
+	}
+
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+
+	return 0
+}
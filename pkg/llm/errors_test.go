@@ -0,0 +1,32 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/config"
+)
+
+func TestTransportErrorHidesAPIKey(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	provider := NewGeminiProvider("secret-api-key", "test", emptyRegistry{}, "", config.GenerationOptions{})
+	provider.baseURL = server.URL
+
+	_, err := provider.GenerateResponse(context.Background(), nil)
+
+	if !errors.Is(err, ErrNetwork) {
+		t.Fatalf("Expected a network error but got: %v", err)
+	}
+	if strings.Contains(err.Error(), "secret-api-key") {
+		t.Errorf("Expected the API key to be removed from the error but got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "generateContent") {
+		t.Errorf("Expected the error to name the request URL but got: %v", err)
+	}
+}
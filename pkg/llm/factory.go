@@ -2,15 +2,26 @@ package llm
 
 import (
 	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
 	"github.com/vhbfernandes/fitbit-agent/pkg/config"
 )
 
+// supportedProviders lists the providers createBaseProvider can build
+var supportedProviders = []string{"deepseek", "gemini", "scripted"}
+
 // ProviderFactory creates LLM providers based on configuration
 type ProviderFactory struct {
 	config       *config.Config
 	toolRegistry agent.ToolRegistry
+
+	// Transport carries the providers' HTTP requests, e.g. to record them (optional)
+	Transport http.RoundTripper
 }
 
 // NewProviderFactory creates a new provider factory
@@ -21,14 +32,59 @@ func NewProviderFactory(config *config.Config, toolRegistry agent.ToolRegistry)
 	}
 }
 
-// CreateProvider creates an LLM provider based on the configuration
+// CreateProvider creates an LLM provider based on the configuration. Transient
+// errors from the provider are retried according to the configured retry policy,
+// and when fallback providers are configured they are chained behind the primary.
 func (f *ProviderFactory) CreateProvider() (agent.LLMProvider, error) {
+	names := []string{f.config.LLMProvider}
+	for _, name := range f.config.LLMFallbacks {
+		if !isSupportedProvider(name) {
+			return nil, fmt.Errorf("unsupported provider in LLM_FALLBACK_PROVIDERS: %s. Supported providers: %s", name, strings.Join(supportedProviders, ", "))
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	var providers []agent.LLMProvider
+	var firstErr error
+	for _, name := range names {
+		provider, err := f.createBaseProvider(name)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			if len(names) > 1 {
+				fmt.Fprintf(os.Stderr, "⚠️  Skipping %s in provider chain: %v\n", name, err)
+			}
+			continue
+		}
+		providers = append(providers, NewRetryProvider(provider, f.retryPolicy()))
+	}
+
+	switch len(providers) {
+	case 0:
+		return nil, firstErr
+	case 1:
+		return providers[0], nil
+	}
+
+	chain := NewFallbackProvider(providers...)
+	chain.OnFailover = func(from, to agent.LLMProvider, err error) {
+		fmt.Fprintf(os.Stderr, "\u001b[93m↪️  %s failed (%v), switching to %s\u001b[0m\n", from.Name(), err, to.Name())
+	}
+	return chain, nil
+}
+
+// createBaseProvider creates the named provider without any wrapping
+func (f *ProviderFactory) createBaseProvider(name string) (agent.LLMProvider, error) {
 	systemPrompt := f.config.SystemPrompt.GetContent()
 
-	switch f.config.LLMProvider {
+	switch name {
 	case "deepseek":
 		// DeepSeek via Ollama - validate connection
-		provider := NewDeepSeekProvider(f.toolRegistry, systemPrompt)
+		provider := NewDeepSeekProvider(f.config.OllamaHost, f.config.Model, f.toolRegistry, systemPrompt, f.config.Generation)
+		provider.client.Transport = f.Transport
 		if err := provider.ValidateConnection(); err != nil {
 			return nil, fmt.Errorf("DeepSeek (Ollama) connection failed: %w", err)
 		}
@@ -38,9 +94,36 @@ func (f *ProviderFactory) CreateProvider() (agent.LLMProvider, error) {
 		if f.config.GeminiAPIKey == "" {
 			return nil, fmt.Errorf("GEMINI_API_KEY environment variable is required for Gemini provider")
 		}
-		return NewGeminiProvider(f.config.GeminiAPIKey, f.toolRegistry, systemPrompt), nil
+		provider := NewGeminiProvider(f.config.GeminiAPIKey, f.config.GeminiModel, f.toolRegistry, systemPrompt, f.config.Generation)
+		provider.client.Transport = f.Transport
+		return provider, nil
+
+	case "scripted", "mock":
+		if f.config.ScriptFile == "" {
+			return nil, fmt.Errorf("SCRIPTED_LLM_FILE environment variable is required for the scripted provider")
+		}
+		script, err := LoadScript(f.config.ScriptFile)
+		if err != nil {
+			return nil, err
+		}
+		return NewScriptedProvider(script), nil
 
 	default:
-		return nil, fmt.Errorf("unsupported LLM provider: %s. Supported providers: deepseek, gemini", f.config.LLMProvider)
+		return nil, fmt.Errorf("unsupported LLM provider: %s. Supported providers: %s", name, strings.Join(supportedProviders, ", "))
+	}
+}
+
+// isSupportedProvider reports whether createBaseProvider knows the named provider
+func isSupportedProvider(name string) bool {
+	return slices.Contains(supportedProviders, name) || name == "mock"
+}
+
+// retryPolicy builds the retry policy from configuration
+func (f *ProviderFactory) retryPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = f.config.LLMMaxAttempts
+	policy.OnRetry = func(attempt int, delay time.Duration, err error) {
+		fmt.Fprintf(os.Stderr, "\u001b[93m⏳ %v - retrying in %s (attempt %d/%d)\u001b[0m\n", err, delay.Round(time.Second), attempt, policy.MaxAttempts)
 	}
+	return policy
 }
@@ -0,0 +1,42 @@
+package llm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/config"
+)
+
+func TestCreateProviderFallbacks(t *testing.T) {
+	scriptFile := filepath.Join(t.TempDir(), "script.json")
+	if err := os.WriteFile(scriptFile, []byte(`{"turns":[{"content":"Hi"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name      string
+		fallbacks []string
+		wantErr   string
+	}{
+		{name: "Supported fallback", fallbacks: []string{"mock"}},
+		{name: "Unknown fallback", fallbacks: []string{"openai"}, wantErr: "unsupported provider in LLM_FALLBACK_PROVIDERS: openai. Supported providers: deepseek, gemini, scripted"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{LLMProvider: "scripted", LLMFallbacks: tc.fallbacks, ScriptFile: scriptFile, SystemPrompt: &config.SystemPrompt{}}
+			provider, err := NewProviderFactory(cfg, nil).CreateProvider()
+			if tc.wantErr == "" {
+				if err != nil || provider == nil {
+					t.Fatalf("expected a provider, got error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+// FallbackProvider tries an ordered chain of providers, failing over to the next
+// one when a provider is out of quota or unavailable. An invalid API key is a
+// configuration problem, so it is reported instead of hidden by a failover. The
+// conversation is passed unchanged to every provider, so context is preserved
+// across a failover.
+type FallbackProvider struct {
+	providers []agent.LLMProvider
+
+	// OnFailover is called when the chain moves on to the next provider (optional)
+	OnFailover func(from, to agent.LLMProvider, err error)
+}
+
+// NewFallbackProvider creates a provider chain in order of preference
+func NewFallbackProvider(providers ...agent.LLMProvider) *FallbackProvider {
+	return &FallbackProvider{
+		providers: providers,
+	}
+}
+
+// Name returns the names of all providers in the chain
+func (f *FallbackProvider) Name() string {
+	names := make([]string, len(f.providers))
+	for i, provider := range f.providers {
+		names[i] = provider.Name()
+	}
+	return strings.Join(names, " → ")
+}
+
+// GenerateResponse asks each provider in turn until one answers
+func (f *FallbackProvider) GenerateResponse(ctx context.Context, conversation []agent.Message) (*agent.Response, error) {
+	return f.do(func(provider agent.LLMProvider) (*agent.Response, bool, error) {
+		response, err := provider.GenerateResponse(ctx, conversation)
+		return response, false, err
+	})
+}
+
+// GenerateResponseStream streams from each provider in turn until one answers.
+// Once a provider has emitted tokens the chain no longer fails over.
+func (f *FallbackProvider) GenerateResponseStream(ctx context.Context, conversation []agent.Message, onToken func(token string)) (*agent.Response, error) {
+	return f.do(func(provider agent.LLMProvider) (*agent.Response, bool, error) {
+		streamer, ok := provider.(agent.StreamingLLMProvider)
+		if !ok {
+			response, err := provider.GenerateResponse(ctx, conversation)
+			if err == nil && response.Content != "" {
+				onToken(response.Content)
+			}
+			return response, false, err
+		}
+
+		emitted := false
+		response, err := streamer.GenerateResponseStream(ctx, conversation, func(token string) {
+			emitted = true
+			onToken(token)
+		})
+		return response, emitted, err
+	})
+}
+
+// do runs attempt against each provider and records which one answered
+func (f *FallbackProvider) do(attempt func(provider agent.LLMProvider) (*agent.Response, bool, error)) (*agent.Response, error) {
+	var lastErr error
+	for i, provider := range f.providers {
+		response, emitted, err := attempt(provider)
+		if err == nil {
+			response.Provider = provider.Name()
+			return response, nil
+		}
+
+		lastErr = err
+		if emitted || !shouldFailover(err) || i == len(f.providers)-1 {
+			break
+		}
+
+		if f.OnFailover != nil {
+			f.OnFailover(provider, f.providers[i+1], err)
+		}
+	}
+
+	return nil, lastErr
+}
+
+// shouldFailover reports whether another provider may answer after err
+func shouldFailover(err error) bool {
+	return IsRecoverable(err) && !errors.Is(err, ErrAPIKey)
+}
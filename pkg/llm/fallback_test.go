@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+// scriptedProvider streams its tokens and then fails with err, or answers
+type scriptedProvider struct {
+	name   string
+	tokens []string
+	err    error
+	calls  int
+}
+
+func (s *scriptedProvider) Name() string { return s.name }
+
+func (s *scriptedProvider) GenerateResponse(ctx context.Context, conversation []agent.Message) (*agent.Response, error) {
+	return s.GenerateResponseStream(ctx, conversation, func(string) {})
+}
+
+func (s *scriptedProvider) GenerateResponseStream(ctx context.Context, conversation []agent.Message, onToken func(token string)) (*agent.Response, error) {
+	s.calls++
+	for _, token := range s.tokens {
+		onToken(token)
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &agent.Response{Content: "answer from " + s.name}, nil
+}
+
+func TestFallbackProvider(t *testing.T) {
+	testCases := []struct {
+		name          string
+		primaryErr    error
+		primaryTokens []string
+		stream        bool
+		wantProvider  string
+		wantErr       error
+		wantFailover  bool
+	}{
+		{
+			name:         "Primary answers",
+			wantProvider: "primary",
+		},
+		{
+			name:         "Quota exceeded fails over",
+			primaryErr:   NewHTTPError("primary", 429, nil, "Quota exceeded"),
+			wantProvider: "backup",
+			wantFailover: true,
+		},
+		{
+			name:         "Service down fails over",
+			primaryErr:   NewHTTPError("primary", 503, nil, ""),
+			wantProvider: "backup",
+			wantFailover: true,
+		},
+		{
+			name:         "Network error fails over",
+			primaryErr:   &APIError{Provider: "primary", Kind: ErrNetwork},
+			wantProvider: "backup",
+			wantFailover: true,
+		},
+		{
+			name:       "Invalid API key is reported",
+			primaryErr: NewHTTPError("primary", 401, nil, "API key not valid"),
+			wantErr:    ErrAPIKey,
+		},
+		{
+			name:       "Invalid request is reported",
+			primaryErr: NewHTTPError("primary", 400, nil, "bad request"),
+			wantErr:    ErrInvalidRequest,
+		},
+		{
+			name:       "Other errors are reported",
+			primaryErr: errors.New("failed to parse response"),
+			wantErr:    errors.New("failed to parse response"),
+		},
+		{
+			name:         "Stream fails over before any token",
+			primaryErr:   NewHTTPError("primary", 503, nil, ""),
+			stream:       true,
+			wantProvider: "backup",
+			wantFailover: true,
+		},
+		{
+			name:          "Stream does not fail over after a token",
+			primaryErr:    &APIError{Provider: "primary", Kind: ErrNetwork},
+			primaryTokens: []string{"Hel"},
+			stream:        true,
+			wantErr:       ErrNetwork,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			primary := &scriptedProvider{name: "primary", tokens: tc.primaryTokens, err: tc.primaryErr}
+			backup := &scriptedProvider{name: "backup"}
+			provider := NewFallbackProvider(primary, backup)
+
+			failedOver := false
+			provider.OnFailover = func(from, to agent.LLMProvider, err error) {
+				failedOver = from == primary && to == backup
+			}
+
+			var resp *agent.Response
+			var err error
+			if tc.stream {
+				resp, err = provider.GenerateResponseStream(context.Background(), nil, func(string) {})
+			} else {
+				resp, err = provider.GenerateResponse(context.Background(), nil)
+			}
+
+			if tc.wantErr != nil {
+				if err == nil || !errors.Is(err, tc.wantErr) && err.Error() != tc.wantErr.Error() {
+					t.Fatalf("Expected error %v but got: %v", tc.wantErr, err)
+				}
+				if backup.calls != 0 {
+					t.Errorf("Expected no call to the backup but got %d", backup.calls)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Expected no error but got: %v", err)
+				}
+				if resp.Provider != tc.wantProvider {
+					t.Errorf("Expected an answer from %s but got %s", tc.wantProvider, resp.Provider)
+				}
+			}
+			if failedOver != tc.wantFailover {
+				t.Errorf("Expected failover %v but got %v", tc.wantFailover, failedOver)
+			}
+		})
+	}
+}
@@ -1,51 +1,49 @@
 package llm
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
-	"regexp"
 	"strings"
+	"time"
 
 	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+	"github.com/vhbfernandes/fitbit-agent/pkg/config"
+	"github.com/vhbfernandes/fitbit-agent/pkg/llm/toolproto"
 )
 
-// API error types for better error handling
-var (
-	ErrQuotaExceeded  = errors.New("API quota exceeded")
-	ErrRateLimited    = errors.New("API rate limited")
-	ErrAPIKey         = errors.New("invalid API key")
-	ErrServiceDown    = errors.New("service unavailable")
-	ErrInvalidRequest = errors.New("invalid request")
-)
+// geminiBaseURL is the Gemini API endpoint
+const geminiBaseURL = "https://generativelanguage.googleapis.com"
 
 // GeminiProvider implements the LLMProvider interface for Google Gemini
 type GeminiProvider struct {
+	baseURL      string
 	apiKey       string
 	toolRegistry agent.ToolRegistry
 	model        string
 	client       *http.Client
 	systemPrompt string
+	options      config.GenerationOptions
 }
 
 // NewGeminiProvider creates a new Gemini LLM provider
-func NewGeminiProvider(apiKey string, toolRegistry agent.ToolRegistry, systemPrompt string) *GeminiProvider {
-	model := os.Getenv("GEMINI_MODEL")
+func NewGeminiProvider(apiKey, model string, toolRegistry agent.ToolRegistry, systemPrompt string, options config.GenerationOptions) *GeminiProvider {
 	if model == "" {
 		model = "gemini-1.5-flash"
 	}
 
 	return &GeminiProvider{
+		baseURL:      geminiBaseURL,
 		apiKey:       apiKey,
 		toolRegistry: toolRegistry,
 		model:        model,
 		client:       &http.Client{},
 		systemPrompt: systemPrompt,
+		options:      options,
 	}
 }
 
@@ -56,7 +54,31 @@ func (g *GeminiProvider) Name() string {
 
 // GeminiRequest represents the request structure for Gemini API
 type GeminiRequest struct {
-	Contents []GeminiContent `json:"contents"`
+	Contents         []GeminiContent         `json:"contents"`
+	Tools            []GeminiTool            `json:"tools,omitempty"`
+	GenerationConfig *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// GeminiTool declares the functions the model may call
+type GeminiTool struct {
+	FunctionDeclarations []GeminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+// GeminiFunctionDeclaration describes a tool as a function, with its input
+// schema given as JSON Schema
+type GeminiFunctionDeclaration struct {
+	Name                 string                 `json:"name"`
+	Description          string                 `json:"description"`
+	ParametersJSONSchema map[string]interface{} `json:"parametersJsonSchema,omitempty"`
+}
+
+// GeminiGenerationConfig represents the sampling parameters for Gemini API
+type GeminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+	Seed            *int64   `json:"seed,omitempty"`
 }
 
 // GeminiContent represents content in Gemini format
@@ -65,9 +87,26 @@ type GeminiContent struct {
 	Parts []GeminiPart `json:"parts"`
 }
 
-// GeminiPart represents a part of content
+// GeminiPart represents a part of content. Each part holds one of text, a
+// function call or a function response.
 type GeminiPart struct {
-	Text string `json:"text"`
+	Text             string                  `json:"text,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// GeminiFunctionCall is a call the model made to a declared function
+type GeminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+// GeminiFunctionResponse returns the result of a function call to the model
+type GeminiFunctionResponse struct {
+	ID       string                 `json:"id,omitempty"`
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
 }
 
 // GeminiResponse represents the response from Gemini API
@@ -78,54 +117,180 @@ type GeminiResponse struct {
 
 // GeminiCandidate represents a response candidate
 type GeminiCandidate struct {
-	Content GeminiContent `json:"content"`
+	Content      GeminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"` // set on the last chunk of a stream
 }
 
 // GeminiError represents an error from Gemini API
 type GeminiError struct {
-	Message string `json:"message"`
-	Code    int    `json:"code"`
+	Message string              `json:"message"`
+	Code    int                 `json:"code"`
+	Details []GeminiErrorDetail `json:"details,omitempty"`
+}
+
+// GeminiErrorDetail carries structured error details such as google.rpc.RetryInfo
+type GeminiErrorDetail struct {
+	Type       string `json:"@type"`
+	RetryDelay string `json:"retryDelay,omitempty"`
 }
 
-// handleAPIError converts Gemini API errors to user-friendly errors
-func (g *GeminiProvider) handleAPIError(statusCode int, geminiErr *GeminiError) error {
+// handleAPIError converts Gemini API errors to typed provider errors
+func (g *GeminiProvider) handleAPIError(statusCode int, header http.Header, geminiErr *GeminiError) error {
 	if geminiErr == nil {
-		switch statusCode {
-		case 429:
-			return fmt.Errorf("%w: please check your plan and billing details", ErrRateLimited)
-		case 401, 403:
-			return fmt.Errorf("%w: please check your API key", ErrAPIKey)
-		case 500, 502, 503, 504:
-			return fmt.Errorf("%w: try again later", ErrServiceDown)
-		default:
-			return fmt.Errorf("HTTP error %d", statusCode)
-		}
+		return NewHTTPError(g.Name(), statusCode, header, "")
 	}
 
-	// Handle specific error codes from Gemini
-	switch geminiErr.Code {
-	case 429:
-		if strings.Contains(strings.ToLower(geminiErr.Message), "quota") {
-			return fmt.Errorf("%w: %s", ErrQuotaExceeded, geminiErr.Message)
+	// Prefer the code reported in the error body over the HTTP status
+	code := statusCode
+	if geminiErr.Code != 0 {
+		code = geminiErr.Code
+	}
+
+	apiErr := NewHTTPError(g.Name(), code, header, geminiErr.Message)
+
+	// Gemini reports how long to back off in a RetryInfo detail instead of Retry-After
+	for _, detail := range geminiErr.Details {
+		if detail.RetryDelay == "" {
+			continue
+		}
+		if delay, err := time.ParseDuration(detail.RetryDelay); err == nil && delay > apiErr.RetryAfter {
+			apiErr.RetryAfter = delay
 		}
-		return fmt.Errorf("%w: %s", ErrRateLimited, geminiErr.Message)
-	case 400:
-		return fmt.Errorf("%w: %s", ErrInvalidRequest, geminiErr.Message)
-	case 401, 403:
-		return fmt.Errorf("%w: %s", ErrAPIKey, geminiErr.Message)
-	case 500, 502, 503, 504:
-		return fmt.Errorf("%w: %s", ErrServiceDown, geminiErr.Message)
-	default:
-		return fmt.Errorf("gemini API error (%d): %s", geminiErr.Code, geminiErr.Message)
 	}
+
+	return apiErr
 }
 
 // GenerateResponse generates a response using Gemini
 func (g *GeminiProvider) GenerateResponse(ctx context.Context, conversation []agent.Message) (*agent.Response, error) {
+	resp, err := g.doGenerate(ctx, conversation, "generateContent")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var geminiResp GeminiResponse
+	if err := json.Unmarshal(body, &geminiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Handle API errors from response
+	if geminiResp.Error != nil {
+		return nil, g.handleAPIError(resp.StatusCode, resp.Header, geminiResp.Error)
+	}
+
+	if len(geminiResp.Candidates) == 0 {
+		return nil, fmt.Errorf("no response candidates received")
+	}
+
+	var content strings.Builder
+	var calls []*GeminiFunctionCall
+	for _, part := range geminiResp.Candidates[0].Content.Parts {
+		content.WriteString(part.Text)
+		if part.FunctionCall != nil {
+			calls = append(calls, part.FunctionCall)
+		}
+	}
+
+	return g.buildResponse(content.String(), calls), nil
+}
+
+// GenerateResponseStream generates a response using Gemini's streamGenerateContent
+// endpoint, calling onToken for every chunk of text as it arrives
+func (g *GeminiProvider) GenerateResponseStream(ctx context.Context, conversation []agent.Message, onToken func(token string)) (*agent.Response, error) {
+	resp, err := g.doGenerate(ctx, conversation, "streamGenerateContent")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// With alt=sse every chunk arrives as a "data: {...}" line
+	var content strings.Builder
+	var calls []*GeminiFunctionCall
+	received, finished := false, false
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var chunk GeminiResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+
+		if chunk.Error != nil {
+			return nil, g.handleAPIError(resp.StatusCode, resp.Header, chunk.Error)
+		}
+
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+		received = true
+		finished = finished || chunk.Candidates[0].FinishReason != ""
+
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			if part.FunctionCall != nil {
+				calls = append(calls, part.FunctionCall)
+			}
+			if part.Text == "" {
+				continue
+			}
+			content.WriteString(part.Text)
+			onToken(part.Text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read response stream: %w", err)
+	}
+
+	if !received {
+		return nil, fmt.Errorf("no response candidates received")
+	}
+	if !finished {
+		return nil, &APIError{Provider: g.Name(), Kind: ErrNetwork, Message: "the response stream ended before it was complete"}
+	}
+
+	return g.buildResponse(content.String(), calls), nil
+}
+
+// buildResponse converts the text and function calls of a reply to a response.
+// Models that write TOOL_CALL lines instead of calling functions still work.
+func (g *GeminiProvider) buildResponse(text string, calls []*GeminiFunctionCall) *agent.Response {
+	response := &agent.Response{Content: text}
+	for _, call := range calls {
+		input := call.Args
+		if len(input) == 0 || string(input) == "null" {
+			input = json.RawMessage("{}")
+		}
+		response.ToolCalls = append(response.ToolCalls, agent.ToolCall{
+			ID:       toolproto.NewCallID(),
+			Name:     call.Name,
+			Function: call.Name,
+			Input:    input,
+		})
+	}
+	if len(response.ToolCalls) == 0 {
+		response.ToolCalls = g.ParseToolCalls(text)
+	}
+	return response
+}
+
+// doGenerate calls the given Gemini model method and returns the successful HTTP response
+func (g *GeminiProvider) doGenerate(ctx context.Context, conversation []agent.Message, method string) (*http.Response, error) {
 	contents := g.buildContents(conversation)
 
 	request := GeminiRequest{
-		Contents: contents,
+		Contents:         contents,
+		Tools:            g.buildTools(),
+		GenerationConfig: g.buildGenerationConfig(),
 	}
 
 	requestBody, err := json.Marshal(request)
@@ -133,7 +298,10 @@ func (g *GeminiProvider) GenerateResponse(ctx context.Context, conversation []ag
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", g.model, g.apiKey)
+	url := fmt.Sprintf("%s/v1beta/models/%s:%s?key=%s", g.baseURL, g.model, method, g.apiKey)
+	if method == "streamGenerateContent" {
+		url += "&alt=sse"
+	}
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -143,48 +311,55 @@ func (g *GeminiProvider) GenerateResponse(ctx context.Context, conversation []ag
 
 	resp, err := g.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request to Gemini: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, NewTransportError(g.Name(), err)
 	}
 
 	// Handle HTTP errors first
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+
 		var geminiResp GeminiResponse
 		// Try to parse error response, but don't fail if we can't
 		json.Unmarshal(body, &geminiResp)
-		return nil, g.handleAPIError(resp.StatusCode, geminiResp.Error)
+		return nil, g.handleAPIError(resp.StatusCode, resp.Header, geminiResp.Error)
 	}
 
-	var geminiResp GeminiResponse
-	if err := json.Unmarshal(body, &geminiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
+	return resp, nil
+}
 
-	// Handle API errors from response
-	if geminiResp.Error != nil {
-		return nil, g.handleAPIError(200, geminiResp.Error)
+// buildGenerationConfig converts the generation options to Gemini's generationConfig
+func (g *GeminiProvider) buildGenerationConfig() *GeminiGenerationConfig {
+	opts := g.options
+	if opts.IsZero() {
+		return nil
 	}
 
-	if len(geminiResp.Candidates) == 0 {
-		return nil, fmt.Errorf("no response candidates received")
+	return &GeminiGenerationConfig{
+		Temperature:     opts.Temperature,
+		TopP:            opts.TopP,
+		MaxOutputTokens: opts.MaxOutputTokens,
+		StopSequences:   opts.Stop,
+		Seed:            opts.Seed,
 	}
+}
 
-	responseText := ""
-	if len(geminiResp.Candidates[0].Content.Parts) > 0 {
-		responseText = geminiResp.Candidates[0].Content.Parts[0].Text
+// buildTools declares every tool as a function Gemini can call
+func (g *GeminiProvider) buildTools() []GeminiTool {
+	defs := g.toolRegistry.GetToolDefinitions()
+	if len(defs) == 0 {
+		return nil
 	}
 
-	toolCalls := g.ParseToolCalls(responseText)
-
-	return &agent.Response{
-		Content:   responseText,
-		ToolCalls: toolCalls,
-	}, nil
+	declarations := make([]GeminiFunctionDeclaration, 0, len(defs))
+	for _, def := range defs {
+		declarations = append(declarations, GeminiFunctionDeclaration{
+			Name:                 def.Name,
+			Description:          def.Description,
+			ParametersJSONSchema: def.InputSchema,
+		})
+	}
+	return []GeminiTool{{FunctionDeclarations: declarations}}
 }
 
 func (g *GeminiProvider) buildContents(conversation []agent.Message) []GeminiContent {
@@ -192,11 +367,10 @@ func (g *GeminiProvider) buildContents(conversation []agent.Message) []GeminiCon
 
 	// Add system prompt as first user message if available
 	if g.systemPrompt != "" {
-		systemContent := g.buildSystemPrompt()
 		contents = append(contents, GeminiContent{
 			Role: "user",
 			Parts: []GeminiPart{
-				{Text: systemContent},
+				{Text: fmt.Sprintf("System: %s", g.systemPrompt)},
 			},
 		})
 		// Add a model response acknowledging the system prompt
@@ -208,217 +382,77 @@ func (g *GeminiProvider) buildContents(conversation []agent.Message) []GeminiCon
 		})
 	}
 
-	// Add conversation history
+	// Gemini rejects a function call without its response and the other way
+	// round, so only calls whose results are in the conversation are sent as
+	// function calls, e.g. not those of an interrupted turn
+	answered := map[string]bool{}
 	for _, msg := range conversation {
-		role := "user"
-		if msg.Role == "assistant" {
-			role = "model"
+		if result, ok := msg.Content.(agent.ToolResult); ok && msg.Role == "tool" {
+			answered[result.CallID] = true
 		}
-
-		content := fmt.Sprintf("%s", msg.Content)
-		if strings.HasPrefix(content, "Tool result: ") {
-			result := strings.TrimPrefix(content, "Tool result: ")
-			content = fmt.Sprintf("Tool Result:\n%s\n\nPlease present this information to the user.", result)
-
-			// If tool result contains a suggested tool call, make it very explicit
-			if strings.Contains(result, "TOOL_CALL:") {
-				content += "\n🚨 The tool result above contains a suggested TOOL_CALL. You MUST execute it immediately using the exact format shown!\n"
-				content += "Copy the TOOL_CALL line exactly as written in the tool result."
-			}
-		}
-
-		contents = append(contents, GeminiContent{
-			Role: role,
-			Parts: []GeminiPart{
-				{Text: content},
-			},
-		})
-	}
-
-	return contents
-}
-
-func (g *GeminiProvider) buildSystemPrompt() string {
-	var prompt string
-
-	// START WITH TOOL CALL REQUIREMENT - FIRST THING THE LLM SEES
-	tools := g.toolRegistry.GetAllTools()
-	if len(tools) > 0 {
-		prompt += "🚨🚨🚨 CRITICAL: YOU MUST USE TOOLS! 🚨🚨🚨\n"
-		prompt += "When user asks to log meals, you MUST use this EXACT format:\n"
-		prompt += "TOOL_CALL: fitbit_log_meal({\"meal_type\": \"breakfast\", \"foods\": [{\"name\": \"scrambled eggs\", \"quantity\": 2, \"unit\": \"large\", \"calories\": 140}]})\n\n"
-		prompt += "DO NOT just say 'I'll log it' - ACTUALLY CALL THE TOOL!\n\n"
 	}
+	called := map[string]bool{}
 
-	prompt += fmt.Sprintf("System: %s\n\n", g.systemPrompt)
-
-	if len(tools) > 0 {
-		prompt += "🚨 AVAILABLE TOOLS:\n"
-		for _, tool := range tools {
-			prompt += fmt.Sprintf("- %s: %s\n", tool.Name(), tool.Description())
-		}
-		prompt += "\n🚨🚨🚨 TOOL CALL FORMAT RULES - FOLLOW EXACTLY! 🚨🚨🚨\n"
-		prompt += "1. Make ONLY ONE tool call per response\n"
-		prompt += "2. Use EXACT format: TOOL_CALL: tool_name(json)\n"
-		prompt += "3. NO extra text after the closing parenthesis )\n"
-		prompt += "4. NO semicolons, commas, or explanations after )\n"
-		prompt += "5. JSON must be valid and complete\n"
-		prompt += "6. End the line immediately after the )\n"
-		prompt += "7. DO NOT repeat tool calls multiple times\n"
-		prompt += "Example: TOOL_CALL: fitbit_log_meal({\"meal_type\": \"breakfast\", \"foods\": [{\"name\": \"eggs\", \"quantity\": 2, \"unit\": \"large\", \"calories\": 140}]})\n"
-		prompt += "WRONG: TOOL_CALL: fitbit_log_meal({...}); followed by explanation\n"
-		prompt += "WRONG: Making multiple identical tool calls\n"
-		prompt += "RIGHT: TOOL_CALL: fitbit_log_meal({...})\n\n"
-	}
-
-	return prompt
-}
-
-func (g *GeminiProvider) ParseToolCalls(response string) []agent.ToolCall {
-	var toolCalls []agent.ToolCall
-
-	// Primary pattern: TOOL_CALL: tool_name(json) - find the tool call start and manually parse the content
-	re := regexp.MustCompile(`TOOL_CALL:\s*(\w+)\s*\(`)
-	matches := re.FindAllStringSubmatchIndex(response, -1)
-
-	for i, match := range matches {
-		if len(match) >= 4 {
-			toolName := response[match[2]:match[3]]
-
-			// Find the opening parenthesis position
-			openParenPos := match[1] - 1 // match[1] is after the (, so -1 to get the ( position
-
-			// Manually parse from the opening parenthesis to find the matching closing one
-			jsonContent := extractJSONManually(response, openParenPos)
-
-			// Clean up the JSON content - only remove trailing semicolons
-			jsonContent = fixCommonJSONIssues(jsonContent)
-
-			if jsonContent == "" {
-				continue
+	// Add conversation history
+	for i, msg := range conversation {
+		switch msg.Role {
+		case "assistant":
+			var parts []GeminiPart
+			if text, _ := msg.Content.(string); text != "" {
+				parts = append(parts, GeminiPart{Text: text})
 			}
-
-			var input json.RawMessage
-			if json.Valid([]byte(jsonContent)) {
-				input = json.RawMessage(jsonContent)
-			} else {
-				// If still invalid, wrap in input field for debugging
-				escapedInput := fmt.Sprintf("{\"input\": %q}", jsonContent)
-				input = json.RawMessage(escapedInput)
+			for _, call := range msg.ToolCalls {
+				if !answered[call.ID] {
+					continue
+				}
+				called[call.ID] = true
+				parts = append(parts, GeminiPart{FunctionCall: &GeminiFunctionCall{ID: call.ID, Name: call.Name, Args: call.Input}})
 			}
-
-			toolCall := agent.ToolCall{
-				ID:       fmt.Sprintf("call_%d", i),
-				Name:     toolName,
-				Function: toolName,
-				Input:    input,
+			if len(parts) == 0 {
+				continue
 			}
-
-			toolCalls = append(toolCalls, toolCall)
-		}
-	}
-
-	// Fallback patterns for other formats
-	if len(toolCalls) == 0 {
-		// Pattern 2: ```tool_call\nfitbit_log_meal({"meal_type": "breakfast"})\n```
-		re2 := regexp.MustCompile("```tool_call\\s*\\n([\\w_]+)\\s*\\(([^)]*)\\)\\s*\\n```")
-		matches2 := re2.FindAllStringSubmatch(response, -1)
-
-		for i, match := range matches2 {
-			if len(match) >= 3 {
-				toolName := match[1]
-				inputStr := match[2]
-
-				var input json.RawMessage
-				if inputStr == "" {
-					input = json.RawMessage("{}")
-				} else if json.Valid([]byte(inputStr)) {
-					input = json.RawMessage(inputStr)
-				} else {
-					escapedInput := fmt.Sprintf("{\"input\": %q}", inputStr)
-					input = json.RawMessage(escapedInput)
-				}
-
-				toolCall := agent.ToolCall{
-					ID:       fmt.Sprintf("call_%d", len(toolCalls)+i),
-					Name:     toolName,
-					Function: toolName,
-					Input:    input,
-				}
-
-				toolCalls = append(toolCalls, toolCall)
+			contents = append(contents, GeminiContent{Role: "model", Parts: parts})
+		case "tool":
+			result, _ := msg.Content.(agent.ToolResult)
+			part := GeminiPart{Text: toolproto.RenderResult(result)}
+			if called[result.CallID] {
+				part = GeminiPart{FunctionResponse: &GeminiFunctionResponse{
+					ID:       result.CallID,
+					Name:     result.Name,
+					Response: functionResponse(result),
+				}}
 			}
-		}
-	}
-
-	// Pattern 3: Try: Call tool_name with {...}
-	if len(toolCalls) == 0 {
-		re3 := regexp.MustCompile(`Call\s+(\w+)\s+with\s+({[^}]*})`)
-		matches3 := re3.FindAllStringSubmatch(response, -1)
-
-		for i, match := range matches3 {
-			if len(match) >= 3 {
-				toolName := match[1]
-				inputStr := match[2]
-
-				var input json.RawMessage
-				if json.Valid([]byte(inputStr)) {
-					input = json.RawMessage(inputStr)
-				} else {
-					escapedInput := fmt.Sprintf("{\"input\": %q}", inputStr)
-					input = json.RawMessage(escapedInput)
-				}
-
-				toolCall := agent.ToolCall{
-					ID:       fmt.Sprintf("call_%d", i),
-					Name:     toolName,
-					Function: toolName,
-					Input:    input,
-				}
 
-				toolCalls = append(toolCalls, toolCall)
+			// Results of calls made in the same turn are sent together
+			if i > 0 && conversation[i-1].Role == "tool" {
+				last := &contents[len(contents)-1]
+				last.Parts = append(last.Parts, part)
+				continue
 			}
+			contents = append(contents, GeminiContent{
+				Role:  "user",
+				Parts: []GeminiPart{part},
+			})
+		default:
+			contents = append(contents, GeminiContent{
+				Role:  "user",
+				Parts: []GeminiPart{{Text: fmt.Sprintf("%s", msg.Content)}},
+			})
 		}
 	}
 
-	// Pattern 4: Just function calls in the text without special markers (only for known tools)
-	if len(toolCalls) == 0 {
-		re4 := regexp.MustCompile(`(\w+)\((\{[^}]*\})\)`)
-		matches4 := re4.FindAllStringSubmatch(response, -1)
-
-		// Only use pattern 4 if the function name looks like a tool
-		tools := g.toolRegistry.GetAllTools()
-		toolNames := make(map[string]bool)
-		for _, tool := range tools {
-			toolNames[tool.Name()] = true
-		}
+	return contents
+}
 
-		for i, match := range matches4 {
-			if len(match) >= 3 {
-				toolName := match[1]
-				inputStr := match[2]
-
-				// Only treat as tool call if it's a known tool name
-				if toolNames[toolName] {
-					var input json.RawMessage
-					if json.Valid([]byte(inputStr)) {
-						input = json.RawMessage(inputStr)
-					} else {
-						continue // Skip invalid JSON
-					}
-
-					toolCall := agent.ToolCall{
-						ID:       fmt.Sprintf("call_%d", i),
-						Name:     toolName,
-						Function: toolName,
-						Input:    input,
-					}
-
-					toolCalls = append(toolCalls, toolCall)
-				}
-			}
-		}
+// functionResponse wraps a tool result in the output or error field Gemini expects
+func functionResponse(result agent.ToolResult) map[string]interface{} {
+	if result.IsError {
+		return map[string]interface{}{"error": result.Content}
 	}
+	return map[string]interface{}{"output": result.Content}
+}
 
-	return toolCalls
+// ParseToolCalls extracts tool calls from a Gemini response
+func (g *GeminiProvider) ParseToolCalls(response string) []agent.ToolCall {
+	return toolproto.Parse(response, toolproto.Names(g.toolRegistry.GetToolDefinitions()))
 }
@@ -0,0 +1,87 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+	"github.com/vhbfernandes/fitbit-agent/pkg/config"
+)
+
+// definitionRegistry is a tool registry that only lists tool definitions
+type definitionRegistry struct {
+	emptyRegistry
+	defs []agent.ToolDefinition
+}
+
+func (r definitionRegistry) GetToolDefinitions() []agent.ToolDefinition { return r.defs }
+
+func TestGeminiFunctionCalling(t *testing.T) {
+	var request GeminiRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		request = GeminiRequest{}
+		json.Unmarshal(body, &request)
+		io.WriteString(w, `{"candidates":[{"content":{"role":"model","parts":[
+			{"functionCall":{"name":"lookup_food_calories","args":{"food":"toast"}}},
+			{"functionCall":{"name":"lookup_food_calories","args":{"food":"eggs"}}}
+		]},"finishReason":"STOP"}]}`)
+	}))
+	defer server.Close()
+
+	registry := definitionRegistry{defs: []agent.ToolDefinition{{
+		Name:        "lookup_food_calories",
+		Description: "Looks up calories",
+		InputSchema: map[string]interface{}{"type": "object", "properties": map[string]interface{}{"food": map[string]interface{}{"type": "string"}}},
+	}}}
+	provider := NewGeminiProvider("test-key", "test", registry, "", config.GenerationOptions{})
+	provider.baseURL = server.URL
+
+	conversation := []agent.Message{{Role: "user", Content: "toast and eggs"}}
+	resp, err := provider.GenerateResponse(context.Background(), conversation)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if len(request.Tools) != 1 || request.Tools[0].FunctionDeclarations[0].Name != "lookup_food_calories" {
+		t.Errorf("Expected the tool to be declared as a function but got %+v", request.Tools)
+	}
+	if len(resp.ToolCalls) != 2 || string(resp.ToolCalls[1].Input) != `{"food":"eggs"}` {
+		t.Fatalf("Expected two native tool calls but got %+v", resp.ToolCalls)
+	}
+	if resp.ToolCalls[0].ID == resp.ToolCalls[1].ID {
+		t.Errorf("Expected unique call IDs but got %s twice", resp.ToolCalls[0].ID)
+	}
+
+	// The results go back as function responses matched to the calls
+	conversation = append(conversation,
+		agent.Message{Role: "assistant", ToolCalls: resp.ToolCalls},
+		agent.Message{Role: "tool", Content: agent.ToolResult{CallID: resp.ToolCalls[0].ID, Name: "lookup_food_calories", Content: "80 kcal"}},
+		agent.Message{Role: "tool", Content: agent.ToolResult{CallID: resp.ToolCalls[1].ID, Name: "lookup_food_calories", Content: "not found", IsError: true}},
+	)
+	if _, err := provider.GenerateResponse(context.Background(), conversation); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if len(request.Contents) != 3 {
+		t.Fatalf("Expected user, model and function response contents but got %+v", request.Contents)
+	}
+	model, results := request.Contents[1], request.Contents[2]
+	if len(model.Parts) != 2 || model.Parts[0].FunctionCall == nil || model.Parts[0].FunctionCall.ID != resp.ToolCalls[0].ID {
+		t.Errorf("Expected the calls to be sent as function calls but got %+v", model.Parts)
+	}
+	if len(results.Parts) != 2 || results.Parts[1].FunctionResponse == nil {
+		t.Fatalf("Expected both results in one function response content but got %+v", results.Parts)
+	}
+	response := results.Parts[1].FunctionResponse
+	if response.ID != resp.ToolCalls[1].ID || response.Response["error"] != "not found" {
+		t.Errorf("Expected the error result for the second call but got %+v", response)
+	}
+	if results.Parts[0].FunctionResponse.Response["output"] != "80 kcal" || results.Parts[0].Text != "" {
+		t.Errorf("Expected only the function response for the first call but got %+v", results.Parts[0])
+	}
+}
@@ -0,0 +1,125 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+// RetryPolicy controls how transient provider errors are retried
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// OnRetry is called before sleeping ahead of the next attempt (optional)
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+	}
+}
+
+// RetryProvider wraps an LLMProvider and retries transient failures with exponential backoff
+type RetryProvider struct {
+	provider agent.LLMProvider
+	policy   RetryPolicy
+}
+
+// NewRetryProvider creates a provider that retries the wrapped provider according to policy
+func NewRetryProvider(provider agent.LLMProvider, policy RetryPolicy) *RetryProvider {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 1
+	}
+
+	return &RetryProvider{
+		provider: provider,
+		policy:   policy,
+	}
+}
+
+// Name returns the name of the wrapped provider
+func (r *RetryProvider) Name() string {
+	return r.provider.Name()
+}
+
+// GenerateResponse calls the wrapped provider, retrying transient errors
+func (r *RetryProvider) GenerateResponse(ctx context.Context, conversation []agent.Message) (*agent.Response, error) {
+	return r.do(ctx, func() (*agent.Response, bool, error) {
+		response, err := r.provider.GenerateResponse(ctx, conversation)
+		return response, false, err
+	})
+}
+
+// GenerateResponseStream streams from the wrapped provider when it supports it.
+// A request is only retried if it failed before any token reached the caller.
+func (r *RetryProvider) GenerateResponseStream(ctx context.Context, conversation []agent.Message, onToken func(token string)) (*agent.Response, error) {
+	streamer, ok := r.provider.(agent.StreamingLLMProvider)
+	if !ok {
+		response, err := r.GenerateResponse(ctx, conversation)
+		if err == nil && response.Content != "" {
+			onToken(response.Content)
+		}
+		return response, err
+	}
+
+	return r.do(ctx, func() (*agent.Response, bool, error) {
+		emitted := false
+		response, err := streamer.GenerateResponseStream(ctx, conversation, func(token string) {
+			emitted = true
+			onToken(token)
+		})
+		return response, emitted, err
+	})
+}
+
+// do runs attempt until it succeeds, fails permanently or the policy is exhausted
+func (r *RetryProvider) do(ctx context.Context, attempt func() (*agent.Response, bool, error)) (*agent.Response, error) {
+	backoff := r.policy.InitialBackoff
+
+	for n := 1; ; n++ {
+		response, emitted, err := attempt()
+		if err == nil || emitted || !IsRetryable(err) || n >= r.policy.MaxAttempts {
+			return response, err
+		}
+
+		delay := backoff
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			delay = apiErr.RetryAfter
+		}
+		if r.policy.MaxBackoff > 0 && delay > r.policy.MaxBackoff {
+			// The service asked us to wait longer than we are willing to
+			if apiErr != nil && apiErr.RetryAfter > r.policy.MaxBackoff {
+				return nil, err
+			}
+			delay = r.policy.MaxBackoff
+		}
+
+		if r.policy.OnRetry != nil {
+			r.policy.OnRetry(n+1, delay, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff = time.Duration(float64(backoff) * r.policy.Multiplier)
+	}
+}
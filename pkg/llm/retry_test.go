@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+// flakyProvider fails with the queued errors before succeeding
+type flakyProvider struct {
+	errs  []error
+	calls int
+}
+
+func (f *flakyProvider) Name() string { return "flaky" }
+
+func (f *flakyProvider) GenerateResponse(ctx context.Context, conversation []agent.Message) (*agent.Response, error) {
+	f.calls++
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	return &agent.Response{Content: "ok"}, nil
+}
+
+func TestRetryProvider(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, Multiplier: 2}
+
+	testCases := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{
+			name:      "Transient errors are retried",
+			errs:      []error{NewHTTPError("flaky", 503, nil, ""), NewHTTPError("flaky", 502, nil, "")},
+			wantCalls: 3,
+		},
+		{
+			name:      "Gives up after max attempts",
+			errs:      []error{NewHTTPError("flaky", 503, nil, ""), NewHTTPError("flaky", 503, nil, ""), NewHTTPError("flaky", 503, nil, "")},
+			wantCalls: 3,
+			wantErr:   ErrServiceDown,
+		},
+		{
+			name:      "Quota errors are not retried",
+			errs:      []error{NewHTTPError("flaky", 429, nil, "Quota exceeded for requests")},
+			wantCalls: 1,
+			wantErr:   ErrQuotaExceeded,
+		},
+		{
+			name:      "Retry-After longer than max backoff is not waited for",
+			errs:      []error{NewHTTPError("flaky", 429, http.Header{"Retry-After": []string{"120"}}, "")},
+			wantCalls: 1,
+			wantErr:   ErrRateLimited,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			inner := &flakyProvider{errs: tc.errs}
+			provider := NewRetryProvider(inner, policy)
+
+			_, err := provider.GenerateResponse(context.Background(), nil)
+
+			if inner.calls != tc.wantCalls {
+				t.Errorf("Expected %d calls but got %d", tc.wantCalls, inner.calls)
+			}
+			if tc.wantErr == nil && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("Expected error %v but got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
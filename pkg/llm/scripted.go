@@ -0,0 +1,194 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+	"github.com/vhbfernandes/fitbit-agent/pkg/llm/toolproto"
+)
+
+// Script is a sequence of canned LLM turns replayed by ScriptedProvider. Scripts
+// are stored as JSON so agent runs can be tested without a live model.
+type Script struct {
+	Name  string       `json:"name,omitempty"`
+	Turns []ScriptTurn `json:"turns"`
+}
+
+// ScriptTurn is the response to a single GenerateResponse call
+type ScriptTurn struct {
+	Expect    *ScriptExpectation `json:"expect,omitempty"`
+	Content   string             `json:"content,omitempty"` // parsed for TOOL_CALL lines when ToolCalls is empty
+	ToolCalls []ScriptToolCall   `json:"tool_calls,omitempty"`
+	Error     string             `json:"error,omitempty"` // e.g. "quota_exceeded", "service_unavailable"
+}
+
+// ScriptToolCall is a tool call returned by a scripted turn
+type ScriptToolCall struct {
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+// ScriptExpectation describes the conversation a scripted turn expects to receive
+type ScriptExpectation struct {
+	MessageCount int      `json:"message_count,omitempty"`
+	LastRole     string   `json:"last_role,omitempty"`
+	Contains     []string `json:"contains,omitempty"` // substrings of the last message
+}
+
+// scriptErrors maps script error names to provider error kinds
+var scriptErrors = map[string]error{
+	"quota_exceeded":      ErrQuotaExceeded,
+	"rate_limited":        ErrRateLimited,
+	"api_key":             ErrAPIKey,
+	"service_unavailable": ErrServiceDown,
+	"invalid_request":     ErrInvalidRequest,
+	"network":             ErrNetwork,
+	"timeout":             ErrTimeout,
+}
+
+// LoadScript reads a script from a JSON file
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+
+	var script Script
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("failed to parse script %s: %w", path, err)
+	}
+
+	for i, turn := range script.Turns {
+		if turn.Error != "" && scriptErrors[turn.Error] == nil {
+			return nil, fmt.Errorf("script turn %d: unknown error %q", i+1, turn.Error)
+		}
+	}
+
+	return &script, nil
+}
+
+// ScriptedProvider implements the LLMProvider interface by replaying a script.
+// Every conversation it receives is recorded and checked against the turn's
+// expectations; a mismatch fails the call and is reported by Failures.
+type ScriptedProvider struct {
+	script        *Script
+	next          int
+	conversations [][]agent.Message
+	failures      []string
+	mu            sync.Mutex
+}
+
+// NewScriptedProvider creates a provider that replays the given script
+func NewScriptedProvider(script *Script) *ScriptedProvider {
+	return &ScriptedProvider{
+		script: script,
+	}
+}
+
+// Name returns the provider name
+func (s *ScriptedProvider) Name() string {
+	return "Scripted"
+}
+
+// GenerateResponse returns the next scripted turn
+func (s *ScriptedProvider) GenerateResponse(ctx context.Context, conversation []agent.Message) (*agent.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conversations = append(s.conversations, append([]agent.Message{}, conversation...))
+
+	if s.next >= len(s.script.Turns) {
+		failure := fmt.Sprintf("call %d: script exhausted after %d turns", s.next+1, len(s.script.Turns))
+		s.failures = append(s.failures, failure)
+		return nil, fmt.Errorf("scripted provider: %s", failure)
+	}
+
+	turn := s.script.Turns[s.next]
+	s.next++
+
+	if turn.Expect != nil {
+		if mismatch := turn.Expect.check(conversation); mismatch != "" {
+			failure := fmt.Sprintf("turn %d: %s", s.next, mismatch)
+			s.failures = append(s.failures, failure)
+			return nil, fmt.Errorf("scripted provider: %s", failure)
+		}
+	}
+
+	if turn.Error != "" {
+		return nil, &APIError{Provider: s.Name(), Kind: scriptErrors[turn.Error], Message: "scripted error"}
+	}
+
+	response := &agent.Response{Content: turn.Content}
+	if len(turn.ToolCalls) == 0 {
+		// Let scripts exercise the text tool-call protocol
+		response.ToolCalls = toolproto.Parse(turn.Content, nil)
+	}
+	for _, call := range turn.ToolCalls {
+		input := call.Input
+		if len(input) == 0 {
+			input = json.RawMessage("{}")
+		}
+		response.ToolCalls = append(response.ToolCalls, agent.ToolCall{
+			ID:       toolproto.NewCallID(),
+			Name:     call.Name,
+			Function: call.Name,
+			Input:    input,
+		})
+	}
+
+	return response, nil
+}
+
+// Conversations returns every conversation the provider received, in order
+func (s *ScriptedProvider) Conversations() [][]agent.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conversations
+}
+
+// Failures returns the expectation failures recorded so far
+func (s *ScriptedProvider) Failures() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failures
+}
+
+// Remaining returns the number of turns that have not been replayed yet
+func (s *ScriptedProvider) Remaining() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.script.Turns) - s.next
+}
+
+// check returns a description of how the conversation differs from the expectation
+func (e *ScriptExpectation) check(conversation []agent.Message) string {
+	if e.MessageCount > 0 && len(conversation) != e.MessageCount {
+		return fmt.Sprintf("expected %d messages, got %d", e.MessageCount, len(conversation))
+	}
+
+	if len(conversation) == 0 {
+		if e.LastRole != "" || len(e.Contains) > 0 {
+			return "expected a message, got an empty conversation"
+		}
+		return ""
+	}
+
+	last := conversation[len(conversation)-1]
+	if e.LastRole != "" && last.Role != e.LastRole {
+		return fmt.Sprintf("expected last message role %q, got %q", e.LastRole, last.Role)
+	}
+
+	content := fmt.Sprintf("%s", last.Content)
+	for _, want := range e.Contains {
+		if !strings.Contains(content, want) {
+			return fmt.Sprintf("expected last message to contain %q, got %q", want, content)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,193 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+	"github.com/vhbfernandes/fitbit-agent/pkg/config"
+)
+
+// emptyRegistry is a tool registry without tools
+type emptyRegistry struct{}
+
+func (emptyRegistry) GetTool(name string) (agent.Tool, bool)     { return nil, false }
+func (emptyRegistry) GetAllTools() []agent.Tool                  { return nil }
+func (emptyRegistry) RegisterTool(tool agent.Tool)               {}
+func (emptyRegistry) GetToolDefinitions() []agent.ToolDefinition { return nil }
+func (emptyRegistry) ValidateInput(name string, input json.RawMessage) (json.RawMessage, error) {
+	return input, nil
+}
+
+// streamServer serves writes one at a time, flushing after each, so a chunk
+// can be split across reads
+func streamServer(t *testing.T, status int, writes []string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		for _, write := range writes {
+			w.Write([]byte(write))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOllamaStream(t *testing.T) {
+	testCases := []struct {
+		name        string
+		status      int
+		writes      []string
+		wantContent string
+		wantTokens  int
+		wantErr     error
+	}{
+		{
+			name:   "Chunks split across reads",
+			status: http.StatusOK,
+			writes: []string{
+				`{"response":"Hel","done":false}` + "\n" + `{"respo`,
+				`nse":"lo","done":false}` + "\n",
+				`{"response":"","done":true}` + "\n",
+			},
+			wantContent: "Hello",
+			wantTokens:  2,
+		},
+		{
+			name:    "Stream ends before done",
+			status:  http.StatusOK,
+			writes:  []string{`{"response":"Hel","done":false}` + "\n"},
+			wantErr: ErrNetwork,
+		},
+		{
+			name:    "Stream cut off inside a chunk",
+			status:  http.StatusOK,
+			writes:  []string{`{"response":"Hel","done":false}` + "\n" + `{"respo`},
+			wantErr: ErrNetwork,
+		},
+		{
+			name:    "Error reported in the stream",
+			status:  http.StatusOK,
+			writes:  []string{`{"response":"Hel","done":false}` + "\n" + `{"error":"model runner has unexpectedly stopped"}` + "\n"},
+			wantErr: ErrServiceDown,
+		},
+		{
+			name:    "Invalid request reported in the stream",
+			status:  http.StatusOK,
+			writes:  []string{`{"error":"model \"test\" not found, try pulling it first"}` + "\n"},
+			wantErr: ErrInvalidRequest,
+		},
+		{
+			name:    "Error status",
+			status:  http.StatusServiceUnavailable,
+			writes:  []string{`{"error":"server busy"}`},
+			wantErr: ErrServiceDown,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := streamServer(t, tc.status, tc.writes)
+			provider := NewDeepSeekProvider(server.URL, "test", emptyRegistry{}, "", config.GenerationOptions{})
+
+			var tokens []string
+			resp, err := provider.GenerateResponseStream(context.Background(), []agent.Message{{Role: "user", Content: "hi"}}, func(token string) {
+				tokens = append(tokens, token)
+			})
+
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("Expected error %v but got: %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if resp.Content != tc.wantContent {
+				t.Errorf("Expected content %q but got %q", tc.wantContent, resp.Content)
+			}
+			if len(tokens) != tc.wantTokens || strings.Join(tokens, "") != tc.wantContent {
+				t.Errorf("Expected %d tokens making %q but got %q", tc.wantTokens, tc.wantContent, tokens)
+			}
+		})
+	}
+}
+
+func TestGeminiStream(t *testing.T) {
+	testCases := []struct {
+		name        string
+		status      int
+		writes      []string
+		wantContent string
+		wantTokens  int
+		wantErr     error
+	}{
+		{
+			name:   "Events split across reads",
+			status: http.StatusOK,
+			writes: []string{
+				`data: {"candidates":[{"content":{"parts":[{"text":"Hel"}]}}]}` + "\n\n" + `data: {"candidates":[{"con`,
+				`tent":{"parts":[{"text":"lo"}]}}]}` + "\n\n",
+				`data: {"candidates":[{"content":{"parts":[{"text":""}]},"finishReason":"STOP"}]}` + "\n\n",
+			},
+			wantContent: "Hello",
+			wantTokens:  2,
+		},
+		{
+			name:    "Stream ends before the finish reason",
+			status:  http.StatusOK,
+			writes:  []string{`data: {"candidates":[{"content":{"parts":[{"text":"Hel"}]}}]}` + "\n\n"},
+			wantErr: ErrNetwork,
+		},
+		{
+			name:   "Error reported in the stream",
+			status: http.StatusOK,
+			writes: []string{
+				`data: {"candidates":[{"content":{"parts":[{"text":"Hel"}]}}]}` + "\n\n",
+				`data: {"error":{"code":503,"message":"The model is overloaded"}}` + "\n\n",
+			},
+			wantErr: ErrServiceDown,
+		},
+		{
+			name:    "Error status",
+			status:  http.StatusTooManyRequests,
+			writes:  []string{`{"error":{"code":429,"message":"Resource has been exhausted"}}`},
+			wantErr: ErrRateLimited,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := streamServer(t, tc.status, tc.writes)
+			provider := NewGeminiProvider("test-key", "test", emptyRegistry{}, "", config.GenerationOptions{})
+			provider.baseURL = server.URL
+
+			var tokens []string
+			resp, err := provider.GenerateResponseStream(context.Background(), []agent.Message{{Role: "user", Content: "hi"}}, func(token string) {
+				tokens = append(tokens, token)
+			})
+
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("Expected error %v but got: %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if resp.Content != tc.wantContent {
+				t.Errorf("Expected content %q but got %q", tc.wantContent, resp.Content)
+			}
+			if len(tokens) != tc.wantTokens || strings.Join(tokens, "") != tc.wantContent {
+				t.Errorf("Expected %d tokens making %q but got %q", tc.wantTokens, tc.wantContent, tokens)
+			}
+		})
+	}
+}
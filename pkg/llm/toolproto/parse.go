@@ -0,0 +1,297 @@
+package toolproto
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+var (
+	// toolCallPattern matches the start of TOOL_CALL: tool_name(
+	toolCallPattern = regexp.MustCompile(`TOOL_CALL:\s*` + "`?" + `([A-Za-z_][\w.-]*)\s*\(`)
+
+	// fencePattern matches fenced code blocks such as ```tool_call or ```json
+	fencePattern = regexp.MustCompile("(?s)```[ \\t]*([\\w-]*)[ \\t]*\\n?(.*?)```")
+
+	// callWithPattern matches the start of "Call tool_name with {"
+	callWithPattern = regexp.MustCompile(`Call\s+([A-Za-z_][\w.-]*)\s+with\s+\{`)
+
+	// bareCallPattern matches the start of tool_name({ for known tools only
+	bareCallPattern = regexp.MustCompile(`([A-Za-z_][\w.-]*)\(\s*\{`)
+)
+
+// Parse extracts tool calls from a model reply. Explicit TOOL_CALL lines are
+// preferred; fenced blocks, "Call x with {...}" and bare known_tool({...})
+// forms are accepted as fallbacks. Arguments may contain nested JSON and
+// parentheses inside strings, and identical repeated calls are collapsed.
+func Parse(text string, knownTools []string) []agent.ToolCall {
+	known := make(map[string]bool, len(knownTools))
+	for _, name := range knownTools {
+		known[name] = true
+	}
+
+	parsers := []func(string, map[string]bool) []rawCall{
+		parseToolCallLines,
+		parseFences,
+		parseCallWith,
+		parseBareCalls,
+	}
+
+	for _, parse := range parsers {
+		if calls := parse(text, known); len(calls) > 0 {
+			return buildCalls(calls)
+		}
+	}
+
+	return nil
+}
+
+// rawCall is a tool name and its unparsed arguments
+type rawCall struct {
+	name string
+	args string
+}
+
+// parseToolCallLines handles TOOL_CALL: tool_name(json)
+func parseToolCallLines(text string, known map[string]bool) []rawCall {
+	var calls []rawCall
+	for _, match := range toolCallPattern.FindAllStringSubmatchIndex(text, -1) {
+		openParen := match[1] - 1
+		end, _ := scanBalanced(text, openParen)
+		calls = append(calls, rawCall{
+			name: text[match[2]:match[3]],
+			args: innerArgs(text[openParen:end]),
+		})
+	}
+	return calls
+}
+
+// parseFences handles fenced blocks containing tool_name(json) or a JSON object
+// such as {"name": "tool_name", "arguments": {...}}
+func parseFences(text string, known map[string]bool) []rawCall {
+	var calls []rawCall
+	for _, match := range fencePattern.FindAllStringSubmatch(text, -1) {
+		lang, body := strings.ToLower(match[1]), strings.TrimSpace(match[2])
+
+		if strings.HasPrefix(body, "{") {
+			if call, ok := parseJSONCall(body, known); ok {
+				calls = append(calls, call)
+			}
+			continue
+		}
+
+		// Only trust name(args) in fences explicitly marked as tool calls
+		if lang != "tool_call" && lang != "tool" && !known[functionName(body)] {
+			continue
+		}
+		if open := strings.Index(body, "("); open > 0 {
+			end, _ := scanBalanced(body, open)
+			calls = append(calls, rawCall{
+				name: strings.TrimSpace(body[:open]),
+				args: innerArgs(body[open:end]),
+			})
+		}
+	}
+	return calls
+}
+
+// parseCallWith handles "Call tool_name with {json}"
+func parseCallWith(text string, known map[string]bool) []rawCall {
+	var calls []rawCall
+	for _, match := range callWithPattern.FindAllStringSubmatchIndex(text, -1) {
+		openBrace := match[1] - 1
+		end, _ := scanBalanced(text, openBrace)
+		calls = append(calls, rawCall{
+			name: text[match[2]:match[3]],
+			args: text[openBrace:end],
+		})
+	}
+	return calls
+}
+
+// parseBareCalls handles tool_name({json}) without markers, for known tools only
+func parseBareCalls(text string, known map[string]bool) []rawCall {
+	var calls []rawCall
+	for _, match := range bareCallPattern.FindAllStringSubmatchIndex(text, -1) {
+		name := text[match[2]:match[3]]
+		if !known[name] {
+			continue
+		}
+		openParen := match[3]
+		end, closed := scanBalanced(text, openParen)
+		args := innerArgs(text[openParen:end])
+		// Without a marker only well-formed calls are trusted
+		if !closed || !json.Valid([]byte(args)) {
+			continue
+		}
+		calls = append(calls, rawCall{name: name, args: args})
+	}
+	return calls
+}
+
+// parseJSONCall reads a call written as a JSON object
+func parseJSONCall(body string, known map[string]bool) (rawCall, bool) {
+	end, _ := scanBalanced(body, 0)
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(body[:end]), &object); err != nil {
+		return rawCall{}, false
+	}
+
+	var name string
+	for _, key := range []string{"tool", "name", "function", "tool_name"} {
+		if raw, ok := object[key]; ok && json.Unmarshal(raw, &name) == nil && name != "" {
+			break
+		}
+	}
+	if name == "" || (len(known) > 0 && !known[name]) {
+		return rawCall{}, false
+	}
+
+	for _, key := range []string{"arguments", "input", "parameters", "args"} {
+		if raw, ok := object[key]; ok {
+			// Some models send arguments as a JSON-encoded string
+			var encoded string
+			if json.Unmarshal(raw, &encoded) == nil {
+				return rawCall{name: name, args: encoded}, true
+			}
+			return rawCall{name: name, args: string(raw)}, true
+		}
+	}
+
+	return rawCall{name: name, args: "{}"}, true
+}
+
+// buildCalls converts raw calls to tool calls, dropping exact repeats
+func buildCalls(raw []rawCall) []agent.ToolCall {
+	var calls []agent.ToolCall
+	seen := map[string]bool{}
+
+	for _, call := range raw {
+		input, parseErr := normalizeArgs(call.args)
+		key := call.name + "\x00" + string(input)
+		if call.name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		calls = append(calls, agent.ToolCall{
+			ID:         NewCallID(),
+			Name:       call.name,
+			Function:   call.name,
+			Input:      input,
+			ParseError: parseErr,
+		})
+	}
+
+	return calls
+}
+
+// NewCallID returns a random ID for a tool call, so calls from different
+// responses in a conversation are never confused
+func NewCallID() string {
+	return fmt.Sprintf("call_%08x", rand.Uint32())
+}
+
+// normalizeArgs turns raw argument text into JSON input, repairing it when
+// needed. Arguments that cannot be repaired are wrapped as {"input": "..."}
+// and the JSON syntax error is returned alongside.
+func normalizeArgs(args string) (json.RawMessage, string) {
+	args = strings.TrimSpace(args)
+	args = strings.TrimSpace(strings.TrimSuffix(args, ";"))
+
+	if args == "" {
+		return json.RawMessage("{}"), ""
+	}
+
+	if json.Valid([]byte(args)) {
+		return compact(args), ""
+	}
+
+	if repaired, ok := Repair(args); ok {
+		return compact(repaired), ""
+	}
+
+	var syntaxErr interface{}
+	parseErr := json.Unmarshal([]byte(args), &syntaxErr)
+
+	wrapped, _ := json.Marshal(map[string]string{"input": args})
+	return json.RawMessage(wrapped), parseErr.Error()
+}
+
+// compact removes insignificant whitespace so equal arguments compare equal
+func compact(args string) json.RawMessage {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(args)); err != nil {
+		return json.RawMessage(args)
+	}
+	return json.RawMessage(buf.String())
+}
+
+// innerArgs strips the surrounding parentheses from a scanned argument list
+func innerArgs(span string) string {
+	span = strings.TrimPrefix(span, "(")
+	span = strings.TrimSuffix(span, ")")
+	return span
+}
+
+// functionName returns the identifier before the first parenthesis
+func functionName(body string) string {
+	if open := strings.Index(body, "("); open > 0 {
+		return strings.TrimSpace(body[:open])
+	}
+	return ""
+}
+
+// scanBalanced scans from the opening bracket at start to its matching closer,
+// ignoring brackets inside JSON strings. It returns the end offset (after the
+// closer) and whether the closer was found; unclosed input runs to the end.
+func scanBalanced(text string, start int) (int, bool) {
+	if start < 0 || start >= len(text) {
+		return len(text), false
+	}
+
+	closers := map[byte]byte{'(': ')', '{': '}', '[': ']'}
+	var stack []byte
+	inString := false
+	escaped := false
+
+	for pos := start; pos < len(text); pos++ {
+		char := text[pos]
+
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case char == '\\':
+				escaped = true
+			case char == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch char {
+		case '"':
+			inString = true
+		case '(', '{', '[':
+			stack = append(stack, closers[char])
+		case ')', '}', ']':
+			if len(stack) == 0 || stack[len(stack)-1] != char {
+				// Mismatched closer; treat it as text
+				continue
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return pos + 1, true
+			}
+		}
+	}
+
+	return len(text), false
+}
@@ -0,0 +1,154 @@
+package toolproto
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var knownTools = []string{"fitbit_log_meal", "fitbit_login"}
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name         string
+		text         string
+		wantNames    []string
+		wantInput    []string
+		wantParseErr bool
+	}{
+		{
+			name:      "TOOL_CALL line",
+			text:      `TOOL_CALL: fitbit_log_meal({"meal_type": "breakfast"})`,
+			wantNames: []string{"fitbit_log_meal"},
+			wantInput: []string{`{"meal_type":"breakfast"}`},
+		},
+		{
+			name:      "Nested JSON and parentheses inside strings",
+			text:      `Sure! TOOL_CALL: fitbit_log_meal({"foods": [{"name": "toast (whole wheat)", "calories": 80}]}) done`,
+			wantNames: []string{"fitbit_log_meal"},
+			wantInput: []string{`{"foods":[{"name":"toast (whole wheat)","calories":80}]}`},
+		},
+		{
+			name:      "Trailing semicolon",
+			text:      `TOOL_CALL: fitbit_login({});`,
+			wantNames: []string{"fitbit_login"},
+			wantInput: []string{`{}`},
+		},
+		{
+			name:      "Multiple calls",
+			text:      "TOOL_CALL: fitbit_login()\nTOOL_CALL: fitbit_log_meal({\"meal_type\": \"lunch\"})",
+			wantNames: []string{"fitbit_login", "fitbit_log_meal"},
+			wantInput: []string{`{}`, `{"meal_type":"lunch"}`},
+		},
+		{
+			name:      "Identical repeated calls are collapsed",
+			text:      "TOOL_CALL: fitbit_log_meal({\"meal_type\": \"lunch\"})\nTOOL_CALL: fitbit_log_meal({\"meal_type\":\"lunch\"})",
+			wantNames: []string{"fitbit_log_meal"},
+			wantInput: []string{`{"meal_type":"lunch"}`},
+		},
+		{
+			name:      "Fenced tool_call block",
+			text:      "```tool_call\nfitbit_log_meal({\"meal_type\": \"dinner\", \"foods\": [{\"name\": \"rice\"}]})\n```",
+			wantNames: []string{"fitbit_log_meal"},
+			wantInput: []string{`{"meal_type":"dinner","foods":[{"name":"rice"}]}`},
+		},
+		{
+			name:      "Fenced JSON object",
+			text:      "```json\n{\"name\": \"fitbit_log_meal\", \"arguments\": {\"meal_type\": \"snack\"}}\n```",
+			wantNames: []string{"fitbit_log_meal"},
+			wantInput: []string{`{"meal_type":"snack"}`},
+		},
+		{
+			name:      "Call with form",
+			text:      `Call fitbit_log_meal with {"meal_type": "lunch", "foods": [{"name": "salad"}]}`,
+			wantNames: []string{"fitbit_log_meal"},
+			wantInput: []string{`{"meal_type":"lunch","foods":[{"name":"salad"}]}`},
+		},
+		{
+			name:      "Bare call to a known tool",
+			text:      `I'll run fitbit_log_meal({"meal_type": "breakfast"}) for you`,
+			wantNames: []string{"fitbit_log_meal"},
+			wantInput: []string{`{"meal_type":"breakfast"}`},
+		},
+		{
+			name: "Bare call to an unknown function is ignored",
+			text: `You could call print({"a": 1}) yourself`,
+		},
+		{
+			name:      "Malformed JSON is repaired",
+			text:      `TOOL_CALL: fitbit_log_meal({'meal_type': 'breakfast', foods: [{'name': 'eggs'},]})`,
+			wantNames: []string{"fitbit_log_meal"},
+			wantInput: []string{`{"meal_type":"breakfast","foods":[{"name":"eggs"}]}`},
+		},
+		{
+			name:         "Unrepairable JSON is wrapped and flagged",
+			text:         `TOOL_CALL: fitbit_log_meal(meal_type=breakfast)`,
+			wantNames:    []string{"fitbit_log_meal"},
+			wantInput:    []string{`{"input":"meal_type=breakfast"}`},
+			wantParseErr: true,
+		},
+		{
+			name: "Plain text has no calls",
+			text: "Your breakfast has been logged!",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := Parse(tc.text, knownTools)
+
+			if len(calls) != len(tc.wantNames) {
+				t.Fatalf("Expected %d calls but got %d: %+v", len(tc.wantNames), len(calls), calls)
+			}
+			for i, call := range calls {
+				if call.Name != tc.wantNames[i] {
+					t.Errorf("Call %d: expected name %q but got %q", i, tc.wantNames[i], call.Name)
+				}
+				if string(call.Input) != tc.wantInput[i] {
+					t.Errorf("Call %d: expected input %s but got %s", i, tc.wantInput[i], call.Input)
+				}
+				if (call.ParseError != "") != tc.wantParseErr {
+					t.Errorf("Call %d: expected parse error %v but got %q", i, tc.wantParseErr, call.ParseError)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCallIDs(t *testing.T) {
+	// Calls parsed from different responses of a conversation must not share IDs
+	seen := map[string]bool{}
+	for _, text := range []string{
+		"TOOL_CALL: fitbit_login({})\nTOOL_CALL: fitbit_log_meal({\"meal_type\": \"lunch\"})",
+		"TOOL_CALL: fitbit_login({})",
+	} {
+		for _, call := range Parse(text, knownTools) {
+			if seen[call.ID] {
+				t.Errorf("Expected unique call IDs but %s was repeated", call.ID)
+			}
+			seen[call.ID] = true
+		}
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected 3 calls but got %d", len(seen))
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add(`TOOL_CALL: fitbit_log_meal({"meal_type": "breakfast"})`)
+	f.Add("```tool_call\nfitbit_login()\n```")
+	f.Add("```json\n{\"tool\": \"fitbit_login\", \"input\": \"{}\"}\n```")
+	f.Add(`Call fitbit_log_meal with {"foods": [{"name": "a\"b)"}]`)
+	f.Add(`fitbit_log_meal({"x": [1, {"y": ")"}]})`)
+	f.Add(`TOOL_CALL: fitbit_log_meal({"unterminated": "`)
+
+	f.Fuzz(func(t *testing.T, text string) {
+		for _, call := range Parse(text, knownTools) {
+			if call.Name == "" {
+				t.Errorf("Parsed a call without a name from %q", text)
+			}
+			if !json.Valid(call.Input) {
+				t.Errorf("Parsed invalid JSON input %q from %q", call.Input, text)
+			}
+		}
+	})
+}
@@ -0,0 +1,167 @@
+// Package toolproto implements the text protocol used by providers without native
+// function calling: rendering tool instructions into the prompt and parsing
+// TOOL_CALL lines back out of the model's reply.
+package toolproto
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+// exampleCall is shown to the model as the canonical tool call format
+const exampleCall = `TOOL_CALL: fitbit_log_meal({"meal_type": "breakfast", "foods": [{"name": "scrambled eggs", "quantity": 2, "unit": "large", "calories": 140}]})`
+
+// RenderPreamble returns the banner placed before the system prompt, reminding
+// the model that it must call tools rather than describe them
+func RenderPreamble(defs []agent.ToolDefinition) string {
+	if len(defs) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("🚨🚨🚨 CRITICAL: YOU MUST USE TOOLS! 🚨🚨🚨\n")
+	b.WriteString("When user asks to log meals, you MUST use this EXACT format:\n")
+	b.WriteString(exampleCall + "\n\n")
+	b.WriteString("DO NOT just say 'I'll log it' - ACTUALLY CALL THE TOOL!\n\n")
+	return b.String()
+}
+
+// RenderTools lists every tool with its description and parameters
+func RenderTools(defs []agent.ToolDefinition) string {
+	if len(defs) == 0 {
+		return ""
+	}
+
+	sorted := append([]agent.ToolDefinition{}, defs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var b strings.Builder
+	b.WriteString("🚨 AVAILABLE TOOLS:\n")
+	for _, def := range sorted {
+		b.WriteString(fmt.Sprintf("- %s: %s\n", def.Name, def.Description))
+		renderProperties(&b, def.InputSchema, "    ")
+	}
+	return b.String()
+}
+
+// RenderRules returns the formatting rules placed after the tool list
+func RenderRules(defs []agent.ToolDefinition) string {
+	if len(defs) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("🚨🚨🚨 TOOL CALL FORMAT RULES - FOLLOW EXACTLY! 🚨🚨🚨\n")
+	b.WriteString("1. Use EXACT format: TOOL_CALL: tool_name(json)\n")
+	b.WriteString("2. Put each tool call on its own line; make several calls when the request needs them\n")
+	b.WriteString("3. NO extra text after the closing parenthesis )\n")
+	b.WriteString("4. NO semicolons, commas, or explanations after )\n")
+	b.WriteString("5. JSON must be valid and complete\n")
+	b.WriteString("6. End the line immediately after the )\n")
+	b.WriteString("7. DO NOT repeat the same tool call\n")
+	b.WriteString("Example: " + exampleCall + "\n")
+	b.WriteString("WRONG: TOOL_CALL: fitbit_log_meal({...}); followed by explanation\n")
+	b.WriteString("WRONG: Making multiple identical tool calls\n")
+	b.WriteString("RIGHT: TOOL_CALL: fitbit_log_meal({...})\n")
+	return b.String()
+}
+
+// RenderResult formats a tool result for the prompt, naming the call it answers
+func RenderResult(result agent.ToolResult) string {
+	label := "Tool Result"
+	if result.IsError {
+		label = "Tool Error"
+	}
+	return fmt.Sprintf("%s (%s, %s):\n%s", label, result.Name, result.CallID, result.Content)
+}
+
+// Names returns the tool names from the definitions, for use with Parse
+func Names(defs []agent.ToolDefinition) []string {
+	names := make([]string, 0, len(defs))
+	for _, def := range defs {
+		names = append(names, def.Name)
+	}
+	return names
+}
+
+// renderProperties writes one line per schema property, recursing into object
+// properties and array items
+func renderProperties(b *strings.Builder, schema map[string]interface{}, indent string) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	if len(properties) == 0 {
+		return
+	}
+
+	required := map[string]bool{}
+	for _, name := range stringList(schema["required"]) {
+		required[name] = true
+	}
+
+	// Required parameters first, then alphabetical, so the prompt is stable
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if required[names[i]] != required[names[j]] {
+			return required[names[i]]
+		}
+		return names[i] < names[j]
+	})
+
+	for _, name := range names {
+		property, _ := properties[name].(map[string]interface{})
+
+		line := fmt.Sprintf("%s- %s (%s", indent, name, typeName(property))
+		if required[name] {
+			line += ", required"
+		}
+		line += ")"
+		if description, _ := property["description"].(string); description != "" {
+			line += ": " + description
+		}
+		if enum := stringList(property["enum"]); len(enum) > 0 {
+			line += fmt.Sprintf(". One of: %s", strings.Join(enum, ", "))
+		}
+		b.WriteString(line + "\n")
+
+		if items, ok := property["items"].(map[string]interface{}); ok {
+			renderProperties(b, items, indent+"    ")
+		}
+		renderProperties(b, property, indent+"    ")
+	}
+}
+
+// typeName describes a schema type, including the item type of arrays
+func typeName(property map[string]interface{}) string {
+	typ, _ := property["type"].(string)
+	if typ == "" {
+		typ = "any"
+	}
+	if typ == "array" {
+		if items, ok := property["items"].(map[string]interface{}); ok {
+			if itemType, _ := items["type"].(string); itemType != "" {
+				return "array of " + itemType
+			}
+		}
+	}
+	return typ
+}
+
+// stringList converts the []string or []interface{} used for enums and required lists
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			list = append(list, fmt.Sprintf("%v", item))
+		}
+		return list
+	}
+	return nil
+}
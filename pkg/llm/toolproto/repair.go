@@ -0,0 +1,145 @@
+package toolproto
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// pythonLiterals maps literals some models emit to their JSON equivalents
+var pythonLiterals = map[string]string{
+	"True":  "true",
+	"False": "false",
+	"None":  "null",
+}
+
+// Repair fixes the mistakes models commonly make when writing JSON arguments:
+// single-quoted strings, unquoted keys and values, trailing commas, Python
+// literals and output truncated before the closing brackets. It returns the
+// repaired text and whether it is now valid JSON.
+func Repair(args string) (string, bool) {
+	var out strings.Builder
+	var stack []byte
+	var quote byte // quote character of the string being copied, 0 outside strings
+
+	for pos := 0; pos < len(args); pos++ {
+		char := args[pos]
+
+		if quote != 0 {
+			switch {
+			case char == '\\' && pos+1 < len(args):
+				pos++
+				if args[pos] == '\'' {
+					// \' is not a JSON escape
+					out.WriteByte('\'')
+				} else {
+					out.WriteByte(char)
+					out.WriteByte(args[pos])
+				}
+			case char == quote:
+				quote = 0
+				out.WriteByte('"')
+			case char == '"':
+				// A double quote inside a single-quoted string
+				out.WriteString(`\"`)
+			case char == '\n':
+				out.WriteString(`\n`)
+			default:
+				out.WriteByte(char)
+			}
+			continue
+		}
+
+		switch {
+		case char == '"' || char == '\'':
+			quote = char
+			out.WriteByte('"')
+		case char == '{' || char == '[':
+			stack = append(stack, char)
+			out.WriteByte(char)
+		case char == '}' || char == ']':
+			if len(stack) > 0 && closerFor(stack[len(stack)-1]) == char {
+				stack = stack[:len(stack)-1]
+				out.WriteByte(char)
+			}
+		case char == ',':
+			// Drop commas directly before a closing bracket
+			next := nextSignificant(args, pos+1)
+			if next != '}' && next != ']' && next != 0 {
+				out.WriteByte(char)
+			}
+		case char == '-' || (char >= '0' && char <= '9'):
+			end := pos
+			for end < len(args) && strings.IndexByte("0123456789eE+-.", args[end]) >= 0 {
+				end++
+			}
+			out.WriteString(args[pos:end])
+			pos = end - 1
+		case isWordStart(char):
+			end := pos
+			for end < len(args) && isWordChar(args[end]) {
+				end++
+			}
+			word := args[pos:end]
+			pos = end - 1
+
+			switch {
+			case nextSignificant(args, end) == ':':
+				out.WriteString(`"` + word + `"`)
+			case word == "true" || word == "false" || word == "null":
+				out.WriteString(word)
+			case pythonLiterals[word] != "":
+				out.WriteString(pythonLiterals[word])
+			default:
+				out.WriteString(`"` + word + `"`)
+			}
+		default:
+			out.WriteByte(char)
+		}
+	}
+
+	// Close whatever the truncation left open
+	repaired := out.String()
+	if quote != 0 {
+		repaired = strings.TrimSuffix(repaired, "\\") + `"`
+	}
+	repaired = strings.TrimRight(repaired, " \t\r\n")
+	repaired = strings.TrimSuffix(repaired, ",")
+	if strings.HasSuffix(repaired, ":") {
+		repaired += "null"
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		repaired += string(closerFor(stack[i]))
+	}
+
+	return repaired, json.Valid([]byte(repaired))
+}
+
+// closerFor returns the closing bracket for an opening one
+func closerFor(open byte) byte {
+	if open == '{' {
+		return '}'
+	}
+	return ']'
+}
+
+// nextSignificant returns the next non-whitespace character at or after pos, or 0
+func nextSignificant(text string, pos int) byte {
+	for ; pos < len(text); pos++ {
+		switch text[pos] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return text[pos]
+	}
+	return 0
+}
+
+// isWordStart reports whether char can start a bare key or value
+func isWordStart(char byte) bool {
+	return char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+}
+
+// isWordChar reports whether char can continue a bare key or value
+func isWordChar(char byte) bool {
+	return isWordStart(char) || (char >= '0' && char <= '9') || char == '-'
+}
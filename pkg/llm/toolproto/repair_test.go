@@ -0,0 +1,66 @@
+package toolproto
+
+import "testing"
+
+func TestRepair(t *testing.T) {
+	testCases := []struct {
+		name   string
+		args   string
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "Trailing commas",
+			args:   `{"foods": [{"name": "eggs",},], }`,
+			want:   `{"foods": [{"name": "eggs"}] }`,
+			wantOK: true,
+		},
+		{
+			name:   "Single quotes",
+			args:   `{'meal_type': 'breakfast', 'notes': 'it\'s "good"'}`,
+			want:   `{"meal_type": "breakfast", "notes": "it's \"good\""}`,
+			wantOK: true,
+		},
+		{
+			name:   "Unquoted keys and Python literals",
+			args:   `{meal_type: "lunch", logged: True, note: None, calories: -1.5e2}`,
+			want:   `{"meal_type": "lunch", "logged": true, "note": null, "calories": -1.5e2}`,
+			wantOK: true,
+		},
+		{
+			name:   "Truncated inside a string",
+			args:   `{"foods": [{"name": "scrambled eg`,
+			want:   `{"foods": [{"name": "scrambled eg"}]}`,
+			wantOK: true,
+		},
+		{
+			name:   "Truncated after a key",
+			args:   `{"foods": [{"name": "toast"}], "date":`,
+			want:   `{"foods": [{"name": "toast"}], "date":null}`,
+			wantOK: true,
+		},
+		{
+			name:   "Truncated array",
+			args:   `{"foods": [{"name": "toast", "calories": 80}, `,
+			want:   `{"foods": [{"name": "toast", "calories": 80}]}`,
+			wantOK: true,
+		},
+		{
+			name: "Missing colon cannot be repaired",
+			args: `{"meal_type" "breakfast"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := Repair(tc.args)
+
+			if ok != tc.wantOK {
+				t.Fatalf("Expected ok=%v but got %v (repaired: %s)", tc.wantOK, ok, got)
+			}
+			if tc.wantOK && got != tc.want {
+				t.Errorf("Expected %s but got %s", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,445 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ServerConfig tells the client how to reach an MCP server: either a command
+// speaking the protocol on stdio, or the URL of an HTTP endpoint
+type ServerConfig struct {
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// closeTimeout is how long closing waits for a server before giving up on it
+const closeTimeout = 2 * time.Second
+
+// transport exchanges JSON-RPC messages with a server
+type transport interface {
+	// roundTrip sends a request and waits for its response
+	roundTrip(ctx context.Context, req *request) (*response, error)
+	// notify sends a notification
+	notify(ctx context.Context, req *request) error
+	Close() error
+}
+
+// Client is a connection to an external MCP server
+type Client struct {
+	name      string
+	transport transport
+	nextID    atomic.Int64
+}
+
+// Connect starts or connects to the server and initializes the session
+func Connect(ctx context.Context, name string, cfg ServerConfig) (*Client, error) {
+	var t transport
+	var err error
+	switch {
+	case cfg.Command != "":
+		t, err = newStdioTransport(cfg)
+	case cfg.URL != "":
+		t = &httpTransport{url: cfg.URL, headers: cfg.Headers, client: &http.Client{}}
+	default:
+		err = fmt.Errorf("either command or url is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{name: name, transport: t}
+	if err := client.initialize(ctx); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// Name returns the name the server was configured under
+func (c *Client) Name() string {
+	return c.name
+}
+
+// initialize negotiates the protocol version and announces the client
+func (c *Client) initialize(ctx context.Context) error {
+	var result initializeResult
+	err := c.call(ctx, "initialize", initializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]interface{}{},
+		ClientInfo:      Implementation{Name: "fitbit-agent", Version: "1.0.0"},
+	}, &result)
+	if err != nil {
+		return fmt.Errorf("failed to initialize: %w", err)
+	}
+	if !isSupportedVersion(result.ProtocolVersion) {
+		return fmt.Errorf("unsupported protocol version %q", result.ProtocolVersion)
+	}
+
+	return c.transport.notify(ctx, &request{JSONRPC: "2.0", Method: "notifications/initialized"})
+}
+
+// ListTools returns every tool the server offers
+func (c *Client) ListTools(ctx context.Context) ([]ToolDefinition, error) {
+	var tools []ToolDefinition
+	cursor := ""
+	for {
+		params := map[string]string{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+
+		var result listToolsResult
+		if err := c.call(ctx, "tools/list", params, &result); err != nil {
+			return nil, fmt.Errorf("failed to list tools: %w", err)
+		}
+		tools = append(tools, result.Tools...)
+
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// CallTool runs a tool on the server
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (*CallToolResult, error) {
+	var result CallToolResult
+	if err := c.call(ctx, "tools/call", callToolParams{Name: name, Arguments: arguments}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Close disconnects from the server, stopping it if the client started it
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
+
+// call sends a request and decodes its result into out
+func (c *Client) call(ctx context.Context, method string, params, out interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	id, _ := json.Marshal(c.nextID.Add(1))
+
+	resp, err := c.transport.roundTrip(ctx, &request{JSONRPC: "2.0", ID: id, Method: method, Params: data})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if err := json.Unmarshal(resp.Result, out); err != nil {
+		return fmt.Errorf("invalid %s result: %w", method, err)
+	}
+	return nil
+}
+
+// Text joins the text content of a result, describing anything else by type
+func (r *CallToolResult) Text() string {
+	var parts []string
+	for _, content := range r.Content {
+		if content.Type == "text" {
+			parts = append(parts, content.Text)
+		} else {
+			parts = append(parts, fmt.Sprintf("[%s content]", content.Type))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// stdioTransport talks to a server started as a child process
+type stdioTransport struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	pending map[string]chan *response
+	done    chan struct{}
+	err     error
+	mu      sync.Mutex
+	writeMu sync.Mutex
+}
+
+// newStdioTransport starts the server command
+func newStdioTransport(cfg ServerConfig) (*stdioTransport, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Env = os.Environ()
+	for key, value := range cfg.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", cfg.Command, err)
+	}
+
+	t := &stdioTransport{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[string]chan *response),
+		done:    make(chan struct{}),
+	}
+	go t.readLoop(stdout)
+	return t, nil
+}
+
+// readLoop delivers responses to the requests waiting for them until the
+// server exits
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageBytes)
+	for scanner.Scan() {
+		var msg struct {
+			request
+			Result json.RawMessage `json:"result,omitempty"`
+			Error  *RPCError       `json:"error,omitempty"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+
+		// Requests from the server are not supported, apart from ping
+		if msg.Method != "" {
+			if !msg.isNotification() {
+				var rpcErr *RPCError
+				if msg.Method != "ping" {
+					rpcErr = &RPCError{Code: codeMethodNotFound, Message: "not supported by this client"}
+				}
+				t.write(encodeResponse(msg.ID, struct{}{}, rpcErr))
+			}
+			continue
+		}
+
+		t.mu.Lock()
+		waiting, ok := t.pending[string(msg.ID)]
+		delete(t.pending, string(msg.ID))
+		t.mu.Unlock()
+		if ok {
+			waiting <- &response{JSONRPC: msg.JSONRPC, ID: msg.ID, Result: msg.Result, Error: msg.Error}
+		}
+	}
+
+	t.mu.Lock()
+	t.err = fmt.Errorf("server exited")
+	if err := scanner.Err(); err != nil {
+		t.err = fmt.Errorf("failed to read from server: %w", err)
+	}
+	t.mu.Unlock()
+	close(t.done)
+}
+
+// write sends a message on its own line
+func (t *stdioTransport) write(message []byte) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err := t.stdin.Write(append(message, '\n'))
+	return err
+}
+
+func (t *stdioTransport) roundTrip(ctx context.Context, req *request) (*response, error) {
+	waiting := make(chan *response, 1)
+	t.mu.Lock()
+	t.pending[string(req.ID)] = waiting
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, string(req.ID))
+		t.mu.Unlock()
+	}()
+
+	if err := t.notify(ctx, req); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp := <-waiting:
+		return resp, nil
+	case <-t.done:
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		return nil, t.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) notify(ctx context.Context, req *request) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if err := t.write(data); err != nil {
+		return fmt.Errorf("failed to write to server: %w", err)
+	}
+	return nil
+}
+
+// Close stops the server by closing its stdin, killing it if it does not exit
+func (t *stdioTransport) Close() error {
+	t.stdin.Close()
+	select {
+	case <-t.done:
+	case <-time.After(closeTimeout):
+		t.cmd.Process.Kill()
+	}
+	return t.cmd.Wait()
+}
+
+// httpTransport talks to a server over the streamable HTTP transport
+type httpTransport struct {
+	url       string
+	headers   map[string]string
+	client    *http.Client
+	sessionID string
+	mu        sync.Mutex
+}
+
+func (t *httpTransport) roundTrip(ctx context.Context, req *request) (*response, error) {
+	httpResp, err := t.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/event-stream") {
+		return readEventStream(httpResp.Body, req.ID)
+	}
+
+	var resp response
+	if err := json.NewDecoder(io.LimitReader(httpResp.Body, maxMessageBytes)).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &resp, nil
+}
+
+func (t *httpTransport) notify(ctx context.Context, req *request) error {
+	httpResp, err := t.post(ctx, req)
+	if err != nil {
+		return err
+	}
+	httpResp.Body.Close()
+	return nil
+}
+
+// post sends a message and checks the HTTP status, keeping the session ID the
+// server assigns
+func (t *httpTransport) post(ctx context.Context, req *request) (*http.Response, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json, text/event-stream")
+	httpReq.Header.Set("MCP-Protocol-Version", ProtocolVersion)
+	t.setHeaders(httpReq)
+	t.mu.Lock()
+	if t.sessionID != "" {
+		httpReq.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	t.mu.Unlock()
+
+	httpResp, err := t.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach server: %w", err)
+	}
+	if httpResp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(httpResp.Body, 1024))
+		httpResp.Body.Close()
+		return nil, fmt.Errorf("server returned %s: %s", httpResp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if sessionID := httpResp.Header.Get("Mcp-Session-Id"); sessionID != "" {
+		t.mu.Lock()
+		t.sessionID = sessionID
+		t.mu.Unlock()
+	}
+	return httpResp, nil
+}
+
+// setHeaders adds the configured headers, such as Authorization, to a request
+func (t *httpTransport) setHeaders(req *http.Request) {
+	for key, value := range t.headers {
+		req.Header.Set(key, os.ExpandEnv(value))
+	}
+}
+
+// Close ends the session, giving up on a server that does not answer in time
+func (t *httpTransport) Close() error {
+	t.mu.Lock()
+	sessionID := t.sessionID
+	t.mu.Unlock()
+	if sessionID == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	t.setHeaders(req)
+	req.Header.Set("Mcp-Session-Id", sessionID)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to end session: %w", err)
+	}
+	return resp.Body.Close()
+}
+
+// readEventStream reads server-sent events until the response to id arrives
+func readEventStream(body io.Reader, id json.RawMessage) (*response, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageBytes)
+
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data.WriteString(strings.TrimPrefix(value, " "))
+			continue
+		}
+		if line != "" || data.Len() == 0 {
+			continue
+		}
+
+		var resp response
+		if err := json.Unmarshal([]byte(data.String()), &resp); err == nil && string(resp.ID) == string(id) {
+			return &resp, nil
+		}
+		data.Reset()
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read event stream: %w", err)
+	}
+	return nil, fmt.Errorf("event stream ended without a response")
+}
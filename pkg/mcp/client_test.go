@@ -0,0 +1,209 @@
+package mcp_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+	"github.com/vhbfernandes/fitbit-agent/pkg/mcp"
+)
+
+// TestMain lets the test binary act as a stdio MCP server for the client tests
+func TestMain(m *testing.M) {
+	if os.Getenv("MCP_TEST_SERVER") == "1" {
+		newTestServer().ServeStdio(context.Background(), os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestLoadTools(t *testing.T) {
+	httpServer := httptest.NewServer(newTestServer().HTTPHandler("secret"))
+	defer httpServer.Close()
+
+	// A server answering with an event stream, after a notification
+	streamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.ID) == 0 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		result := `{}`
+		switch req.Method {
+		case "initialize":
+			result = `{"protocolVersion": "2025-03-26", "capabilities": {}, "serverInfo": {"name": "stream", "version": "1"}}`
+		case "tools/list":
+			result = `{"tools": [{"name": "find.recipe", "description": "Finds recipes", "inputSchema": {"type": "object"}}]}`
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\": \"2.0\", \"method\": \"notifications/progress\"}\n\n")
+		fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\": \"2.0\", \"id\": %s, \"result\": %s}\n\n", req.ID, result)
+	}))
+	defer streamServer.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("failed to find the test binary: %v", err)
+	}
+
+	cfg := &mcp.Config{Servers: map[string]mcp.ServerConfig{
+		"local":  {Command: executable, Env: map[string]string{"MCP_TEST_SERVER": "1"}},
+		"remote": {URL: httpServer.URL, Headers: map[string]string{"Authorization": "Bearer secret"}},
+		"stream": {URL: streamServer.URL},
+		"denied": {URL: httpServer.URL},
+		"broken": {},
+	}}
+
+	tools, clients, errs := mcp.LoadTools(context.Background(), cfg)
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "broken") || !strings.Contains(errs[1].Error(), "401") {
+		t.Errorf("expected the broken and denied servers to fail, got %v", errs)
+	}
+
+	byName := map[string]agent.Tool{}
+	for _, tool := range tools {
+		byName[tool.Name()] = tool
+	}
+	for _, name := range []string{"local_echo", "local_write", "remote_echo", "remote_write", "stream_find_recipe"} {
+		if _, ok := byName[name]; !ok {
+			t.Errorf("expected tool %s, got %v", name, byName)
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		tool    string
+		input   string
+		output  string
+		wantErr string
+	}{
+		{name: "Tools run over stdio", tool: "local_echo", input: `{"message": "hi"}`, output: "echo: hi"},
+		{name: "Tools run over HTTP", tool: "remote_echo", input: `{"message": "there"}`, output: "echo: there"},
+		{name: "Tool errors are returned as errors", tool: "local_echo", input: `{"message": "fail"}`, wantErr: "asked to fail"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tool, ok := byName[tc.tool]
+			if !ok {
+				t.Fatalf("tool %s was not loaded", tc.tool)
+			}
+			output, err := tool.Execute(context.Background(), json.RawMessage(tc.input))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil || output != tc.output {
+				t.Errorf("expected %q, got %q (%v)", tc.output, output, err)
+			}
+		})
+	}
+
+	if description := byName["remote_echo"].Description(); !strings.Contains(description, "remote MCP server") {
+		t.Errorf("expected the description to name the server, got %q", description)
+	}
+
+	// Closing the clients stops the servers started for them
+	if len(clients) != 3 {
+		t.Fatalf("expected a client for each working server, got %d", len(clients))
+	}
+	for _, client := range clients {
+		if err := client.Close(); err != nil {
+			t.Errorf("failed to close %s: %v", client.Name(), err)
+		}
+	}
+	if _, err := byName["local_echo"].Execute(context.Background(), json.RawMessage(`{"message": "hi"}`)); err == nil {
+		t.Error("expected the stdio server to be stopped")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	cfg, err := mcp.LoadConfig(filepath.Join(dir, "missing.json"))
+	if err != nil || len(cfg.Servers) != 0 {
+		t.Errorf("expected no servers without a config file, got %v (%v)", cfg, err)
+	}
+
+	path := filepath.Join(dir, "mcp.json")
+	os.WriteFile(path, []byte(`{"mcpServers": {"recipes": {"command": "recipes-mcp", "args": ["--stdio"]}}}`), 0600)
+	cfg, err = mcp.LoadConfig(path)
+	if err != nil || cfg.Servers["recipes"].Command != "recipes-mcp" || len(cfg.Servers["recipes"].Args) != 1 {
+		t.Errorf("unexpected config %+v (%v)", cfg, err)
+	}
+
+	os.WriteFile(path, []byte(`{"mcpServers": [`), 0600)
+	if _, err := mcp.LoadConfig(path); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestHTTPClientClose(t *testing.T) {
+	testCases := []struct {
+		name    string
+		hang    bool
+		wantErr bool
+	}{
+		{name: "The session is ended with the configured headers"},
+		{name: "An unresponsive server is given up on", hang: true, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deleteAuth := make(chan string, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodDelete {
+					deleteAuth <- r.Header.Get("Authorization")
+					if tc.hang {
+						<-r.Context().Done()
+					}
+					return
+				}
+
+				var req struct {
+					ID json.RawMessage `json:"id"`
+				}
+				json.NewDecoder(r.Body).Decode(&req)
+				if len(req.ID) == 0 {
+					w.WriteHeader(http.StatusAccepted)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Mcp-Session-Id", "session-1")
+				fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": %s, "result": {"protocolVersion": "2025-03-26", "capabilities": {}, "serverInfo": {"name": "test", "version": "1"}}}`, req.ID)
+			}))
+			defer server.Close()
+
+			client, err := mcp.Connect(context.Background(), "test", mcp.ServerConfig{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer secret"}})
+			if err != nil {
+				t.Fatalf("failed to connect: %v", err)
+			}
+
+			start := time.Now()
+			err = client.Close()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("expected Close to give up in time, took %s", elapsed)
+			}
+			if auth := <-deleteAuth; auth != "Bearer secret" {
+				t.Errorf("expected the session to be ended with the configured headers, got %q", auth)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+// maxMessageBytes limits the size of a single message
+const maxMessageBytes = 4 << 20
+
+// Server serves the tools of a registry over the Model Context Protocol, so
+// other AI clients can use them
+type Server struct {
+	registry agent.ToolRegistry
+	info     Implementation
+}
+
+// NewServer creates an MCP server for every tool in the registry
+func NewServer(registry agent.ToolRegistry, info Implementation) *Server {
+	return &Server{registry: registry, info: info}
+}
+
+// Handle processes a single JSON-RPC message and returns the response to send
+// back, or nil for notifications. A message is a notification when it has no
+// id, whatever its method, so requests for unknown methods are always answered.
+func (s *Server) Handle(ctx context.Context, message []byte) []byte {
+	var req request
+	if err := json.Unmarshal(message, &req); err != nil {
+		return encodeResponse(nil, nil, &RPCError{Code: codeParseError, Message: "invalid JSON: " + err.Error()})
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		if req.isNotification() {
+			return nil
+		}
+		return encodeResponse(req.ID, nil, &RPCError{Code: codeInvalidRequest, Message: "not a JSON-RPC 2.0 request"})
+	}
+
+	result, rpcErr := s.dispatch(ctx, &req)
+	if req.isNotification() {
+		return nil
+	}
+	return encodeResponse(req.ID, result, rpcErr)
+}
+
+// dispatch runs the method of a request
+func (s *Server) dispatch(ctx context.Context, req *request) (interface{}, *RPCError) {
+	switch req.Method {
+	case "initialize":
+		var params initializeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &RPCError{Code: codeInvalidParams, Message: "invalid initialize params"}
+		}
+		version := ProtocolVersion
+		if isSupportedVersion(params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		return initializeResult{
+			ProtocolVersion: version,
+			Capabilities:    map[string]interface{}{"tools": map[string]interface{}{"listChanged": false}},
+			ServerInfo:      s.info,
+		}, nil
+
+	case "ping":
+		return struct{}{}, nil
+
+	case "tools/list":
+		return listToolsResult{Tools: s.toolDefinitions()}, nil
+
+	case "tools/call":
+		var params callToolParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &RPCError{Code: codeInvalidParams, Message: "invalid tools/call params"}
+		}
+		return s.callTool(ctx, params)
+
+	default:
+		return nil, &RPCError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not found", req.Method)}
+	}
+}
+
+// toolDefinitions converts the registry's tools to MCP tool definitions, by name
+func (s *Server) toolDefinitions() []ToolDefinition {
+	definitions := []ToolDefinition{}
+	for _, tool := range s.registry.GetAllTools() {
+		writer, ok := agent.AsTool[agent.SideEffectTool](tool)
+		definitions = append(definitions, ToolDefinition{
+			Name:        tool.Name(),
+			Description: tool.Description(),
+			InputSchema: tool.InputSchema(),
+			Annotations: &ToolAnnotations{ReadOnlyHint: !ok || !writer.SideEffects()},
+		})
+	}
+	sort.Slice(definitions, func(i, j int) bool { return definitions[i].Name < definitions[j].Name })
+	return definitions
+}
+
+// callTool validates the arguments and runs the tool. Tool failures are
+// reported in the result so the client's model can see them.
+func (s *Server) callTool(ctx context.Context, params callToolParams) (interface{}, *RPCError) {
+	tool, found := s.registry.GetTool(params.Name)
+	if !found {
+		return nil, &RPCError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown tool %q", params.Name)}
+	}
+
+	arguments := params.Arguments
+	if len(arguments) == 0 || string(arguments) == "null" {
+		arguments = json.RawMessage(`{}`)
+	}
+	input, err := s.registry.ValidateInput(params.Name, arguments)
+	if err != nil {
+		return textResult(fmt.Sprintf("Error: invalid input for tool '%s': %s", params.Name, err.Error()), true), nil
+	}
+
+	output, err := tool.Execute(ctx, input)
+	if err != nil {
+		return textResult(fmt.Sprintf("Error executing tool '%s': %s", params.Name, err.Error()), true), nil
+	}
+	return textResult(output, false), nil
+}
+
+// ServeStdio reads newline-delimited messages from r and writes the responses
+// to w until r is closed or ctx is cancelled. Requests are handled one at a time.
+func (s *Server) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageBytes)
+
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if reply := s.Handle(ctx, []byte(line)); reply != nil {
+			if _, err := fmt.Fprintf(w, "%s\n", reply); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// HTTPHandler serves the streamable HTTP transport: each POST carries a single
+// message and is answered with JSON. When token is set, requests must carry it
+// as a bearer token.
+func (s *Server) HTTPHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="fitbit-agent"`)
+				http.Error(w, "missing or invalid bearer token", http.StatusUnauthorized)
+				return
+			}
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		message, err := io.ReadAll(io.LimitReader(r.Body, maxMessageBytes))
+		if err != nil {
+			http.Error(w, "failed to read request", http.StatusBadRequest)
+			return
+		}
+
+		reply := s.Handle(r.Context(), message)
+		if reply == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(reply)
+	})
+}
+
+// encodeResponse builds a JSON-RPC response with either a result or an error
+func encodeResponse(id json.RawMessage, result interface{}, rpcErr *RPCError) []byte {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	resp := response{JSONRPC: "2.0", ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			resp.Error = &RPCError{Code: codeInternalError, Message: "failed to encode result"}
+		} else {
+			resp.Result = data
+		}
+	}
+
+	data, _ := json.Marshal(resp)
+	return data
+}
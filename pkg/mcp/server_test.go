@@ -0,0 +1,191 @@
+package mcp_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+	"github.com/vhbfernandes/fitbit-agent/pkg/mcp"
+	"github.com/vhbfernandes/fitbit-agent/pkg/registry"
+)
+
+// echoTool returns its input, or fails when asked to
+type echoTool struct{}
+
+func (t *echoTool) Name() string        { return "echo" }
+func (t *echoTool) Description() string { return "Echoes a message" }
+func (t *echoTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"message": map[string]interface{}{"type": "string"},
+		},
+		"required": []string{"message"},
+	}
+}
+
+func (t *echoTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+	var params struct {
+		Message string `json:"message"`
+	}
+	json.Unmarshal(input, &params)
+	if params.Message == "fail" {
+		return "", fmt.Errorf("asked to fail")
+	}
+	return "echo: " + params.Message, nil
+}
+
+// writeTool is a tool with side effects
+type writeTool struct{ echoTool }
+
+func (t *writeTool) Name() string      { return "write" }
+func (t *writeTool) SideEffects() bool { return true }
+func (t *writeTool) Preview(input json.RawMessage) (*agent.ActionPreview, error) {
+	return &agent.ActionPreview{Action: "Write"}, nil
+}
+
+func newTestServer() *mcp.Server {
+	toolRegistry := registry.NewDefaultToolRegistry()
+	toolRegistry.RegisterTool(&echoTool{})
+	toolRegistry.RegisterTool(&writeTool{})
+	return mcp.NewServer(toolRegistry, mcp.Implementation{Name: "test", Version: "1.0"})
+}
+
+func TestServerHandle(t *testing.T) {
+	server := newTestServer()
+
+	testCases := []struct {
+		name     string
+		message  string
+		contains []string
+	}{
+		{
+			name:     "Initialize negotiates the protocol version",
+			message:  `{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"protocolVersion": "2025-03-26", "capabilities": {}, "clientInfo": {"name": "c", "version": "1"}}}`,
+			contains: []string{`"id":1`, `"protocolVersion":"2025-03-26"`, `"tools":{`, `"serverInfo":{"name":"test"`},
+		},
+		{
+			name:     "Unknown versions get the latest one",
+			message:  `{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"protocolVersion": "1999-01-01"}}`,
+			contains: []string{`"protocolVersion":"` + mcp.ProtocolVersion + `"`},
+		},
+		{
+			name:     "Notifications get no response",
+			message:  `{"jsonrpc": "2.0", "method": "notifications/initialized"}`,
+			contains: nil,
+		},
+		{
+			name:     "Notification methods sent as requests are answered",
+			message:  `{"jsonrpc": "2.0", "id": 7, "method": "notifications/initialized"}`,
+			contains: []string{`"id":7`, `"error":{"code":-32601`, `method \"notifications/initialized\" not found`},
+		},
+		{
+			name:     "Tools are listed with their schemas",
+			message:  `{"jsonrpc": "2.0", "id": "a", "method": "tools/list"}`,
+			contains: []string{`"id":"a"`, `"name":"echo"`, `"inputSchema":{`, `"required":["message"]`, `"readOnlyHint":true`, `"name":"write"`, `"readOnlyHint":false`},
+		},
+		{
+			name:     "Tools are called with validated arguments",
+			message:  `{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "echo", "arguments": {"message": "hi"}}}`,
+			contains: []string{`"content":[{"type":"text","text":"echo: hi"}]`},
+		},
+		{
+			name:     "Invalid arguments are a tool error",
+			message:  `{"jsonrpc": "2.0", "id": 3, "method": "tools/call", "params": {"name": "echo", "arguments": {}}}`,
+			contains: []string{`"isError":true`, `invalid input for tool 'echo'`},
+		},
+		{
+			name:     "Failures are a tool error",
+			message:  `{"jsonrpc": "2.0", "id": 4, "method": "tools/call", "params": {"name": "echo", "arguments": {"message": "fail"}}}`,
+			contains: []string{`"isError":true`, `asked to fail`},
+		},
+		{
+			name:     "Unknown tools are a protocol error",
+			message:  `{"jsonrpc": "2.0", "id": 5, "method": "tools/call", "params": {"name": "nope"}}`,
+			contains: []string{`"error":{"code":-32602`},
+		},
+		{
+			name:     "Unknown methods are a protocol error",
+			message:  `{"jsonrpc": "2.0", "id": 6, "method": "resources/list"}`,
+			contains: []string{`"error":{"code":-32601`},
+		},
+		{
+			name:     "Invalid JSON is a parse error",
+			message:  `{"jsonrpc": `,
+			contains: []string{`"id":null`, `"error":{"code":-32700`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reply := server.Handle(context.Background(), []byte(tc.message))
+			if tc.contains == nil {
+				if reply != nil {
+					t.Errorf("expected no response, got %s", reply)
+				}
+				return
+			}
+			for _, want := range tc.contains {
+				if !strings.Contains(string(reply), want) {
+					t.Errorf("expected response to contain %s, got %s", want, reply)
+				}
+			}
+		})
+	}
+}
+
+func TestServerTransports(t *testing.T) {
+	server := newTestServer()
+
+	// stdio answers each request on its own line
+	input := strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "ping"}
+{"jsonrpc": "2.0", "method": "notifications/initialized"}
+
+{"jsonrpc": "2.0", "id": 2, "method": "tools/list"}
+`)
+	var output strings.Builder
+	if err := server.ServeStdio(context.Background(), input, &output); err != nil {
+		t.Fatalf("ServeStdio failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"id":1`) || !strings.Contains(lines[1], `"id":2`) {
+		t.Errorf("expected two responses, got %q", output.String())
+	}
+
+	// HTTP needs the token and answers with JSON
+	ts := httptest.NewServer(server.HTTPHandler("secret"))
+	defer ts.Close()
+
+	testCases := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+	}{
+		{name: "Requests without the token are rejected", body: `{"jsonrpc": "2.0", "id": 1, "method": "ping"}`, status: http.StatusUnauthorized},
+		{name: "Requests are answered", token: "secret", body: `{"jsonrpc": "2.0", "id": 1, "method": "ping"}`, status: http.StatusOK},
+		{name: "Notifications are accepted", token: "secret", body: `{"jsonrpc": "2.0", "method": "notifications/initialized"}`, status: http.StatusAccepted},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(tc.body))
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+// connectTimeout limits the time spent starting and listing each server
+const connectTimeout = 15 * time.Second
+
+// callTimeout limits a call to a remote tool in place of the global tool
+// timeout, as servers may do more work than the built-in tools, e.g. searching
+const callTimeout = 2 * time.Minute
+
+// Config lists the external MCP servers whose tools the agent can use, in the
+// "mcpServers" format shared by other MCP clients
+type Config struct {
+	Servers map[string]ServerConfig `json:"mcpServers"`
+}
+
+// LoadConfig reads the MCP server configuration. A missing file means no servers.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MCP config: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid MCP config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// LoadTools connects to every configured server and returns their tools and the
+// clients, which the caller must close to stop the servers. Servers that cannot
+// be used are skipped and reported in the errors.
+func LoadTools(ctx context.Context, cfg *Config) ([]agent.Tool, []*Client, []error) {
+	names := make([]string, 0, len(cfg.Servers))
+	for name := range cfg.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tools []agent.Tool
+	var clients []*Client
+	var errs []error
+	for _, name := range names {
+		serverTools, client, err := loadServerTools(ctx, name, cfg.Servers[name])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("MCP server %s: %w", name, err))
+			continue
+		}
+		tools = append(tools, serverTools...)
+		clients = append(clients, client)
+	}
+	return tools, clients, errs
+}
+
+// loadServerTools connects to a server and wraps each of its tools
+func loadServerTools(ctx context.Context, name string, serverCfg ServerConfig) ([]agent.Tool, *Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	client, err := Connect(ctx, name, serverCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	definitions, err := client.ListTools(ctx)
+	if err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+
+	tools := make([]agent.Tool, 0, len(definitions))
+	for _, definition := range definitions {
+		tools = append(tools, NewRemoteTool(client, definition))
+	}
+	return tools, client, nil
+}
+
+// invalidNameChars matches what LLM providers do not accept in tool names
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// RemoteTool is a tool offered by an external MCP server, so the LLM can call
+// it like the built-in tools. Its name is prefixed with the server's to keep
+// tools from different servers apart.
+type RemoteTool struct {
+	client     *Client
+	definition ToolDefinition
+}
+
+// NewRemoteTool wraps a tool offered by the client's server
+func NewRemoteTool(client *Client, definition ToolDefinition) *RemoteTool {
+	return &RemoteTool{client: client, definition: definition}
+}
+
+// Name returns the server and tool names, e.g. "recipes_search"
+func (t *RemoteTool) Name() string {
+	name := invalidNameChars.ReplaceAllString(t.client.Name()+"_"+t.definition.Name, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// Description returns the server's description of the tool
+func (t *RemoteTool) Description() string {
+	return fmt.Sprintf("%s (from the %s MCP server)", t.definition.Description, t.client.Name())
+}
+
+// InputSchema returns the tool's JSON schema as given by the server
+func (t *RemoteTool) InputSchema() map[string]interface{} {
+	if t.definition.InputSchema == nil {
+		return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	return t.definition.InputSchema
+}
+
+// Timeout returns how long a call to the server may take
+func (t *RemoteTool) Timeout() time.Duration {
+	return callTimeout
+}
+
+// Execute calls the tool on the server. A result marked as an error is returned
+// as one, so the LLM sees it failed.
+func (t *RemoteTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+	result, err := t.client.CallTool(ctx, t.definition.Name, input)
+	if err != nil {
+		return "", fmt.Errorf("%s MCP server: %w", t.client.Name(), err)
+	}
+	if result.IsError {
+		return "", errors.New(result.Text())
+	}
+	return result.Text(), nil
+}
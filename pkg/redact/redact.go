@@ -0,0 +1,69 @@
+// Package redact hides secrets such as tokens and passwords before text is
+// logged or written to disk
+package redact
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+)
+
+// Placeholder replaces every redacted value
+const Placeholder = "[REDACTED]"
+
+// sensitiveKey matches the names of values that must not be logged. Tokens are
+// matched at the end of the name, e.g. access_token or idToken, so counts such
+// as promptTokenCount are kept.
+var sensitiveKey = regexp.MustCompile(`(?i)token$|secret|password|passwd|api[_-]?key|authorization|credential|cookie|^code$`)
+
+// sensitiveText matches secrets written as key=value or "key": "value" in free text
+var sensitiveText = regexp.MustCompile(`(?i)((?:access_token|refresh_token|token|secret|password|api[_-]?key|authorization)["']?\s*[:=]\s*["']?)(?:Bearer\s+)?[^"'\s,&}]+`)
+
+// Text hides the values of secrets in JSON or free text
+func Text(text string) string {
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err == nil {
+		if _, isObject := value.(map[string]interface{}); isObject {
+			if data, err := json.Marshal(redactValue(value)); err == nil {
+				return string(data)
+			}
+		}
+	}
+	return sensitiveText.ReplaceAllString(text, "${1}"+Placeholder)
+}
+
+// Form hides the values of secrets in a URL-encoded form, such as the body of
+// an OAuth token request
+func Form(body string) string {
+	values, err := url.ParseQuery(body)
+	if err != nil {
+		return Text(body)
+	}
+	for key := range values {
+		if sensitiveKey.MatchString(key) {
+			values.Set(key, Placeholder)
+		}
+	}
+	return values.Encode()
+}
+
+// redactValue replaces the values of sensitive keys in decoded JSON
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, inner := range v {
+			if sensitiveKey.MatchString(key) {
+				v[key] = Placeholder
+			} else {
+				v[key] = redactValue(inner)
+			}
+		}
+	case []interface{}:
+		for i, inner := range v {
+			v[i] = redactValue(inner)
+		}
+	case string:
+		return sensitiveText.ReplaceAllString(v, "${1}"+Placeholder)
+	}
+	return value
+}
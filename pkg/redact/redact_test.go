@@ -0,0 +1,47 @@
+package redact
+
+import "testing"
+
+func TestText(t *testing.T) {
+	testCases := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "JSON keys", text: `{"api_key": "k1", "nested": {"refresh_token": "r1"}, "food": "toast"}`, want: `{"api_key":"[REDACTED]","food":"toast","nested":{"refresh_token":"[REDACTED]"}}`},
+		{name: "Free text", text: "request failed: access_token=abc123&user=me", want: "request failed: access_token=[REDACTED]&user=me"},
+		{name: "Bearer headers", text: "Authorization: Bearer abc123", want: "Authorization: [REDACTED]"},
+		{name: "Token keys", text: `{"id_token": "i1", "accessToken": "a1"}`, want: `{"accessToken":"[REDACTED]","id_token":"[REDACTED]"}`},
+		{name: "Token counts are kept", text: `{"usageMetadata": {"promptTokenCount": 12, "candidatesTokenCount": 30, "totalTokenCount": 42}}`, want: `{"usageMetadata":{"candidatesTokenCount":30,"promptTokenCount":12,"totalTokenCount":42}}`},
+		{name: "Token counts in streamed text are kept", text: `data: {"usageMetadata": {"totalTokenCount": 42}}`, want: `data: {"usageMetadata": {"totalTokenCount": 42}}`},
+		{name: "Nothing secret", text: "logged 2 eggs", want: "logged 2 eggs"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Text(tc.text); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestForm(t *testing.T) {
+	testCases := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "Code exchange", body: "client_id=abc&code=c1&grant_type=authorization_code", want: "client_id=abc&code=%5BREDACTED%5D&grant_type=authorization_code"},
+		{name: "Token refresh", body: "grant_type=refresh_token&refresh_token=r1&client_secret=s1", want: "client_secret=%5BREDACTED%5D&grant_type=refresh_token&refresh_token=%5BREDACTED%5D"},
+		{name: "Nothing secret", body: "meal=lunch", want: "meal=lunch"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Form(tc.body); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -1,35 +1,62 @@
 package registry
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
 
 	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
 	"github.com/vhbfernandes/fitbit-agent/pkg/config"
 	"github.com/vhbfernandes/fitbit-agent/pkg/input"
 	"github.com/vhbfernandes/fitbit-agent/pkg/llm"
+	"github.com/vhbfernandes/fitbit-agent/pkg/mcp"
+	"github.com/vhbfernandes/fitbit-agent/pkg/tools/actions"
 	"github.com/vhbfernandes/fitbit-agent/pkg/tools/fitbit"
 	"github.com/vhbfernandes/fitbit-agent/pkg/tools/storage"
 )
 
 // Container holds all dependencies
 type Container struct {
+	cfg           *config.Config
 	toolRegistry  agent.ToolRegistry
 	llmProvider   agent.LLMProvider
 	inputProvider agent.UserInputProvider
+	journal       *agent.Journal
+	metrics       *ToolMetrics
+	middleware    []Middleware
+	transport     http.RoundTripper
+	mcpClients    []*mcp.Client
 	agent         agent.Agent
 	llmError      error
 }
 
-// NewContainer creates a new dependency injection container
-func NewContainer(providerType, systemPrompt string) (*Container, error) {
-	// Create tool registry
-	toolRegistry := NewDefaultToolRegistry()
-
-	// Auto-discover and register tools
-	if err := autoDiscoverTools(toolRegistry); err != nil {
-		return nil, fmt.Errorf("failed to auto-discover tools: %w", err)
-	}
+// ContainerOptions overrides the default dependencies created by the container
+type ContainerOptions struct {
+	// InputProvider replaces the console input provider when set
+	InputProvider agent.UserInputProvider
+	// Store persists the conversation so it can be resumed
+	Store agent.ConversationStore
+	// AutoApprove runs side-effecting tool calls without asking
+	AutoApprove bool
+	// Output receives what the agent prints instead of stdout
+	Output io.Writer
+	// Transport carries the LLM and Fitbit HTTP requests when set, e.g. to
+	// record or replay them
+	Transport http.RoundTripper
+	// Journal records the agent's changes instead of the container's journal,
+	// so undo in one conversation cannot reverse another's changes
+	Journal *agent.Journal
+	// Headless refuses the exclusive tools, such as the Fitbit login, which need
+	// someone at this machine's terminal and browser
+	Headless bool
+}
 
+// NewContainer creates a new dependency injection container. It starts the
+// configured MCP servers, so callers must Close it when done.
+func NewContainer(providerType, systemPrompt string, opts ContainerOptions) (*Container, error) {
 	// Load system prompt with provided fallback
 	systemPromptConfig := config.LoadSystemPrompt()
 	if systemPrompt != "" && systemPromptConfig.IsDefault() {
@@ -46,42 +73,89 @@ func NewContainer(providerType, systemPrompt string) (*Container, error) {
 	}
 	cfg.SystemPrompt = systemPromptConfig
 
+	// Create tool registry with all discovered tools
+	toolRegistry, err := NewToolRegistry(cfg, opts.Transport)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tool calls are measured, logged, limited and protected from panics here
+	// rather than in each tool
+	metrics := NewToolMetrics()
+	middleware, err := DefaultMiddleware(cfg, metrics)
+	if err != nil {
+		return nil, err
+	}
+	toolRegistry.Use(middleware...)
+
+	// Tools from external MCP servers are offered next to the built-in ones
+	mcpClients := registerMCPTools(toolRegistry, cfg.MCPConfigFile)
+
+	// Side-effecting tool calls are journaled so they can be undone
+	journal := agent.NewJournal()
+	toolRegistry.RegisterTool(actions.NewUndoLastActionTool(journal, toolRegistry))
+
 	// Create LLM provider factory
 	factory := llm.NewProviderFactory(cfg, toolRegistry)
+	factory.Transport = opts.Transport
 
 	// Create LLM provider
 	llmProvider, llmError := factory.CreateProvider()
 
 	// Create input provider
-	inputProvider := input.NewConsoleInputProvider()
+	var inputProvider agent.UserInputProvider = input.NewConsoleInputProvider()
+	if opts.InputProvider != nil {
+		inputProvider = opts.InputProvider
+	}
 
 	container := &Container{
+		cfg:           cfg,
 		toolRegistry:  toolRegistry,
 		llmProvider:   llmProvider,
 		inputProvider: inputProvider,
+		journal:       journal,
+		metrics:       metrics,
+		middleware:    middleware,
+		transport:     opts.Transport,
+		mcpClients:    mcpClients,
 		llmError:      llmError,
 	}
 
 	// Only create agent if LLM provider was created successfully
 	if llmError == nil {
-		container.agent = agent.NewInteractiveAgent(
-			llmProvider,
-			toolRegistry,
-			inputProvider,
-		)
+		opts.InputProvider = inputProvider
+		container.agent = container.NewAgent(opts)
 	}
 
 	return container, nil
 }
 
+// NewToolRegistry creates a tool registry with the built-in tools and the
+// configured plugins registered. Tools from external MCP servers are only added
+// by the container. The Fitbit tools send their requests through transport when
+// it is set.
+func NewToolRegistry(cfg *config.Config, transport http.RoundTripper) (*DefaultToolRegistry, error) {
+	toolRegistry := NewDefaultToolRegistry()
+
+	// Auto-discover and register tools
+	if err := autoDiscoverTools(toolRegistry, cfg, transport); err != nil {
+		return nil, fmt.Errorf("failed to auto-discover tools: %w", err)
+	}
+
+	return toolRegistry, nil
+}
+
 // autoDiscoverTools automatically discovers and registers available tools
-func autoDiscoverTools(registry agent.ToolRegistry) error {
+func autoDiscoverTools(registry agent.ToolRegistry, cfg *config.Config, transport http.RoundTripper) error {
 	discovery := NewToolDiscovery(registry)
 
 	// Register Fitbit tools
 	fitbitLoginTool := fitbit.NewLoginTool()
 	fitbitLogMealTool := fitbit.NewLogMealTool()
 	fitbitGetProfileTool := fitbit.NewGetProfileTool()
+	fitbitLoginTool.Transport = transport
+	fitbitLogMealTool.Transport = transport
+	fitbitGetProfileTool.Transport = transport
 
 	// Register storage tools
 	saveMealTool := storage.NewSaveMealTool()
@@ -97,8 +171,102 @@ func autoDiscoverTools(registry agent.ToolRegistry) error {
 		viewSummaryTool,
 		foodDatabaseTool,
 	)
+	if err != nil {
+		return err
+	}
+
+	// Register executable plugins
+	discovery.RegisterPlugins(cfg.PluginDir, cfg.PluginAllowlist, cfg.PluginTimeout)
+	return nil
+}
+
+// registerMCPTools registers the tools of the MCP servers configured in path and
+// returns their clients, which must be closed to stop the servers. Servers that
+// cannot be reached are skipped so the built-in tools still work.
+func registerMCPTools(registry agent.ToolRegistry, path string) []*mcp.Client {
+	mcpConfig, err := mcp.LoadConfig(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Skipping MCP servers: %v\n", err)
+		return nil
+	}
+
+	tools, clients, errs := mcp.LoadTools(context.Background(), mcpConfig)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "⚠️  Skipping %v\n", err)
+	}
+	for _, tool := range tools {
+		if _, exists := registry.GetTool(tool.Name()); exists {
+			fmt.Fprintf(os.Stderr, "⚠️  Skipping MCP tool %s, a tool with that name already exists\n", tool.Name())
+			continue
+		}
+		registry.RegisterTool(tool)
+	}
+	return clients
+}
+
+// Close stops the MCP servers started for the container's tools
+func (c *Container) Close() error {
+	var errs []error
+	for _, client := range c.mcpClients {
+		if err := client.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("MCP server %s: %w", client.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
 
-	return err
+// NewAgent creates another agent sharing the container's LLM provider and tools,
+// for callers that hold several conversations at once. The input provider and
+// journal default to the container's; an agent given its own journal gets an
+// undo tool that only reverses the changes recorded in it.
+func (c *Container) NewAgent(opts ContainerOptions) *agent.InteractiveAgent {
+	inputProvider := c.inputProvider
+	if opts.InputProvider != nil {
+		inputProvider = opts.InputProvider
+	}
+
+	cfg, toolRegistry, journal := c.cfg, c.toolRegistry, c.journal
+	overlay := map[string]agent.Tool{}
+	if opts.Journal != nil {
+		journal = opts.Journal
+		undo := actions.NewUndoLastActionTool(journal, c.toolRegistry)
+		overlay[undo.Name()] = wrap(undo, c.middleware)
+	}
+	if opts.Headless {
+		for _, tool := range c.toolRegistry.GetAllTools() {
+			if exclusive, ok := agent.AsTool[agent.ExclusiveTool](tool); ok && exclusive.Exclusive() {
+				overlay[tool.Name()] = &refusedTool{Tool: tool}
+			}
+		}
+	}
+	if len(overlay) > 0 {
+		toolRegistry = &overlayRegistry{ToolRegistry: c.toolRegistry, tools: overlay}
+	}
+	return agent.NewInteractiveAgent(
+		c.llmProvider,
+		toolRegistry,
+		inputProvider,
+		agent.Options{
+			MaxHistoryTokens:    int(cfg.MaxTokens),
+			SummarizeHistory:    cfg.SummarizeHistory,
+			MaxRepairAttempts:   cfg.MaxRepairAttempts,
+			MaxParallelTools:    cfg.MaxParallelTools,
+			MaxStepsPerTurn:     cfg.MaxStepsPerTurn,
+			TurnTimeout:         cfg.TurnTimeout,
+			Store:               opts.Store,
+			AutoApproveCalories: cfg.AutoApproveCalories,
+			Journal:             journal,
+			AutoApprove:         opts.AutoApprove,
+			Output:              opts.Output,
+			SwitchProvider: func(name string) (agent.LLMProvider, error) {
+				switched := *cfg
+				switched.LLMProvider = name
+				factory := llm.NewProviderFactory(&switched, toolRegistry)
+				factory.Transport = c.transport
+				return factory.CreateProvider()
+			},
+		},
+	)
 }
 
 // GetAgent returns the configured agent
@@ -111,6 +279,11 @@ func (c *Container) GetToolRegistry() agent.ToolRegistry {
 	return c.toolRegistry
 }
 
+// GetToolMetrics returns the metrics collected for every tool call
+func (c *Container) GetToolMetrics() *ToolMetrics {
+	return c.metrics
+}
+
 // GetLLMProvider returns the LLM provider
 func (c *Container) GetLLMProvider() agent.LLMProvider {
 	return c.llmProvider
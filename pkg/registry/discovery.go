@@ -2,9 +2,12 @@ package registry
 
 import (
 	"fmt"
+	"os"
 	"reflect"
+	"time"
 
 	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+	"github.com/vhbfernandes/fitbit-agent/pkg/tools/plugins"
 )
 
 // ToolDiscovery handles automatic tool registration
@@ -24,7 +27,7 @@ func (td *ToolDiscovery) AutoRegisterTools(tools ...interface{}) error {
 	for _, tool := range tools {
 		if t, ok := tool.(agent.Tool); ok {
 			td.registry.RegisterTool(t)
-			fmt.Printf("Registered tool: %s\n", t.Name())
+			fmt.Fprintf(os.Stderr, "Registered tool: %s\n", t.Name())
 		} else {
 			return fmt.Errorf("object %v does not implement agent.Tool interface", reflect.TypeOf(tool))
 		}
@@ -37,6 +40,23 @@ func (td *ToolDiscovery) RegisterToolFactories(factories ...func() agent.Tool) {
 	for _, factory := range factories {
 		tool := factory()
 		td.registry.RegisterTool(tool)
-		fmt.Printf("Registered tool: %s\n", tool.Name())
+		fmt.Fprintf(os.Stderr, "Registered tool: %s\n", tool.Name())
+	}
+}
+
+// RegisterPlugins registers the executable plugins in dir that are named in the
+// allowlist. Invalid plugins and plugins named like an existing tool are skipped.
+func (td *ToolDiscovery) RegisterPlugins(dir string, allowlist []string, timeout time.Duration) {
+	tools, errs := plugins.Load(dir, allowlist, timeout)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "⚠️  Skipping %v\n", err)
+	}
+	for _, tool := range tools {
+		if _, exists := td.registry.GetTool(tool.Name()); exists {
+			fmt.Fprintf(os.Stderr, "⚠️  Skipping plugin %s, a tool with that name already exists\n", tool.Name())
+			continue
+		}
+		td.registry.RegisterTool(tool)
+		fmt.Fprintf(os.Stderr, "Registered plugin tool: %s\n", tool.Name())
 	}
 }
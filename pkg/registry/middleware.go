@@ -0,0 +1,269 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+	"github.com/vhbfernandes/fitbit-agent/pkg/config"
+	"github.com/vhbfernandes/fitbit-agent/pkg/redact"
+)
+
+// ExecuteFunc runs a tool with the given input
+type ExecuteFunc func(ctx context.Context, input json.RawMessage) (string, error)
+
+// Middleware wraps the execution of a tool, calling next to run it
+type Middleware func(tool agent.Tool, next ExecuteFunc) ExecuteFunc
+
+// middlewareTool is a tool whose Execute runs through the registry's middleware.
+// Unwrap exposes the tool so its optional interfaces are still found.
+type middlewareTool struct {
+	agent.Tool
+	execute ExecuteFunc
+}
+
+// Execute runs the tool through the middleware
+func (t *middlewareTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+	return t.execute(ctx, input)
+}
+
+// Unwrap returns the tool the middleware wraps
+func (t *middlewareTool) Unwrap() agent.Tool {
+	return t.Tool
+}
+
+// sideEffectMiddlewareTool is a middlewareTool around a tool with side effects.
+// A panic in its Preview becomes an error, as Recover does for Execute.
+type sideEffectMiddlewareTool struct {
+	*middlewareTool
+	writer agent.SideEffectTool
+}
+
+// SideEffects reports whether the wrapped tool changes anything
+func (t *sideEffectMiddlewareTool) SideEffects() bool {
+	return t.writer.SideEffects()
+}
+
+// Preview describes the call with the wrapped tool, recovering from a panic
+func (t *sideEffectMiddlewareTool) Preview(input json.RawMessage) (preview *agent.ActionPreview, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			preview, err = nil, fmt.Errorf("%s crashed while previewing: %v", t.Name(), r)
+		}
+	}()
+	return t.writer.Preview(input)
+}
+
+// DefaultMiddleware returns the middleware configured for every tool: metrics,
+// logging when a tool log is set, the per-call timeout and panic recovery
+func DefaultMiddleware(cfg *config.Config, metrics *ToolMetrics) ([]Middleware, error) {
+	middleware := []Middleware{Metrics(metrics)}
+
+	switch cfg.ToolLog {
+	case "":
+	case "stderr":
+		middleware = append(middleware, Logging(slog.New(slog.NewJSONHandler(os.Stderr, nil))))
+	default:
+		if err := os.MkdirAll(filepath.Dir(cfg.ToolLog), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create tool log directory: %w", err)
+		}
+		file, err := os.OpenFile(cfg.ToolLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open tool log: %w", err)
+		}
+		middleware = append(middleware, Logging(slog.New(slog.NewJSONHandler(file, nil))))
+	}
+
+	return append(middleware, Timeout(cfg.ToolTimeout), Recover()), nil
+}
+
+// wrap applies the middleware to a tool, the first one outermost
+func wrap(tool agent.Tool, middleware []Middleware) agent.Tool {
+	if len(middleware) == 0 {
+		return tool
+	}
+
+	execute := tool.Execute
+	for i := len(middleware) - 1; i >= 0; i-- {
+		execute = middleware[i](tool, execute)
+	}
+	wrapped := &middlewareTool{Tool: tool, execute: execute}
+	if writer, ok := agent.AsTool[agent.SideEffectTool](tool); ok {
+		return &sideEffectMiddlewareTool{middlewareTool: wrapped, writer: writer}
+	}
+	return wrapped
+}
+
+// Timeout limits each call to d, or to the tool's own limit if it is a
+// TimedTool. Exclusive tools such as the Fitbit login wait for the user and are
+// not limited. Neither are tools with side effects: abandoning one could leave
+// a change made but not journaled, so it could not be undone. A tool that
+// ignores its context is left to finish in the background.
+func Timeout(d time.Duration) Middleware {
+	return func(tool agent.Tool, next ExecuteFunc) ExecuteFunc {
+		limit := d
+		if timed, ok := agent.AsTool[agent.TimedTool](tool); ok {
+			limit = timed.Timeout()
+		}
+		exclusive, isExclusive := agent.AsTool[agent.ExclusiveTool](tool)
+		writer, isWriter := agent.AsTool[agent.SideEffectTool](tool)
+		if limit <= 0 || isExclusive && exclusive.Exclusive() || isWriter && writer.SideEffects() {
+			return next
+		}
+
+		return func(ctx context.Context, input json.RawMessage) (string, error) {
+			ctx, cancel := context.WithTimeout(ctx, limit)
+			defer cancel()
+
+			type result struct {
+				output string
+				err    error
+			}
+			done := make(chan result, 1)
+			go func() {
+				output, err := next(ctx, input)
+				done <- result{output, err}
+			}()
+
+			select {
+			case r := <-done:
+				if r.err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return "", fmt.Errorf("%s timed out after %s", tool.Name(), limit)
+				}
+				return r.output, r.err
+			case <-ctx.Done():
+				if ctx.Err() == context.DeadlineExceeded {
+					return "", fmt.Errorf("%s timed out after %s", tool.Name(), limit)
+				}
+				return "", ctx.Err()
+			}
+		}
+	}
+}
+
+// Recover turns a panic in a tool into an error, so one broken tool does not
+// crash the agent. It should come after Timeout, which runs tools on their own
+// goroutine.
+func Recover() Middleware {
+	return func(tool agent.Tool, next ExecuteFunc) ExecuteFunc {
+		return func(ctx context.Context, input json.RawMessage) (output string, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					output, err = "", fmt.Errorf("%s crashed: %v", tool.Name(), r)
+				}
+			}()
+			return next(ctx, input)
+		}
+	}
+}
+
+// maxLoggedOutput limits how much of a tool's output is logged
+const maxLoggedOutput = 500
+
+// Logging logs every call with its input, output, duration and error. Secrets
+// such as tokens and passwords are redacted.
+func Logging(logger *slog.Logger) Middleware {
+	return func(tool agent.Tool, next ExecuteFunc) ExecuteFunc {
+		return func(ctx context.Context, input json.RawMessage) (string, error) {
+			start := time.Now()
+			output, err := next(ctx, input)
+
+			attrs := []any{
+				"tool", tool.Name(),
+				"input", redact.Text(string(input)),
+				"duration_ms", time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				logger.WarnContext(ctx, "tool call failed", append(attrs, "error", redact.Text(err.Error()))...)
+			} else {
+				logged := redact.Text(output)
+				if len(logged) > maxLoggedOutput {
+					logged = logged[:maxLoggedOutput] + "..."
+				}
+				logger.InfoContext(ctx, "tool call", append(attrs, "output", logged)...)
+			}
+			return output, err
+		}
+	}
+}
+
+// ToolStats are the metrics collected for one tool
+type ToolStats struct {
+	Tool          string        `json:"tool"`
+	Calls         int           `json:"calls"`
+	Errors        int           `json:"errors"`
+	TotalDuration time.Duration `json:"total_duration_ns"`
+	MaxDuration   time.Duration `json:"max_duration_ns"`
+}
+
+// AverageDuration returns the mean duration of a call
+func (s ToolStats) AverageDuration() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.TotalDuration / time.Duration(s.Calls)
+}
+
+// ToolMetrics counts the calls, errors and time spent per tool
+type ToolMetrics struct {
+	stats map[string]*ToolStats
+	mu    sync.Mutex
+}
+
+// NewToolMetrics creates empty tool metrics
+func NewToolMetrics() *ToolMetrics {
+	return &ToolMetrics{stats: make(map[string]*ToolStats)}
+}
+
+// Metrics records the calls, errors and duration of every call in metrics
+func Metrics(m *ToolMetrics) Middleware {
+	return func(tool agent.Tool, next ExecuteFunc) ExecuteFunc {
+		return func(ctx context.Context, input json.RawMessage) (string, error) {
+			start := time.Now()
+			output, err := next(ctx, input)
+			m.record(tool.Name(), time.Since(start), err)
+			return output, err
+		}
+	}
+}
+
+// record adds a call to a tool's metrics
+func (m *ToolMetrics) record(name string, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.stats[name]
+	if !ok {
+		stats = &ToolStats{Tool: name}
+		m.stats[name] = stats
+	}
+	stats.Calls++
+	if err != nil {
+		stats.Errors++
+	}
+	stats.TotalDuration += duration
+	if duration > stats.MaxDuration {
+		stats.MaxDuration = duration
+	}
+}
+
+// Snapshot returns the metrics of every tool called so far, by name
+func (m *ToolMetrics) Snapshot() []ToolStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make([]ToolStats, 0, len(m.stats))
+	for _, stats := range m.stats {
+		snapshot = append(snapshot, *stats)
+	}
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].Tool < snapshot[j].Tool })
+	return snapshot
+}
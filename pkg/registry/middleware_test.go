@@ -0,0 +1,180 @@
+package registry_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+	"github.com/vhbfernandes/fitbit-agent/pkg/registry"
+)
+
+// funcTool runs a function as its Execute
+type funcTool struct {
+	name    string
+	execute func(ctx context.Context) (string, error)
+}
+
+func (t *funcTool) Name() string        { return t.name }
+func (t *funcTool) Description() string { return "Test tool" }
+func (t *funcTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+func (t *funcTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+	return t.execute(ctx)
+}
+
+// sideEffectTool is a tool with side effects
+type sideEffectTool struct{ funcTool }
+
+func (t *sideEffectTool) SideEffects() bool { return true }
+func (t *sideEffectTool) Preview(input json.RawMessage) (*agent.ActionPreview, error) {
+	return &agent.ActionPreview{Action: "Write"}, nil
+}
+
+// exclusiveTool must run alone, like the Fitbit login
+type exclusiveTool struct{ funcTool }
+
+func (t *exclusiveTool) Exclusive() bool { return true }
+
+// timedTool sets its own time limit, like a plugin
+type timedTool struct {
+	funcTool
+	timeout time.Duration
+}
+
+func (t *timedTool) Timeout() time.Duration { return t.timeout }
+
+// brokenPreviewTool panics while previewing
+type brokenPreviewTool struct{ sideEffectTool }
+
+func (t *brokenPreviewTool) Preview(input json.RawMessage) (*agent.ActionPreview, error) {
+	panic("no preview")
+}
+
+// reportDeadline tells how long the call was given, or "no deadline"
+func reportDeadline(ctx context.Context) (string, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return "no deadline", nil
+	}
+	return time.Until(deadline).Round(time.Hour).String(), nil
+}
+
+func TestMiddleware(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+	var logs bytes.Buffer
+	metrics := registry.NewToolMetrics()
+
+	toolRegistry := registry.NewDefaultToolRegistry()
+	toolRegistry.Use(
+		registry.Metrics(metrics),
+		registry.Logging(slog.New(slog.NewJSONHandler(&logs, nil))),
+		registry.Timeout(50*time.Millisecond),
+		registry.Recover(),
+	)
+
+	toolRegistry.RegisterTool(&funcTool{name: "ok", execute: func(ctx context.Context) (string, error) {
+		return `{"access_token": "abc123", "meal": "eggs"}`, nil
+	}})
+	toolRegistry.RegisterTool(&funcTool{name: "slow", execute: func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}})
+	toolRegistry.RegisterTool(&exclusiveTool{funcTool{name: "login", execute: reportDeadline}})
+	toolRegistry.RegisterTool(&timedTool{funcTool{name: "plugin", execute: reportDeadline}, time.Hour})
+	toolRegistry.RegisterTool(&funcTool{name: "panics", execute: func(ctx context.Context) (string, error) {
+		panic("boom")
+	}})
+	toolRegistry.RegisterTool(&sideEffectTool{funcTool{name: "write", execute: func(ctx context.Context) (string, error) {
+		if _, ok := ctx.Deadline(); ok {
+			return "", errors.New("write was given a deadline")
+		}
+		return "written", nil
+	}}})
+	toolRegistry.RegisterTool(&brokenPreviewTool{sideEffectTool{funcTool{name: "broken", execute: reportDeadline}}})
+
+	testCases := []struct {
+		name    string
+		tool    string
+		input   string
+		output  string
+		wantErr string
+	}{
+		{name: "Calls run through the middleware", tool: "ok", input: `{"password": "hunter2"}`, output: `{"access_token": "abc123", "meal": "eggs"}`},
+		{name: "Slow calls time out", tool: "slow", input: `{}`, wantErr: "slow timed out after 50ms"},
+		{name: "Exclusive tools are not timed out", tool: "login", input: `{}`, output: "no deadline"},
+		{name: "A tool's own timeout replaces the global one", tool: "plugin", input: `{}`, output: "1h0m0s"},
+		{name: "Panics become errors", tool: "panics", input: `{}`, wantErr: "panics crashed: boom"},
+		{name: "Tools with side effects are not timed out", tool: "write", input: `{}`, output: "written"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tool, found := toolRegistry.GetTool(tc.tool)
+			if !found {
+				t.Fatalf("tool %s not found", tc.tool)
+			}
+			output, err := tool.Execute(context.Background(), json.RawMessage(tc.input))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil || output != tc.output {
+				t.Errorf("expected %q, got %q (%v)", tc.output, output, err)
+			}
+		})
+	}
+
+	// Wrapping keeps the optional interfaces reachable
+	write, _ := toolRegistry.GetTool("write")
+	if _, ok := write.(agent.WrappedTool); !ok {
+		t.Error("expected the registry to return a wrapped tool")
+	}
+	if writer, ok := agent.AsTool[agent.SideEffectTool](write); !ok || !writer.SideEffects() {
+		t.Error("expected the wrapped tool to still have side effects")
+	}
+	if _, ok := agent.AsTool[agent.TimedTool](write); ok {
+		t.Error("expected only tools with their own timeout to be timed tools")
+	}
+
+	// A panic while previewing becomes an error too
+	broken, _ := toolRegistry.GetTool("broken")
+	writer, _ := agent.AsTool[agent.SideEffectTool](broken)
+	if _, err := writer.Preview(json.RawMessage(`{}`)); err == nil || !strings.Contains(err.Error(), "broken crashed while previewing: no preview") {
+		t.Errorf("expected the preview panic to become an error, got %v", err)
+	}
+
+	// Timed out calls do not leave goroutines behind
+	for start := time.Now(); runtime.NumGoroutine() > goroutines && time.Since(start) < time.Second; {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if leaked := runtime.NumGoroutine() - goroutines; leaked > 0 {
+		t.Errorf("expected no goroutines left running, got %d more", leaked)
+	}
+
+	// Secrets are redacted from the logs
+	if strings.Contains(logs.String(), "hunter2") || strings.Contains(logs.String(), "abc123") {
+		t.Errorf("expected secrets to be redacted, got %s", logs.String())
+	}
+	if !strings.Contains(logs.String(), `"tool":"ok"`) || !strings.Contains(logs.String(), "eggs") {
+		t.Errorf("expected the call to be logged, got %s", logs.String())
+	}
+
+	// Every call is counted
+	stats := map[string]registry.ToolStats{}
+	for _, s := range metrics.Snapshot() {
+		stats[s.Tool] = s
+	}
+	if stats["ok"].Calls != 1 || stats["ok"].Errors != 0 || stats["slow"].Errors != 1 || stats["panics"].Errors != 1 {
+		t.Errorf("unexpected metrics %+v", stats)
+	}
+}
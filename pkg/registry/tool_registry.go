@@ -1,15 +1,20 @@
 package registry
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"sync"
 
 	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+	"github.com/vhbfernandes/fitbit-agent/pkg/schema"
 )
 
 // DefaultToolRegistry implements the ToolRegistry interface
 type DefaultToolRegistry struct {
-	tools map[string]agent.Tool
-	mu    sync.RWMutex
+	tools      map[string]agent.Tool
+	middleware []Middleware
+	mu         sync.RWMutex
 }
 
 // NewDefaultToolRegistry creates a new tool registry
@@ -31,7 +36,10 @@ func (r *DefaultToolRegistry) GetTool(name string) (agent.Tool, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	tool, exists := r.tools[name]
-	return tool, exists
+	if !exists {
+		return nil, false
+	}
+	return wrap(tool, r.middleware), true
 }
 
 // GetAllTools returns all registered tools
@@ -41,11 +49,19 @@ func (r *DefaultToolRegistry) GetAllTools() []agent.Tool {
 
 	tools := make([]agent.Tool, 0, len(r.tools))
 	for _, tool := range r.tools {
-		tools = append(tools, tool)
+		tools = append(tools, wrap(tool, r.middleware))
 	}
 	return tools
 }
 
+// Use adds middleware around the Execute of every tool the registry returns,
+// the first one outermost
+func (r *DefaultToolRegistry) Use(middleware ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middleware = append(r.middleware, middleware...)
+}
+
 // GetToolDefinitions returns tool definitions for LLM
 func (r *DefaultToolRegistry) GetToolDefinitions() []agent.ToolDefinition {
 	r.mu.RLock()
@@ -61,3 +77,49 @@ func (r *DefaultToolRegistry) GetToolDefinitions() []agent.ToolDefinition {
 	}
 	return definitions
 }
+
+// ValidateInput validates and coerces input against the tool's input schema
+func (r *DefaultToolRegistry) ValidateInput(name string, input json.RawMessage) (json.RawMessage, error) {
+	tool, exists := r.GetTool(name)
+	if !exists {
+		return nil, fmt.Errorf("tool '%s' not found", name)
+	}
+	return schema.Validate(tool.InputSchema(), input)
+}
+
+// overlayRegistry serves some tools in place of those of a shared registry,
+// such as an undo tool bound to one conversation's journal
+type overlayRegistry struct {
+	agent.ToolRegistry
+	tools map[string]agent.Tool
+}
+
+// GetTool retrieves a tool by name, preferring the overlay
+func (r *overlayRegistry) GetTool(name string) (agent.Tool, bool) {
+	if tool, ok := r.tools[name]; ok {
+		return tool, true
+	}
+	return r.ToolRegistry.GetTool(name)
+}
+
+// GetAllTools returns all tools, with the overlay replacing those of the same name
+func (r *overlayRegistry) GetAllTools() []agent.Tool {
+	tools := r.ToolRegistry.GetAllTools()
+	for i, tool := range tools {
+		if replacement, ok := r.tools[tool.Name()]; ok {
+			tools[i] = replacement
+		}
+	}
+	return tools
+}
+
+// refusedTool stands in for a tool that cannot run here, so the LLM is told
+// why instead of the call hanging, e.g. waiting for a browser nobody sees
+type refusedTool struct {
+	agent.Tool
+}
+
+// Execute refuses to run the tool
+func (t *refusedTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+	return "", fmt.Errorf("%s needs someone at the agent's terminal and cannot run here; run it from the interactive chat instead", t.Name())
+}
@@ -0,0 +1,267 @@
+// Package schema validates tool inputs against the JSON-schema-like maps
+// returned by Tool.InputSchema, coercing near-misses the LLM commonly makes.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FieldError describes a single field that does not match the schema
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every field that does not match the schema
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		messages = append(messages, fmt.Sprintf("%s: %s", fieldErr.Path, fieldErr.Message))
+	}
+	return "invalid input: " + strings.Join(messages, "; ")
+}
+
+// Validate checks input against schema and returns the input with values
+// coerced to the declared types: numeric strings become numbers, "true" and
+// "false" become booleans, enums match case-insensitively and a single object
+// is wrapped when an array is expected. Properties missing from the schema are
+// passed through unchanged.
+func Validate(schema map[string]interface{}, input json.RawMessage) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(input)) == 0 {
+		input = json.RawMessage("{}")
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(input))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, &ValidationError{Errors: []FieldError{{Path: "$", Message: fmt.Sprintf("not valid JSON: %v", err)}}}
+	}
+
+	v := &validator{}
+	coerced := v.check("$", schema, value)
+	if len(v.errors) > 0 {
+		return nil, &ValidationError{Errors: v.errors}
+	}
+
+	output, err := json.Marshal(coerced)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode validated input: %w", err)
+	}
+	return output, nil
+}
+
+// validator collects field errors while walking a value
+type validator struct {
+	errors []FieldError
+}
+
+// fail records a field error
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.errors = append(v.errors, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// check validates value against schema and returns the coerced value
+func (v *validator) check(path string, schema map[string]interface{}, value interface{}) interface{} {
+	if schema == nil {
+		return value
+	}
+
+	typ, _ := schema["type"].(string)
+	switch typ {
+	case "object":
+		return v.checkObject(path, schema, value)
+	case "array":
+		return v.checkArray(path, schema, value)
+	case "string":
+		return v.checkString(path, schema, value)
+	case "number", "integer":
+		return v.checkNumber(path, typ, value)
+	case "boolean":
+		return v.checkBoolean(path, value)
+	}
+
+	return value
+}
+
+// checkObject validates required fields and every declared property
+func (v *validator) checkObject(path string, schema map[string]interface{}, value interface{}) interface{} {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		v.fail(path, "expected object, got %s", describe(value))
+		return value
+	}
+
+	for _, name := range stringList(schema["required"]) {
+		if field, exists := object[name]; !exists || field == nil {
+			v.fail(join(path, name), "is required")
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, declared := properties[name].(map[string]interface{})
+		if !declared || object[name] == nil {
+			continue
+		}
+		object[name] = v.check(join(path, name), property, object[name])
+	}
+
+	return object
+}
+
+// checkArray validates every item, wrapping a single value in an array
+func (v *validator) checkArray(path string, schema map[string]interface{}, value interface{}) interface{} {
+	items, ok := value.([]interface{})
+	if !ok {
+		items = []interface{}{value}
+	}
+
+	itemSchema, _ := schema["items"].(map[string]interface{})
+	for i, item := range items {
+		items[i] = v.check(fmt.Sprintf("%s[%d]", path, i), itemSchema, item)
+	}
+	return items
+}
+
+// checkString validates enums and patterns, converting numbers and booleans to text
+func (v *validator) checkString(path string, schema map[string]interface{}, value interface{}) interface{} {
+	var text string
+	switch val := value.(type) {
+	case string:
+		text = val
+	case json.Number:
+		text = val.String()
+	case bool:
+		text = strconv.FormatBool(val)
+	default:
+		v.fail(path, "expected string, got %s", describe(value))
+		return value
+	}
+
+	if enum := stringList(schema["enum"]); len(enum) > 0 {
+		match := ""
+		for _, option := range enum {
+			if strings.EqualFold(strings.TrimSpace(text), option) {
+				match = option
+				break
+			}
+		}
+		if match == "" {
+			v.fail(path, "must be one of %s, got %q", strings.Join(enum, ", "), text)
+			return value
+		}
+		text = match
+	}
+
+	if pattern, _ := schema["pattern"].(string); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err == nil && !re.MatchString(text) {
+			v.fail(path, "must match pattern %s, got %q", pattern, text)
+		}
+	}
+
+	return text
+}
+
+// checkNumber accepts numbers and numeric strings
+func (v *validator) checkNumber(path, typ string, value interface{}) interface{} {
+	var text string
+	switch val := value.(type) {
+	case json.Number:
+		text = val.String()
+	case string:
+		text = strings.TrimSpace(val)
+	default:
+		v.fail(path, "expected %s, got %s", typ, describe(value))
+		return value
+	}
+
+	number, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		v.fail(path, "expected %s, got %q", typ, text)
+		return value
+	}
+
+	if typ == "integer" {
+		if number != float64(int64(number)) {
+			v.fail(path, "expected integer, got %v", number)
+			return value
+		}
+		return int64(number)
+	}
+	return number
+}
+
+// checkBoolean accepts booleans and the strings "true" and "false"
+func (v *validator) checkBoolean(path string, value interface{}) interface{} {
+	switch val := value.(type) {
+	case bool:
+		return val
+	case string:
+		if parsed, err := strconv.ParseBool(strings.TrimSpace(val)); err == nil {
+			return parsed
+		}
+	}
+	v.fail(path, "expected boolean, got %s", describe(value))
+	return value
+}
+
+// describe names the JSON type of value for error messages
+func describe(value interface{}) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return fmt.Sprintf("string %q", val)
+	case json.Number:
+		return "number " + val.String()
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// join appends a property name to a path
+func join(path, name string) string {
+	if path == "$" {
+		return name
+	}
+	return path + "." + name
+}
+
+// stringList converts the []string or []interface{} used for enums and required lists
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			list = append(list, fmt.Sprintf("%v", item))
+		}
+		return list
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/schema"
+	"github.com/vhbfernandes/fitbit-agent/pkg/tools/fitbit"
+)
+
+func TestValidate(t *testing.T) {
+	logMeal := fitbit.NewLogMealTool().InputSchema()
+	getProfile := fitbit.NewGetProfileTool().InputSchema()
+
+	testCases := []struct {
+		name       string
+		schema     map[string]interface{}
+		input      string
+		want       string
+		wantFields []string
+	}{
+		{
+			name:   "Valid input is unchanged",
+			schema: logMeal,
+			input:  `{"meal_type": "lunch", "foods": [{"name": "salad", "quantity": 1, "unit": "bowl", "calories": 200}]}`,
+			want:   `{"foods":[{"calories":200,"name":"salad","quantity":1,"unit":"bowl"}],"meal_type":"lunch"}`,
+		},
+		{
+			name:   "Strings, enum case and single objects are coerced",
+			schema: logMeal,
+			input:  `{"meal_type": "Breakfast", "foods": {"name": "eggs", "quantity": "2", "unit": "large", "calories": "140"}, "days_count": "3"}`,
+			want:   `{"days_count":3,"foods":[{"calories":140,"name":"eggs","quantity":2,"unit":"large"}],"meal_type":"breakfast"}`,
+		},
+		{
+			name:       "Required fields, enums and types are reported together",
+			schema:     logMeal,
+			input:      `{"meal_type": "brunch", "foods": [{"name": "toast", "quantity": 2, "unit": "slices", "calories": "lots"}, {"name": "jam"}]}`,
+			wantFields: []string{"foods[0].calories", "foods[1].quantity", "foods[1].unit", "foods[1].calories", "meal_type"},
+		},
+		{
+			name:       "Missing required field",
+			schema:     logMeal,
+			input:      `{"foods": []}`,
+			wantFields: []string{"meal_type"},
+		},
+		{
+			name:   "Date matches the pattern",
+			schema: getProfile,
+			input:  `{"date": "2025-08-14"}`,
+			want:   `{"date":"2025-08-14"}`,
+		},
+		{
+			name:       "Date does not match the pattern",
+			schema:     getProfile,
+			input:      `{"date": "yesterday"}`,
+			wantFields: []string{"date"},
+		},
+		{
+			name:   "Empty input is an empty object",
+			schema: getProfile,
+			input:  ``,
+			want:   `{}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := schema.Validate(tc.schema, json.RawMessage(tc.input))
+
+			if len(tc.wantFields) == 0 {
+				if err != nil {
+					t.Fatalf("Expected no error but got: %v", err)
+				}
+				if string(got) != tc.want {
+					t.Errorf("Expected %s but got %s", tc.want, got)
+				}
+				return
+			}
+
+			var validationErr *schema.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected a validation error but got: %v", err)
+			}
+			var fields []string
+			for _, fieldErr := range validationErr.Errors {
+				fields = append(fields, fieldErr.Path)
+			}
+			if strings.Join(fields, ",") != strings.Join(tc.wantFields, ",") {
+				t.Errorf("Expected errors for %v but got %v", tc.wantFields, validationErr.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,402 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+	"github.com/vhbfernandes/fitbit-agent/pkg/input"
+	"github.com/vhbfernandes/fitbit-agent/pkg/registry"
+	"github.com/vhbfernandes/fitbit-agent/pkg/session"
+)
+
+// directTools maps the tool endpoints to the tools they run
+var directTools = map[string]string{
+	"log_meal": "fitbit_log_meal",
+	"summary":  "view_daily_summary",
+	"lookup":   "lookup_food_calories",
+}
+
+// maxBodyBytes limits the size of request bodies
+const maxBodyBytes = 1 << 20
+
+const (
+	// maxSessions is how many chat sessions are kept in memory
+	maxSessions = 100
+	// sessionIdleTTL is how long an unused chat session is kept in memory
+	sessionIdleTTL = 30 * time.Minute
+)
+
+// Server exposes the agent over HTTP. Each chat session keeps its own
+// conversation, saved in the session store, and its own undo journal, while the
+// LLM provider and tools are shared through the container. Sessions left idle,
+// or the least recently used beyond maxSessions, are dropped from memory and
+// loaded from the store again when used, without their undo history.
+type Server struct {
+	container *registry.Container
+	store     *session.Store
+	token     string
+
+	sessions    map[string]*chatSession
+	maxSessions int
+	idleTTL     time.Duration
+	mu          sync.Mutex
+}
+
+// chatSession is a conversation served over HTTP. Its turns run one at a time.
+type chatSession struct {
+	session *session.Session
+	journal *agent.Journal
+	mu      sync.Mutex
+
+	// Guarded by the server's mutex
+	lastUsed time.Time
+	users    int // requests holding the session, which keep it from being dropped
+}
+
+// NewServer creates a server for the container's agent. Every request except
+// the health check must carry token as a bearer token.
+func NewServer(container *registry.Container, store *session.Store, token string) (*Server, error) {
+	if token == "" {
+		return nil, fmt.Errorf("an API token is required")
+	}
+	if _, err := container.TryGetLLMProvider(); err != nil {
+		return nil, fmt.Errorf("cannot start agent: %w", err)
+	}
+
+	return &Server{
+		container:   container,
+		store:       store,
+		token:       token,
+		sessions:    make(map[string]*chatSession),
+		maxSessions: maxSessions,
+		idleTTL:     sessionIdleTTL,
+	}, nil
+}
+
+// Handler returns the HTTP handler serving the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/health", s.handleHealth)
+	mux.Handle("POST /api/chat", s.authenticate(s.handleChat))
+	mux.Handle("GET /api/sessions/{id}", s.authenticate(s.handleGetSession))
+	mux.Handle("GET /api/tools", s.authenticate(s.handleListTools))
+	mux.Handle("GET /api/metrics", s.authenticate(s.handleMetrics))
+	mux.Handle("POST /api/tools/{name}", s.authenticate(s.handleTool))
+	return mux
+}
+
+// ListenAndServe serves the API on addr until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// authenticate rejects requests without the server's bearer token
+func (s *Server) authenticate(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="fitbit-agent"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// handleHealth reports that the server is up, without authentication
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	provider, _ := s.container.TryGetLLMProvider()
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "provider": provider.Name()})
+}
+
+// chatRequest is the body of POST /api/chat
+type chatRequest struct {
+	SessionID string `json:"session_id,omitempty"` // empty starts a new session
+	Message   string `json:"message"`
+	Approve   bool   `json:"approve,omitempty"` // run side-effecting tool calls without asking
+}
+
+// chatResponse is the reply to POST /api/chat
+type chatResponse struct {
+	SessionID string `json:"session_id"`
+	*agent.TurnResult
+}
+
+// handleChat sends a message to the session's conversation. Side-effecting
+// tool calls are declined unless the request approves them, as there is no one
+// to ask. With Accept: text/event-stream the agent's output is streamed as it
+// works, followed by the result.
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var req chatRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		writeError(w, http.StatusBadRequest, "message is required")
+		return
+	}
+
+	chat, err := s.chatSession(req.SessionID)
+	if errors.Is(err, session.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer s.release(chat)
+
+	var stream *eventStream
+	var output io.Writer = io.Discard
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		if stream, err = newEventStream(w); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		output = stream
+	}
+
+	chat.mu.Lock()
+	defer chat.mu.Unlock()
+
+	chatAgent := s.container.NewAgent(registry.ContainerOptions{
+		InputProvider: input.NewLinesInputProvider(nil),
+		Store:         chat.session,
+		AutoApprove:   req.Approve,
+		Output:        output,
+		Journal:       chat.journal,
+		Headless:      true,
+	})
+	result, err := chatAgent.RunTurn(r.Context(), req.Message)
+
+	if stream != nil {
+		if err != nil {
+			stream.send("error", map[string]string{"error": err.Error()})
+			return
+		}
+		stream.send("result", chatResponse{SessionID: chat.session.ID, TurnResult: result})
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, chatResponse{SessionID: chat.session.ID, TurnResult: result})
+}
+
+// chatSession returns the session with the given ID, loading it from the store
+// when it is not in memory, or starts a new one when id is empty. The caller
+// must release it when done.
+func (s *Server) chatSession(id string) (*chatSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if chat, ok := s.sessions[id]; ok && (chat.users > 0 || now.Sub(chat.lastUsed) <= s.idleTTL) {
+		chat.users++
+		chat.lastUsed = now
+		return chat, nil
+	}
+	s.evict(now)
+
+	var sess *session.Session
+	if id == "" {
+		provider, _ := s.container.TryGetLLMProvider()
+		sess = s.store.Create(provider.Name())
+	} else {
+		var err error
+		if sess, err = s.store.Get(id); err != nil {
+			return nil, err
+		}
+	}
+
+	chat := &chatSession{session: sess, journal: agent.NewJournal(), lastUsed: now, users: 1}
+	s.sessions[sess.ID] = chat
+	return chat, nil
+}
+
+// release marks a session returned by chatSession as no longer in use
+func (s *Server) release(chat *chatSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chat.users--
+	chat.lastUsed = time.Now()
+}
+
+// evict drops the sessions idle for longer than the TTL, then the least
+// recently used ones until there is room for another. Sessions in use are kept.
+// The caller must hold s.mu.
+func (s *Server) evict(now time.Time) {
+	for id, chat := range s.sessions {
+		if chat.users == 0 && now.Sub(chat.lastUsed) > s.idleTTL {
+			delete(s.sessions, id)
+		}
+	}
+
+	for len(s.sessions) >= s.maxSessions {
+		var oldest string
+		for id, chat := range s.sessions {
+			if chat.users == 0 && (oldest == "" || chat.lastUsed.Before(s.sessions[oldest].lastUsed)) {
+				oldest = id
+			}
+		}
+		if oldest == "" {
+			return
+		}
+		delete(s.sessions, oldest)
+	}
+}
+
+// handleGetSession returns a session and its messages
+func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	chat, err := s.chatSession(r.PathValue("id"))
+	if errors.Is(err, session.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer s.release(chat)
+
+	chat.mu.Lock()
+	defer chat.mu.Unlock()
+	writeJSON(w, http.StatusOK, chat.session)
+}
+
+// toolInfo describes a tool endpoint
+type toolInfo struct {
+	Endpoint    string                 `json:"endpoint"`
+	Tool        string                 `json:"tool"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// handleListTools lists the tools that can be run directly
+func (s *Server) handleListTools(w http.ResponseWriter, r *http.Request) {
+	tools := []toolInfo{}
+	for endpoint, name := range directTools {
+		tool, found := s.container.GetToolRegistry().GetTool(name)
+		if !found {
+			continue
+		}
+		tools = append(tools, toolInfo{
+			Endpoint:    "/api/tools/" + endpoint,
+			Tool:        name,
+			Description: tool.Description(),
+			InputSchema: tool.InputSchema(),
+		})
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Endpoint < tools[j].Endpoint })
+	writeJSON(w, http.StatusOK, tools)
+}
+
+// handleTool runs a tool directly with the request body as its input, without
+// the LLM. The request itself approves any change. With a session_id query
+// parameter the change is recorded in that session, so it can be undone in its
+// chat; otherwise it cannot be undone.
+func (s *Server) handleTool(w http.ResponseWriter, r *http.Request) {
+	name, ok := directTools[r.PathValue("name")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown tool %q", r.PathValue("name")))
+		return
+	}
+	if _, found := s.container.GetToolRegistry().GetTool(name); !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("the %s tool is not available", name))
+		return
+	}
+
+	var params json.RawMessage
+	if err := decodeBody(r, &params); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(params) == 0 {
+		params = json.RawMessage(`{}`)
+	}
+
+	journal := agent.NewJournal()
+	if id := r.URL.Query().Get("session_id"); id != "" {
+		chat, err := s.chatSession(id)
+		if errors.Is(err, session.ErrNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		} else if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		defer s.release(chat)
+		chat.mu.Lock()
+		defer chat.mu.Unlock()
+		journal = chat.journal
+	}
+
+	toolAgent := s.container.NewAgent(registry.ContainerOptions{
+		InputProvider: input.NewLinesInputProvider(nil),
+		Output:        io.Discard,
+		Journal:       journal,
+		Headless:      true,
+	})
+	result := toolAgent.ExecuteTool(r.Context(), name, params)
+
+	status := http.StatusOK
+	if result.IsError {
+		status = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, status, result)
+}
+
+// handleMetrics returns the calls, errors and durations of every tool called so far
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.container.GetToolMetrics().Snapshot())
+}
+
+// decodeBody parses a JSON request body into v. An empty body leaves v unchanged.
+func decodeBody(r *http.Request, v interface{}) error {
+	err := json.NewDecoder(io.LimitReader(r.Body, maxBodyBytes)).Decode(v)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return nil
+}
+
+// writeJSON writes v as the JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/session"
+)
+
+func TestChatSessionEviction(t *testing.T) {
+	store := session.NewStore(t.TempDir())
+	ids := make([]string, 3)
+	for i := range ids {
+		sess := store.Create("scripted")
+		sess.ID += string(rune('a' + i))
+		if err := sess.Save(nil); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		ids[i] = sess.ID
+	}
+
+	s := &Server{store: store, sessions: map[string]*chatSession{}, maxSessions: 2, idleTTL: time.Hour}
+	open := func(id string) *chatSession {
+		chat, err := s.chatSession(id)
+		if err != nil {
+			t.Fatalf("chatSession failed: %v", err)
+		}
+		return chat
+	}
+
+	first := open(ids[0])
+	s.release(first)
+	second := open(ids[1])
+
+	// The least recently used session makes room for a new one
+	s.release(open(ids[2]))
+	if _, ok := s.sessions[ids[0]]; ok || len(s.sessions) != 2 {
+		t.Errorf("expected %s to be evicted, got %d sessions", ids[0], len(s.sessions))
+	}
+
+	// Sessions in use are not evicted
+	s.release(open(ids[0]))
+	if s.sessions[ids[1]] != second {
+		t.Errorf("expected %s to be kept while in use", ids[1])
+	}
+	s.release(second)
+
+	// Idle sessions expire and are loaded again
+	s.sessions[ids[1]].lastUsed = time.Now().Add(-2 * time.Hour)
+	if again := open(ids[1]); again == second {
+		t.Errorf("expected the idle session to be loaded again")
+	}
+}
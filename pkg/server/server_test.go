@@ -0,0 +1,316 @@
+package server_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/registry"
+	"github.com/vhbfernandes/fitbit-agent/pkg/server"
+	"github.com/vhbfernandes/fitbit-agent/pkg/session"
+)
+
+const testToken = "secret-token"
+
+// newTestServer serves the agent with a scripted LLM replaying script
+func newTestServer(t *testing.T, script string) *httptest.Server {
+	t.Helper()
+
+	dir := t.TempDir()
+	scriptFile := filepath.Join(dir, "script.json")
+	if err := os.WriteFile(scriptFile, []byte(script), 0600); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	t.Setenv("HOME", dir)
+	t.Setenv("LLM_PROVIDER", "scripted")
+	t.Setenv("SCRIPTED_LLM_FILE", scriptFile)
+	t.Setenv("LLM_FALLBACK_PROVIDERS", "")
+
+	container, err := registry.NewContainer("scripted", "", registry.ContainerOptions{})
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	t.Cleanup(func() { container.Close() })
+	apiServer, err := server.NewServer(container, session.NewStore(filepath.Join(dir, "sessions")), testToken)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	ts := httptest.NewServer(apiServer.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// call sends a request to the test server and decodes the JSON response into out
+func call(t *testing.T, ts *httptest.Server, method, path, token, body string, out interface{}) int {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("invalid JSON response: %v", err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestServerEndpoints(t *testing.T) {
+	ts := newTestServer(t, `{"turns": []}`)
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+	}{
+		{
+			name:   "Health check needs no token",
+			method: http.MethodGet,
+			path:   "/api/health",
+			status: http.StatusOK,
+		},
+		{
+			name:   "Requests without a token are rejected",
+			method: http.MethodGet,
+			path:   "/api/tools",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "Requests with the wrong token are rejected",
+			method: http.MethodPost,
+			path:   "/api/chat",
+			token:  "wrong",
+			body:   `{"message": "hi"}`,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "Tool endpoints are listed",
+			method: http.MethodGet,
+			path:   "/api/tools",
+			token:  testToken,
+			status: http.StatusOK,
+		},
+		{
+			name:   "Tool metrics are shown",
+			method: http.MethodGet,
+			path:   "/api/metrics",
+			token:  testToken,
+			status: http.StatusOK,
+		},
+		{
+			name:   "Lookup runs the tool directly",
+			method: http.MethodPost,
+			path:   "/api/tools/lookup",
+			token:  testToken,
+			body:   `{"food_name": "apple"}`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "Summary works without a body",
+			method: http.MethodPost,
+			path:   "/api/tools/summary",
+			token:  testToken,
+			status: http.StatusOK,
+		},
+		{
+			name:   "Invalid tool input is rejected",
+			method: http.MethodPost,
+			path:   "/api/tools/lookup",
+			token:  testToken,
+			body:   `{}`,
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "Only the tool endpoints can be run",
+			method: http.MethodPost,
+			path:   "/api/tools/fitbit_login",
+			token:  testToken,
+			body:   `{}`,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "Chat needs a message",
+			method: http.MethodPost,
+			path:   "/api/chat",
+			token:  testToken,
+			body:   `{"message": " "}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "Unknown sessions are not found",
+			method: http.MethodPost,
+			path:   "/api/chat",
+			token:  testToken,
+			body:   `{"session_id": "20240101-000000-0000", "message": "hi"}`,
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out interface{}
+			if status := call(t, ts, tc.method, tc.path, tc.token, tc.body, &out); status != tc.status {
+				t.Errorf("expected status %d, got %d: %v", tc.status, status, out)
+			}
+		})
+	}
+}
+
+func TestServerChat(t *testing.T) {
+	ts := newTestServer(t, `{"turns": [
+		{"content": "What did you have for breakfast?"},
+		{"expect": {"contains": ["eggs"]}, "content": "Sounds good."},
+		{"content": "Streaming hello"}
+	]}`)
+
+	// A new session is started and then continued
+	var first struct {
+		SessionID string `json:"session_id"`
+		Reply     string `json:"reply"`
+	}
+	if status := call(t, ts, http.MethodPost, "/api/chat", testToken, `{"message": "log my breakfast"}`, &first); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	if first.SessionID == "" || first.Reply != "What did you have for breakfast?" {
+		t.Fatalf("unexpected response: %+v", first)
+	}
+
+	var second struct {
+		SessionID string `json:"session_id"`
+		Reply     string `json:"reply"`
+	}
+	body := `{"session_id": "` + first.SessionID + `", "message": "two eggs"}`
+	if status := call(t, ts, http.MethodPost, "/api/chat", testToken, body, &second); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	if second.SessionID != first.SessionID || second.Reply != "Sounds good." {
+		t.Fatalf("unexpected response: %+v", second)
+	}
+
+	var saved session.Session
+	if status := call(t, ts, http.MethodGet, "/api/sessions/"+first.SessionID, testToken, "", &saved); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	if len(saved.Messages) != 4 {
+		t.Errorf("expected 4 saved messages, got %d", len(saved.Messages))
+	}
+
+	// The reply is streamed as server-sent events
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/chat", strings.NewReader(`{"message": "hi"}`))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", contentType)
+	}
+
+	var events []string
+	var result string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if event, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			events = append(events, event)
+		}
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			result = data
+		}
+	}
+
+	if len(events) < 2 || events[0] != "output" || events[len(events)-1] != "result" {
+		t.Errorf("expected output events followed by a result, got %v", events)
+	}
+	if !strings.Contains(result, "Streaming hello") || strings.Contains(result, first.SessionID) {
+		t.Errorf("expected the result of a new session, got %s", result)
+	}
+}
+
+func TestServerUndoIsPerSession(t *testing.T) {
+	ts := newTestServer(t, `{"turns": [
+		{"tool_calls": [{"name": "save_meal_locally", "input": {"meal_data": {"meal_type": "lunch", "foods": [{"name": "salad", "calories": 200}]}}}]},
+		{"content": "Saved."},
+		{"tool_calls": [{"name": "undo_last_action"}]},
+		{"content": "Nothing to undo."},
+		{"tool_calls": [{"name": "undo_last_action"}]},
+		{"content": "Undone."}
+	]}`)
+
+	type turn struct {
+		SessionID   string `json:"session_id"`
+		ToolResults []struct {
+			Content string `json:"content"`
+			IsError bool   `json:"is_error"`
+		} `json:"tool_results"`
+	}
+	chat := func(sessionID, message string) turn {
+		var out turn
+		body := `{"session_id": "` + sessionID + `", "message": "` + message + `", "approve": true}`
+		if status := call(t, ts, http.MethodPost, "/api/chat", testToken, body, &out); status != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", status)
+		}
+		if len(out.ToolResults) != 1 {
+			t.Fatalf("expected one tool result, got %+v", out)
+		}
+		return out
+	}
+
+	saved := chat("", "save my lunch")
+	if saved.ToolResults[0].IsError {
+		t.Fatalf("expected the meal to be saved, got %+v", saved)
+	}
+
+	// Another session has nothing to undo
+	other := chat("", "undo that")
+	if other.SessionID == saved.SessionID || strings.Contains(other.ToolResults[0].Content, "Undid") {
+		t.Errorf("expected undo in another session to leave the meal alone, got %+v", other)
+	}
+
+	undone := chat(saved.SessionID, "undo that")
+	if !strings.Contains(undone.ToolResults[0].Content, "Undid") {
+		t.Errorf("expected the meal to be undone in its own session, got %+v", undone)
+	}
+}
+
+func TestServerRefusesInteractiveTools(t *testing.T) {
+	ts := newTestServer(t, `{"turns": [
+		{"tool_calls": [{"name": "fitbit_login"}]},
+		{"content": "Please log in from the terminal."}
+	]}`)
+
+	var out struct {
+		ToolResults []struct {
+			Content string `json:"content"`
+			IsError bool   `json:"is_error"`
+		} `json:"tool_results"`
+	}
+	if status := call(t, ts, http.MethodPost, "/api/chat", testToken, `{"message": "log me in", "approve": true}`, &out); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	if len(out.ToolResults) != 1 || !out.ToolResults[0].IsError || !strings.Contains(out.ToolResults[0].Content, "cannot run here") {
+		t.Errorf("expected the login to be refused, got %+v", out.ToolResults)
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+)
+
+// ansiEscape matches the terminal colour codes in the agent's output
+var ansiEscape = regexp.MustCompile("\u001b\\[[0-9;]*m")
+
+// eventStream sends server-sent events. As an io.Writer it sends whatever the
+// agent prints as "output" events, without terminal colours.
+type eventStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	mu      sync.Mutex
+}
+
+// newEventStream starts a server-sent events response
+func newEventStream(w http.ResponseWriter) (*eventStream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming is not supported")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &eventStream{w: w, flusher: flusher}, nil
+}
+
+// Write sends p as an output event
+func (s *eventStream) Write(p []byte) (int, error) {
+	if text := ansiEscape.ReplaceAllString(string(p), ""); text != "" {
+		s.send("output", map[string]string{"text": text})
+	}
+	return len(p), nil
+}
+
+// send sends an event with v as its JSON data
+func (s *eventStream) send(event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data)
+	s.flusher.Flush()
+}
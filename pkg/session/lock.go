@@ -0,0 +1,42 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// lockWait is how long a write waits for another process to release a session
+	lockWait = 5 * time.Second
+	// staleLock is the age after which a lock is assumed to belong to a process
+	// that died while holding it
+	staleLock = 30 * time.Second
+)
+
+// lockFile takes the lock file at path, waiting while another process holds
+// it, and returns the function releasing it. The lock is a file created
+// exclusively, so it works across processes and platforms.
+func lockFile(path string) (func(), error) {
+	deadline := time.Now().Add(lockWait)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to lock session: %w", err)
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("failed to lock session: %s is held by another process", path)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
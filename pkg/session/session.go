@@ -0,0 +1,242 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+// ErrNotFound is returned when a session does not exist
+var ErrNotFound = errors.New("session not found")
+
+// ErrConflict is returned when saving a session another process has saved
+// since it was loaded, instead of overwriting those messages
+var ErrConflict = errors.New("session was changed by another process")
+
+// Session is a conversation saved to disk so it can be resumed later
+type Session struct {
+	ID        string          `json:"id"`
+	Provider  string          `json:"provider,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Messages  []agent.Message `json:"messages"`
+
+	store   *Store
+	savedAt time.Time // UpdatedAt of the file this session was last read from or written to
+}
+
+// Load returns the saved messages, so a session can be passed to the agent as
+// its conversation store
+func (s *Session) Load() ([]agent.Message, error) {
+	return s.Messages, nil
+}
+
+// Save replaces the saved messages and writes the session to disk. When the
+// write fails the session is left as it was. If another process saved the
+// session in the meantime, this conversation continues as a new session so
+// neither is lost; the conflict is still reported, once.
+func (s *Session) Save(conversation []agent.Message) error {
+	updated := *s
+	updated.Messages = conversation
+	updated.UpdatedAt = time.Now()
+
+	err := s.store.write(&updated)
+	if errors.Is(err, ErrConflict) {
+		forked := updated
+		forked.ID = newID(forked.UpdatedAt)
+		forked.savedAt = time.Time{}
+		if s.store.write(&forked) == nil {
+			*s = forked
+			return fmt.Errorf("%w, this conversation continues as session %s", err, forked.ID)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	*s = updated
+	return nil
+}
+
+// Title returns the first thing the user said, to tell sessions apart in listings
+func (s *Session) Title() string {
+	for _, msg := range s.Messages {
+		if text, ok := msg.Content.(string); ok && msg.Role == "user" {
+			text = strings.Join(strings.Fields(text), " ")
+			if len(text) > 60 {
+				text = text[:57] + "..."
+			}
+			return text
+		}
+	}
+	return "(empty)"
+}
+
+// Store keeps sessions as JSON files in a directory
+type Store struct {
+	dir string
+}
+
+// NewStore creates a store for sessions in dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// DefaultDir returns ~/.fitbit-agent/sessions
+func DefaultDir() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".fitbit-agent", "sessions")
+}
+
+// Create starts a new, empty session. It is written on the first Save.
+func (s *Store) Create(provider string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:        newID(now),
+		Provider:  provider,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Messages:  []agent.Message{},
+		store:     s,
+	}
+}
+
+// newID returns a session ID that sorts by the time it was created
+func newID(now time.Time) string {
+	return fmt.Sprintf("%s-%04x", now.Format("20060102-150405"), rand.Intn(0x10000))
+}
+
+// Get loads the session with the given ID
+func (s *Store) Get(id string) (*Session, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session %s: %w", id, err)
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", id, err)
+	}
+	session.store = s
+	session.savedAt = session.UpdatedAt
+	return &session, nil
+}
+
+// List returns every session, most recently updated first
+func (s *Store) List() ([]*Session, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*Session, 0, len(paths))
+	for _, path := range paths {
+		session, err := s.Get(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			// One damaged file should not hide every other conversation
+			fmt.Fprintf(os.Stderr, "⚠️  Skipping session file %s: %v\n", path, err)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// Latest returns the most recently updated session
+func (s *Store) Latest() (*Session, error) {
+	sessions, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, ErrNotFound
+	}
+	return sessions[0], nil
+}
+
+// Delete removes the session with the given ID
+func (s *Store) Delete(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	} else if err != nil {
+		return fmt.Errorf("failed to delete session %s: %w", id, err)
+	}
+	return nil
+}
+
+// write saves the session, replacing the previous file in one step so an
+// interrupted write never leaves a truncated session behind. The file is locked
+// while it is checked and replaced, so another process holding the same
+// session, such as the server and a terminal chat, cannot overwrite its turns.
+func (s *Store) write(session *Session) error {
+	path, err := s.path(session.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if saved, err := s.Get(session.ID); err == nil && !saved.UpdatedAt.Equal(session.savedAt) {
+		return fmt.Errorf("%w: %s", ErrConflict, session.ID)
+	}
+
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	session.savedAt = session.UpdatedAt
+	return nil
+}
+
+// path returns the file holding a session, rejecting IDs that would escape the directory
+func (s *Store) path(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid session ID %q", id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
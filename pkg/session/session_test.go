@@ -0,0 +1,152 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+
+	if _, err := store.Latest(); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound for an empty store but got: %v", err)
+	}
+
+	first := store.Create("scripted")
+	conversation := []agent.Message{
+		{Role: "user", Content: "I had eggs for breakfast"},
+		{Role: "assistant", Content: "Logging it", ToolCalls: []agent.ToolCall{{ID: "call_1", Name: "fitbit_log_meal"}}},
+		{Role: "tool", Content: agent.ToolResult{CallID: "call_1", Name: "fitbit_log_meal", Content: "logged"}},
+	}
+	if err := first.Save(conversation); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	second := store.Create("scripted")
+	second.ID = first.ID + "-b"
+	time.Sleep(10 * time.Millisecond)
+	if err := second.Save([]agent.Message{{Role: "user", Content: "hello"}}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	t.Run("Tool results are restored", func(t *testing.T) {
+		loaded, err := store.Get(first.ID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		messages, _ := loaded.Load()
+		if len(messages) != 3 {
+			t.Fatalf("Expected 3 messages but got %d", len(messages))
+		}
+		if result, ok := messages[2].Content.(agent.ToolResult); !ok || result.CallID != "call_1" {
+			t.Errorf("Expected a ToolResult for call_1 but got %#v", messages[2].Content)
+		}
+		if messages[1].ToolCalls[0].ID != "call_1" {
+			t.Errorf("Expected tool calls to be restored, got %v", messages[1].ToolCalls)
+		}
+		if loaded.Title() != "I had eggs for breakfast" {
+			t.Errorf("Unexpected title %q", loaded.Title())
+		}
+	})
+
+	t.Run("Latest is the most recently updated", func(t *testing.T) {
+		latest, err := store.Latest()
+		if err != nil {
+			t.Fatalf("Latest failed: %v", err)
+		}
+		if latest.ID != second.ID {
+			t.Errorf("Expected %s but got %s", second.ID, latest.ID)
+		}
+	})
+
+	t.Run("Delete removes the session", func(t *testing.T) {
+		if err := store.Delete(second.ID); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if _, err := store.Get(second.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound after delete but got: %v", err)
+		}
+		sessions, _ := store.List()
+		if len(sessions) != 1 {
+			t.Errorf("Expected 1 session left but got %d", len(sessions))
+		}
+	})
+
+	t.Run("List skips corrupt files", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(dir, "20250101-000000-beef.json"), []byte(`{"id": "trunc`), 0600); err != nil {
+			t.Fatal(err)
+		}
+		sessions, err := store.List()
+		if err != nil {
+			t.Fatalf("Expected the corrupt file to be skipped but got: %v", err)
+		}
+		if len(sessions) != 1 || sessions[0].ID != first.ID {
+			t.Errorf("Expected only %s but got %d sessions", first.ID, len(sessions))
+		}
+	})
+
+	t.Run("Save does not overwrite another process's changes", func(t *testing.T) {
+		mine, err := store.Get(first.ID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		theirs, err := NewStore(dir).Get(first.ID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+
+		if err := theirs.Save(append(theirs.Messages, agent.Message{Role: "user", Content: "and coffee"})); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		if err := mine.Save(append(mine.Messages, agent.Message{Role: "user", Content: "and juice"})); !errors.Is(err, ErrConflict) {
+			t.Fatalf("Expected ErrConflict but got: %v", err)
+		}
+		if err := theirs.Save(theirs.Messages); err != nil {
+			t.Errorf("Expected the process that saved last to keep saving but got: %v", err)
+		}
+
+		// The conflicting conversation continues as a new session, reported once
+		if mine.ID == first.ID {
+			t.Fatalf("Expected the conflicting conversation to get a new ID")
+		}
+		if err := mine.Save(append(mine.Messages, agent.Message{Role: "user", Content: "and toast"})); err != nil {
+			t.Errorf("Expected later saves to work but got: %v", err)
+		}
+		forked, err := store.Get(mine.ID)
+		if err != nil || len(forked.Messages) != len(mine.Messages) {
+			t.Errorf("Expected the new session to hold the whole conversation but got %v (%v)", forked, err)
+		}
+
+		loaded, _ := store.Get(first.ID)
+		if last := loaded.Messages[len(loaded.Messages)-1]; last.Content != "and coffee" {
+			t.Errorf("Expected the other process's message to be kept but got %v", last.Content)
+		}
+		if _, err := os.Stat(filepath.Join(dir, first.ID+".json.lock")); !os.IsNotExist(err) {
+			t.Errorf("Expected the lock to be released but got: %v", err)
+		}
+	})
+
+	t.Run("A failed save leaves the session unchanged", func(t *testing.T) {
+		broken := store.Create("scripted")
+		broken.ID = "../outside"
+		updatedAt := broken.UpdatedAt
+		if err := broken.Save([]agent.Message{{Role: "user", Content: "hello"}}); err == nil {
+			t.Fatal("Expected the save to fail")
+		}
+		if len(broken.Messages) != 0 || !broken.UpdatedAt.Equal(updatedAt) {
+			t.Errorf("Expected the session to be unchanged but got %d messages, updated %s", len(broken.Messages), broken.UpdatedAt)
+		}
+	})
+
+	t.Run("IDs cannot escape the directory", func(t *testing.T) {
+		if _, err := store.Get("../secrets"); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected an invalid ID error but got: %v", err)
+		}
+	})
+}
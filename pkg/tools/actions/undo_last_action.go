@@ -0,0 +1,121 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+// UndoLastActionTool reverses the most recent meals logged to Fitbit or saved locally
+type UndoLastActionTool struct {
+	journal  *agent.Journal
+	registry agent.ToolRegistry
+}
+
+// NewUndoLastActionTool creates an undo tool for the actions recorded in journal.
+// registry provides the tools that reverse each action.
+func NewUndoLastActionTool(journal *agent.Journal, registry agent.ToolRegistry) *UndoLastActionTool {
+	return &UndoLastActionTool{
+		journal:  journal,
+		registry: registry,
+	}
+}
+
+// Name returns the tool name
+func (t *UndoLastActionTool) Name() string {
+	return "undo_last_action"
+}
+
+// Description returns the tool description
+func (t *UndoLastActionTool) Description() string {
+	return "Undo the most recent meal(s) logged to Fitbit or saved locally in this session. Use when the user says a logged meal was wrong, then log the corrected meal."
+}
+
+// InputSchema returns the input schema for the tool
+func (t *UndoLastActionTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"count": map[string]interface{}{
+				"type":        "integer",
+				"description": "Number of actions to undo, most recent first (defaults to 1)",
+			},
+		},
+	}
+}
+
+// UndoInput represents the input for the undo tool
+type UndoInput struct {
+	Count int `json:"count,omitempty"`
+}
+
+// parseInput reads the number of actions to undo
+func parseInput(input json.RawMessage) (int, error) {
+	var undoInput UndoInput
+	if len(input) > 0 {
+		if err := json.Unmarshal(input, &undoInput); err != nil {
+			return 0, fmt.Errorf("failed to parse input: %w", err)
+		}
+	}
+	if undoInput.Count < 1 {
+		return 1, nil
+	}
+	return undoInput.Count, nil
+}
+
+// SideEffects reports that undoing changes data, so the user confirms it first
+func (t *UndoLastActionTool) SideEffects() bool {
+	return true
+}
+
+// Preview describes the action that would be undone
+func (t *UndoLastActionTool) Preview(input json.RawMessage) (*agent.ActionPreview, error) {
+	count, err := parseInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := t.journal.Last(count)
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("nothing to undo")
+	}
+	if len(actions) == 1 && actions[0].Preview != nil {
+		preview := *actions[0].Preview
+		preview.Action = "Undo " + strings.ToLower(preview.Action)
+		return &preview, nil
+	}
+
+	var summaries []string
+	for _, action := range actions {
+		summaries = append(summaries, action.Summary())
+	}
+	return &agent.ActionPreview{Action: "Undo " + strings.Join(summaries, "; ")}, nil
+}
+
+// Execute undoes the most recent actions
+func (t *UndoLastActionTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+	count, err := parseInput(input)
+	if err != nil {
+		return "", err
+	}
+
+	undone, err := t.journal.Undo(ctx, t.registry, count)
+
+	var lines []string
+	for _, action := range undone {
+		lines = append(lines, "↩️ Undid: "+action.Summary())
+	}
+	if err != nil {
+		if len(lines) == 0 {
+			return "", err
+		}
+		return "", fmt.Errorf("%s\n%w", strings.Join(lines, "\n"), err)
+	}
+	if len(lines) == 0 {
+		return "Nothing to undo, no meals have been logged or saved in this session.", nil
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,82 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+// mealTool records undone meals
+type mealTool struct {
+	undone []string
+}
+
+func (t *mealTool) Name() string        { return "log_meal" }
+func (t *mealTool) Description() string { return "Logs a meal" }
+func (t *mealTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+func (t *mealTool) SideEffects() bool { return true }
+
+func (t *mealTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+	return "logged", nil
+}
+
+func (t *mealTool) Preview(input json.RawMessage) (*agent.ActionPreview, error) {
+	return &agent.ActionPreview{Action: "Log", MealType: string(input)}, nil
+}
+
+func (t *mealTool) Undo(ctx context.Context, step json.RawMessage) error {
+	t.undone = append(t.undone, string(step))
+	return nil
+}
+
+// toolRegistry holds a single tool
+type toolRegistry struct {
+	agent.ToolRegistry
+	tool agent.Tool
+}
+
+func (r *toolRegistry) GetTool(name string) (agent.Tool, bool) {
+	return r.tool, name == r.tool.Name()
+}
+
+func TestUndoLastActionTool(t *testing.T) {
+	meals := &mealTool{}
+
+	journal := agent.NewJournal()
+	for _, meal := range []string{"breakfast", "lunch", "dinner"} {
+		journal.Record(agent.Action{
+			Tool:    "log_meal",
+			Preview: &agent.ActionPreview{Action: "Log", MealType: meal},
+			Steps:   []json.RawMessage{json.RawMessage(`"` + meal + `-eggs"`), json.RawMessage(`"` + meal + `-toast"`)},
+		})
+	}
+	tool := NewUndoLastActionTool(journal, &toolRegistry{tool: meals})
+
+	preview, err := tool.Preview(json.RawMessage(`{}`))
+	if err != nil || preview.Action != "Undo log" || preview.MealType != "dinner" {
+		t.Fatalf("Expected a preview of undoing dinner, got %+v (%v)", preview, err)
+	}
+
+	result, err := tool.Execute(context.Background(), json.RawMessage(`{"count": 2}`))
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if !strings.Contains(result, "dinner") || !strings.Contains(result, "lunch") {
+		t.Errorf("Expected dinner and lunch to be undone, got: %s", result)
+	}
+	want := `"dinner-toast","dinner-eggs","lunch-toast","lunch-eggs"`
+	if got := strings.Join(meals.undone, ","); got != want {
+		t.Errorf("Expected changes undone in reverse order %s but got %s", want, got)
+	}
+
+	tool.Execute(context.Background(), json.RawMessage(`{}`))
+	result, err = tool.Execute(context.Background(), json.RawMessage(`{}`))
+	if err != nil || !strings.Contains(result, "Nothing to undo") {
+		t.Errorf("Expected nothing left to undo, got %q (%v)", result, err)
+	}
+}
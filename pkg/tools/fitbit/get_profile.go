@@ -10,7 +10,10 @@ import (
 )
 
 // GetProfileTool retrieves user profile and daily nutrition stats from Fitbit
-type GetProfileTool struct{}
+type GetProfileTool struct {
+	// Transport carries the requests to Fitbit, e.g. to record them (optional)
+	Transport http.RoundTripper
+}
 
 // NewGetProfileTool creates a new profile tool
 func NewGetProfileTool() *GetProfileTool {
@@ -96,15 +99,15 @@ Note: This is simulated data. Connect your real Fitbit account for actual statis
 }
 
 // validateToken checks if the access token is still valid
-func (t *GetProfileTool) validateToken(token string) error {
-	req, err := http.NewRequest("GET", "https://api.fitbit.com/1/user/-/profile.json", nil)
+func (t *GetProfileTool) validateToken(ctx context.Context, token string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.fitbit.com/1/user/-/profile.json", nil)
 	if err != nil {
 		return err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+token)
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := &http.Client{Timeout: 10 * time.Second, Transport: t.Transport}
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
@@ -13,11 +13,15 @@ import (
 	"strings"
 	"time"
 
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
 	"github.com/vhbfernandes/fitbit-agent/pkg/config"
 )
 
 // LogMealTool handles logging meals to Fitbit
-type LogMealTool struct{}
+type LogMealTool struct {
+	// Transport carries the requests to Fitbit, e.g. to record them (optional)
+	Transport http.RoundTripper
+}
 
 // NewLogMealTool creates a new meal logging tool
 func NewLogMealTool() *LogMealTool {
@@ -149,8 +153,17 @@ type ParsedFoodItem struct {
 	Calories float64
 }
 
-// Execute logs the meal to Fitbit
-func (t *LogMealTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+// mealPlan is a parsed meal and the dates it will be logged on
+type mealPlan struct {
+	input         LogMealInput
+	mealType      string
+	foods         []ParsedFoodItem
+	totalCalories float64
+	dates         []time.Time
+}
+
+// parseMeal turns the flexible tool input into a meal plan, without contacting Fitbit
+func (t *LogMealTool) parseMeal(input json.RawMessage) (*mealPlan, error) {
 	// First, try to handle cases where input is wrapped in an extra "input" field
 	var rawInput json.RawMessage = input
 
@@ -168,25 +181,25 @@ func (t *LogMealTool) Execute(ctx context.Context, input json.RawMessage) (strin
 			if len(inputPreview) > 100 {
 				inputPreview = inputPreview[:100] + "..."
 			}
-			return "", fmt.Errorf("received truncated or invalid JSON input. Please ensure the complete meal data is provided. Got: %s", inputPreview)
+			return nil, fmt.Errorf("received truncated or invalid JSON input. Please ensure the complete meal data is provided. Got: %s", inputPreview)
 		}
 	}
 
 	var mealInput LogMealInput
 	if err := json.Unmarshal(rawInput, &mealInput); err != nil {
-		return "", fmt.Errorf("failed to parse meal input: %w. Raw input: %s", err, string(rawInput))
+		return nil, fmt.Errorf("failed to parse meal input: %w. Raw input: %s", err, string(rawInput))
 	}
 
 	// Normalize meal type
 	mealType := normalizeMealType(mealInput.MealType)
 	if mealType == "" {
-		return "", fmt.Errorf("invalid or missing meal type. Must be one of: breakfast, lunch, dinner, snack. Got: %q", mealInput.MealType)
+		return nil, fmt.Errorf("invalid or missing meal type. Must be one of: breakfast, lunch, dinner, snack. Got: %q", mealInput.MealType)
 	}
 
 	// Collect all food items from various possible fields
 	allFoods := collectAllFoods(mealInput)
 	if len(allFoods) == 0 {
-		return "", fmt.Errorf("no food items found. Please provide at least one food item")
+		return nil, fmt.Errorf("no food items found. Please provide at least one food item")
 	}
 
 	// Parse foods into consistent format
@@ -194,22 +207,11 @@ func (t *LogMealTool) Execute(ctx context.Context, input json.RawMessage) (strin
 	for i, food := range allFoods {
 		parsed, err := t.parseFoodItem(food)
 		if err != nil {
-			return "", fmt.Errorf("error parsing food item %d (%s): %w", i+1, getAnyFoodName(food), err)
+			return nil, fmt.Errorf("error parsing food item %d (%s): %w", i+1, getAnyFoodName(food), err)
 		}
 		parsedFoods = append(parsedFoods, parsed)
 	}
 
-	// Check authentication first
-	if !t.isAuthenticated() {
-		return `🔐 Authentication Required!
-
-To log meals to Fitbit, you need to authenticate first. Let me help you with that.
-
-TOOL_CALL: fitbit_login({})
-
-After authentication, I'll log your meal automatically.`, nil
-	}
-
 	// Calculate total calories and validate
 	totalCalories := 0.0
 	for _, food := range parsedFoods {
@@ -222,7 +224,7 @@ After authentication, I'll log your meal automatically.`, nil
 		if err == nil && expectedTotal > 0 {
 			diff := totalCalories - expectedTotal
 			if diff < -50 || diff > 50 { // Allow 50 calorie difference
-				return "", fmt.Errorf("calorie mismatch: calculated %.0f calories but expected %.0f calories", totalCalories, expectedTotal)
+				return nil, fmt.Errorf("calorie mismatch: calculated %.0f calories but expected %.0f calories", totalCalories, expectedTotal)
 			}
 		}
 	}
@@ -246,21 +248,86 @@ After authentication, I'll log your meal automatically.`, nil
 		startDate = time.Now().AddDate(0, 0, 1)
 	}
 
-	// Make actual API calls to Fitbit for each day
-	var loggedDates []string
-	for i := 0; i < daysCount; i++ {
-		currentDate := startDate.AddDate(0, 0, i)
-		err := t.logMealToFitbit(ctx, mealType, parsedFoods, mealInput, currentDate)
-		if err != nil {
-			// If unauthorized, suggest re-authentication
-			if strings.Contains(err.Error(), "401") || strings.Contains(err.Error(), "unauthorized") {
-				return `🔐 Authentication Expired!
+	dates := make([]time.Time, daysCount)
+	for i := range dates {
+		dates[i] = startDate.AddDate(0, 0, i)
+	}
 
-Your Fitbit access token has expired. Let me help you re-authenticate.
+	return &mealPlan{
+		input:         mealInput,
+		mealType:      mealType,
+		foods:         parsedFoods,
+		totalCalories: totalCalories,
+		dates:         dates,
+	}, nil
+}
+
+// SideEffects reports that this tool writes to the user's Fitbit account
+func (t *LogMealTool) SideEffects() bool {
+	return true
+}
+
+// Preview describes the meal that would be logged and on which dates
+func (t *LogMealTool) Preview(input json.RawMessage) (*agent.ActionPreview, error) {
+	plan, err := t.parseMeal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &agent.ActionPreview{Action: "Log to Fitbit", MealType: plan.mealType}
+	for _, date := range plan.dates {
+		preview.Dates = append(preview.Dates, date.Format("2006-01-02"))
+	}
+	for _, food := range plan.foods {
+		preview.Foods = append(preview.Foods, agent.PreviewFood{
+			Name:     food.Name,
+			Quantity: food.Quantity,
+			Unit:     food.Unit,
+			Calories: food.Calories,
+		})
+	}
+	return preview, nil
+}
+
+// Execute logs the meal to Fitbit
+func (t *LogMealTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+	plan, err := t.parseMeal(input)
+	if err != nil {
+		return "", err
+	}
 
-TOOL_CALL: fitbit_login({})
+	// Check authentication first; the agent logs in and retries this call
+	if !t.isAuthenticated() {
+		return "", &agent.FollowUpError{
+			Reason:        "Fitbit authentication required",
+			Tool:          "fitbit_login",
+			Input:         json.RawMessage("{}"),
+			RetryOriginal: true,
+		}
+	}
 
-After re-authentication, I'll log your meal automatically.`, nil
+	// Make actual API calls to Fitbit for each day
+	var loggedDates, loggedFoods []string
+	for _, currentDate := range plan.dates {
+		posted, err := t.logMealToFitbit(ctx, plan.mealType, plan.foods, plan.input, currentDate)
+		for _, food := range posted {
+			loggedFoods = append(loggedFoods, fmt.Sprintf("%s on %s", food, currentDate.Format("2006-01-02")))
+		}
+		if err != nil {
+			// If unauthorized before any food was logged, re-authenticate and
+			// retry; retrying after that would log those foods twice
+			unauthorized := strings.Contains(err.Error(), "401") || strings.Contains(err.Error(), "unauthorized")
+			if unauthorized && len(loggedFoods) == 0 {
+				return "", &agent.FollowUpError{
+					Reason:        "Fitbit access token expired",
+					Tool:          "fitbit_login",
+					Input:         json.RawMessage(`{"force_reauth": true}`),
+					RetryOriginal: true,
+				}
+			}
+			if len(loggedFoods) > 0 {
+				return "", fmt.Errorf("failed to log meal to Fitbit for %s: %w. Already logged, do not log these again: %s",
+					currentDate.Format("2006-01-02"), err, strings.Join(loggedFoods, ", "))
 			}
 			return "", fmt.Errorf("failed to log meal to Fitbit for %s: %w", currentDate.Format("2006-01-02"), err)
 		}
@@ -269,7 +336,7 @@ After re-authentication, I'll log your meal automatically.`, nil
 
 	// Format success response
 	var foodList []string
-	for _, food := range parsedFoods {
+	for _, food := range plan.foods {
 		foodStr := fmt.Sprintf("- %s (%s %s): ~%.0f cal",
 			food.Name,
 			formatQuantity(food.Quantity),
@@ -280,19 +347,19 @@ After re-authentication, I'll log your meal automatically.`, nil
 
 	// Build result message
 	var result string
-	if daysCount == 1 {
+	if len(plan.dates) == 1 {
 		// Single day logging
-		mealTime := getMealTime(mealInput)
+		mealTime := getMealTime(plan.input)
 		result = fmt.Sprintf(`✅ Successfully logged %s to Fitbit (%s):
 %s
 
 💯 Total: ~%.0f calories
 
 🎉 Meal logged to your Fitbit account! Check your Fitbit app to see the nutrition data.`,
-			mealType,
+			plan.mealType,
 			mealTime,
 			strings.Join(foodList, "\n"),
-			totalCalories)
+			plan.totalCalories)
 	} else {
 		// Multiple days logging
 		result = fmt.Sprintf(`✅ Successfully logged %s to Fitbit for %d days (%s):
@@ -302,16 +369,16 @@ After re-authentication, I'll log your meal automatically.`, nil
 🗓️ Logged for: %s
 
 🎉 All meals logged to your Fitbit account! Check your Fitbit app to see the nutrition data for each day.`,
-			mealType,
-			daysCount,
+			plan.mealType,
+			len(plan.dates),
 			strings.Join(loggedDates, ", "),
 			strings.Join(foodList, "\n"),
-			totalCalories,
+			plan.totalCalories,
 			strings.Join(loggedDates, ", "))
 	}
 
 	// Add notes if provided
-	notes := getNotes(mealInput)
+	notes := getNotes(plan.input)
 	if notes != "" {
 		result += fmt.Sprintf("\n📝 Notes: %s", notes)
 	}
@@ -639,25 +706,27 @@ func (t *LogMealTool) isAuthenticated() bool {
 	return token != ""
 }
 
-// logMealToFitbit makes the actual API call to Fitbit to log the meal
-func (t *LogMealTool) logMealToFitbit(ctx context.Context, mealType string, foods []ParsedFoodItem, input LogMealInput, targetDate time.Time) error {
+// logMealToFitbit makes the actual API call to Fitbit to log the meal. It
+// returns the names of the foods logged, also when a later one fails.
+func (t *LogMealTool) logMealToFitbit(ctx context.Context, mealType string, foods []ParsedFoodItem, input LogMealInput, targetDate time.Time) ([]string, error) {
 	config.LoadConfig()
 	accessToken := os.Getenv("FITBIT_ACCESS_TOKEN")
 	userID := os.Getenv("FITBIT_USER_ID")
 
 	if accessToken == "" {
-		return fmt.Errorf("missing FITBIT_ACCESS_TOKEN")
+		return nil, fmt.Errorf("missing FITBIT_ACCESS_TOKEN")
 	}
 	if userID == "" {
-		return fmt.Errorf("missing FITBIT_USER_ID")
+		return nil, fmt.Errorf("missing FITBIT_USER_ID")
 	}
 
 	// Get the date for the meal
 	date := targetDate.Format("2006-01-02")
 
 	// Log each food item individually to Fitbit
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Timeout: 30 * time.Second, Transport: t.Transport}
 
+	var posted []string
 	for _, food := range foods {
 		// Convert meal type to Fitbit meal ID
 		mealID := getMealID(mealType)
@@ -675,7 +744,7 @@ func (t *LogMealTool) logMealToFitbit(ctx context.Context, mealType string, food
 		apiURL := fmt.Sprintf("https://api.fitbit.com/1/user/%s/foods/log.json", userID)
 		req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBufferString(formData.Encode()))
 		if err != nil {
-			return fmt.Errorf("failed to create request for %s: %w", food.Name, err)
+			return posted, fmt.Errorf("failed to create request for %s: %w", food.Name, err)
 		}
 
 		// Set headers
@@ -685,26 +754,74 @@ func (t *LogMealTool) logMealToFitbit(ctx context.Context, mealType string, food
 		// Make the request
 		resp, err := client.Do(req)
 		if err != nil {
-			return fmt.Errorf("failed to log %s to Fitbit: %w", food.Name, err)
+			return posted, fmt.Errorf("failed to log %s to Fitbit: %w", food.Name, err)
 		}
 		defer resp.Body.Close()
 
 		// Check response status
 		if resp.StatusCode == 401 {
-			return fmt.Errorf("unauthorized: access token may be expired (401)")
+			return posted, fmt.Errorf("unauthorized: access token may be expired (401)")
 		}
 		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			return fmt.Errorf("failed to log %s: HTTP %d", food.Name, resp.StatusCode)
+			return posted, fmt.Errorf("failed to log %s: HTTP %d", food.Name, resp.StatusCode)
+		}
+
+		// Remember the food log ID so the entry can be deleted by undo
+		var logged struct {
+			FoodLog struct {
+				LogID int64 `json:"logId"`
+			} `json:"foodLog"`
 		}
+		if err := json.NewDecoder(resp.Body).Decode(&logged); err == nil && logged.FoodLog.LogID != 0 {
+			agent.RecordUndo(ctx, foodLogUndo{LogID: logged.FoodLog.LogID, Food: food.Name, Date: date})
+		}
+		posted = append(posted, food.Name)
+	}
+
+	return posted, nil
+}
+
+// foodLogUndo identifies a Fitbit food log entry created by this tool
+type foodLogUndo struct {
+	LogID int64  `json:"log_id"`
+	Food  string `json:"food"`
+	Date  string `json:"date"`
+}
+
+// Undo deletes a food log entry created by Execute
+func (t *LogMealTool) Undo(ctx context.Context, step json.RawMessage) error {
+	var entry foodLogUndo
+	if err := json.Unmarshal(step, &entry); err != nil {
+		return fmt.Errorf("invalid undo step: %w", err)
+	}
+
+	accessToken := os.Getenv("FITBIT_ACCESS_TOKEN")
+	userID := os.Getenv("FITBIT_USER_ID")
+	if accessToken == "" || userID == "" {
+		return fmt.Errorf("not authenticated with Fitbit")
+	}
 
-		// Read response body for debugging
-		var responseBody bytes.Buffer
-		responseBody.ReadFrom(resp.Body)
+	apiURL := fmt.Sprintf("https://api.fitbit.com/1/user/%s/foods/log/%d.json", userID, entry.LogID)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", apiURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-		// For successful requests, we could parse the response to get the food log ID
-		// but for now we'll just check the status code
+	client := &http.Client{Timeout: 30 * time.Second, Transport: t.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s from Fitbit: %w", entry.Food, err)
 	}
+	defer resp.Body.Close()
 
+	// An entry that is already gone needs no undoing
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to delete %s from Fitbit: HTTP %d", entry.Food, resp.StatusCode)
+	}
 	return nil
 }
 
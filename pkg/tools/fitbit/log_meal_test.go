@@ -3,8 +3,13 @@ package fitbit
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
 	"strings"
 	"testing"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
 )
 
 func TestLogMealFlexibility(t *testing.T) {
@@ -56,22 +61,46 @@ func TestLogMealFlexibility(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			result, err := tool.Execute(context.Background(), json.RawMessage(tc.input))
 
-			if tc.wantErr && err == nil {
-				t.Errorf("Expected error but got none. Result: %s", result)
+			// Valid input should ask for a login follow-up since no token is set
+			var followUp *agent.FollowUpError
+			isFollowUp := errors.As(err, &followUp)
+
+			if tc.wantErr && (err == nil || isFollowUp) {
+				t.Errorf("Expected input error but got: %v. Result: %s", err, result)
 			}
-			if !tc.wantErr && err != nil {
-				t.Errorf("Expected no error but got: %v", err)
+			if !tc.wantErr && !isFollowUp {
+				t.Errorf("Expected login follow-up but got: %v. Result: %s", err, result)
 			}
-			if !tc.wantErr && err == nil {
-				// Should contain authentication message since no token set
-				if !strings.Contains(result, "Authentication Required") {
-					t.Errorf("Expected authentication message in result: %s", result)
-				}
+			if isFollowUp && (followUp.Tool != "fitbit_login" || !followUp.RetryOriginal) {
+				t.Errorf("Expected fitbit_login follow-up that retries the meal, got %+v", followUp)
 			}
 		})
 	}
 }
 
+func TestLogMealPreview(t *testing.T) {
+	tool := NewLogMealTool()
+
+	preview, err := tool.Preview(json.RawMessage(`{"meal_type": "lunch", "foods": [{"name": "salad", "quantity": 1, "unit": "bowl", "calories": 200}, {"name": "bread", "quantity": 1, "unit": "slice", "calories": "80"}], "days_count": 3, "start_date": "2025-08-14"}`))
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if preview.MealType != "lunch" || len(preview.Foods) != 2 {
+		t.Errorf("Expected a lunch with 2 foods, got %+v", preview)
+	}
+	if got := strings.Join(preview.Dates, ","); got != "2025-08-14,2025-08-15,2025-08-16" {
+		t.Errorf("Unexpected dates %s", got)
+	}
+	if preview.TotalCalories() != 840 {
+		t.Errorf("Expected 840 calories over 3 days but got %.0f", preview.TotalCalories())
+	}
+
+	if _, err := tool.Preview(json.RawMessage(`{"meal_type": "brunch", "foods": []}`)); err == nil {
+		t.Errorf("Expected invalid input to fail the preview")
+	}
+}
+
 func TestNumberParsing(t *testing.T) {
 	testCases := []struct {
 		input    any
@@ -105,3 +134,64 @@ func TestNumberParsing(t *testing.T) {
 		}
 	}
 }
+
+// fitbitResponses answers each request with the next status, counting the requests
+type fitbitResponses struct {
+	statuses []int
+	requests int
+}
+
+func (f *fitbitResponses) RoundTrip(req *http.Request) (*http.Response, error) {
+	status := f.statuses[min(f.requests, len(f.statuses)-1)]
+	f.requests++
+	return &http.Response{
+		StatusCode: status,
+		Body:       io.NopCloser(strings.NewReader(`{"foodLog": {"logId": 42}}`)),
+		Header:     http.Header{},
+		Request:    req,
+	}, nil
+}
+
+func TestLogMealUnauthorized(t *testing.T) {
+	t.Setenv("FITBIT_ACCESS_TOKEN", "expired")
+	t.Setenv("FITBIT_USER_ID", "user")
+	meal := json.RawMessage(`{"meal_type": "breakfast", "start_date": "2025-08-14", "foods": [
+		{"name": "eggs", "quantity": 2, "unit": "large", "calories": 140},
+		{"name": "toast", "quantity": 2, "unit": "slices", "calories": 160}
+	]}`)
+
+	testCases := []struct {
+		name         string
+		statuses     []int
+		wantFollowUp bool
+		wantErr      string
+	}{
+		{
+			name:         "Expired before anything was logged asks to log in and retry",
+			statuses:     []int{401},
+			wantFollowUp: true,
+		},
+		{
+			name:     "Expired after a food was logged names it instead of retrying",
+			statuses: []int{200, 401},
+			wantErr:  "Already logged, do not log these again: eggs on 2025-08-14",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tool := NewLogMealTool()
+			tool.Transport = &fitbitResponses{statuses: tc.statuses}
+
+			_, err := tool.Execute(context.Background(), meal)
+
+			var followUp *agent.FollowUpError
+			if isFollowUp := errors.As(err, &followUp); isFollowUp != tc.wantFollowUp {
+				t.Fatalf("Expected follow-up %v but got: %v", tc.wantFollowUp, err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Errorf("Expected error containing %q but got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -16,7 +16,10 @@ import (
 )
 
 // LoginTool handles Fitbit OAuth authentication
-type LoginTool struct{}
+type LoginTool struct {
+	// Transport carries the requests to Fitbit, e.g. to record them (optional)
+	Transport http.RoundTripper
+}
 
 // NewLoginTool creates a new Fitbit login tool
 func NewLoginTool() *LoginTool {
@@ -33,6 +36,12 @@ func (t *LoginTool) Description() string {
 	return "Authenticate with Fitbit API to enable meal logging. Guides user through OAuth flow."
 }
 
+// Exclusive reports that the login must not run alongside other tool calls,
+// since it waits for the user to finish the OAuth flow in the browser
+func (t *LoginTool) Exclusive() bool {
+	return true
+}
+
 // InputSchema returns the input schema for the tool
 func (t *LoginTool) InputSchema() map[string]interface{} {
 	return map[string]interface{}{
@@ -71,7 +80,7 @@ func (t *LoginTool) Execute(ctx context.Context, input json.RawMessage) (string,
 	if !loginInput.ForceReauth {
 		if token := os.Getenv("FITBIT_ACCESS_TOKEN"); token != "" {
 			// Validate the token
-			if err := t.validateToken(token); err == nil {
+			if err := t.validateToken(ctx, token); err == nil {
 				return "✅ Already authenticated with Fitbit! You can start logging meals.", nil
 			}
 			// If token is invalid, continue with authentication
@@ -92,7 +101,7 @@ func (t *LoginTool) Execute(ctx context.Context, input json.RawMessage) (string,
 	}
 
 	// Exchange the authorization code for an access token
-	accessToken, err := t.exchangeCodeForToken(cfg, authCode)
+	accessToken, err := t.exchangeCodeForToken(ctx, cfg, authCode)
 	if err != nil {
 		return "", fmt.Errorf("failed to exchange code for token: %w", err)
 	}
@@ -111,15 +120,15 @@ Your authentication will be remembered for future sessions.`, nil
 }
 
 // validateToken checks if the access token is still valid
-func (t *LoginTool) validateToken(token string) error {
-	req, err := http.NewRequest("GET", "https://api.fitbit.com/1/user/-/profile.json", nil)
+func (t *LoginTool) validateToken(ctx context.Context, token string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.fitbit.com/1/user/-/profile.json", nil)
 	if err != nil {
 		return err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+token)
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := &http.Client{Timeout: 10 * time.Second, Transport: t.Transport}
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
@@ -154,7 +163,10 @@ func (t *LoginTool) startOAuthServer(ctx context.Context, cfg *config.Config, au
 			if errMsg == "" {
 				errMsg = "No authorization code received"
 			}
-			errChan <- fmt.Errorf("OAuth error: %s", errMsg)
+			select {
+			case errChan <- fmt.Errorf("OAuth error: %s", errMsg):
+			default:
+			}
 			http.Error(w, "Authorization failed", http.StatusBadRequest)
 			return
 		}
@@ -182,8 +194,11 @@ func (t *LoginTool) startOAuthServer(ctx context.Context, cfg *config.Config, au
 </body>
 </html>`)
 
-		// Send the code
-		codeChan <- code
+		// Send the code, ignoring repeated callbacks
+		select {
+		case codeChan <- code:
+		default:
+		}
 	})
 
 	// Start server
@@ -195,7 +210,10 @@ func (t *LoginTool) startOAuthServer(ctx context.Context, cfg *config.Config, au
 	// Start server in goroutine
 	go func() {
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			errChan <- fmt.Errorf("server error: %w", err)
+			select {
+			case errChan <- fmt.Errorf("server error: %w", err):
+			default:
+			}
 		}
 	}()
 
@@ -203,36 +221,41 @@ func (t *LoginTool) startOAuthServer(ctx context.Context, cfg *config.Config, au
 	time.Sleep(100 * time.Millisecond)
 
 	// Open browser (macOS specific)
-	fmt.Printf("🌐 Opening browser for Fitbit authentication...\n")
+	fmt.Fprintf(os.Stderr, "🌐 Opening browser for Fitbit authentication...\n")
 	if err := exec.Command("open", authURL).Start(); err != nil {
-		fmt.Printf("⚠️  Could not automatically open browser. Please visit: %s\n", authURL)
+		fmt.Fprintf(os.Stderr, "⚠️  Could not automatically open browser. Please visit: %s\n", authURL)
 	}
 
-	fmt.Printf("🔄 Waiting for authorization (server running on %s)...\n", redirectURL.Host)
+	fmt.Fprintf(os.Stderr, "🔄 Waiting for authorization (server running on %s)...\n", redirectURL.Host)
+
+	// The server must stop however the wait ends, including on Ctrl-C
+	defer shutdownServer(server)
 
 	// Wait for either code or error
-	var authCode string
 	select {
 	case code := <-codeChan:
-		authCode = code
+		return code, nil
 	case err := <-errChan:
-		server.Shutdown(ctx)
 		return "", err
 	case <-time.After(5 * time.Minute): // Timeout after 5 minutes
-		server.Shutdown(ctx)
 		return "", fmt.Errorf("authentication timeout - please try again")
 	case <-ctx.Done():
-		server.Shutdown(ctx)
 		return "", ctx.Err()
 	}
+}
 
-	// Shutdown server
-	server.Shutdown(ctx)
-	return authCode, nil
+// shutdownServer stops the callback server, giving in-flight requests a few
+// seconds to finish. It uses its own context as the caller's may be cancelled.
+func shutdownServer(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		server.Close()
+	}
 }
 
 // exchangeCodeForToken exchanges the authorization code for an access token
-func (t *LoginTool) exchangeCodeForToken(cfg *config.Config, authCode string) (string, error) {
+func (t *LoginTool) exchangeCodeForToken(ctx context.Context, cfg *config.Config, authCode string) (string, error) {
 	// Prepare token exchange request
 	data := url.Values{}
 	data.Set("client_id", cfg.FitbitClientID)
@@ -241,7 +264,7 @@ func (t *LoginTool) exchangeCodeForToken(cfg *config.Config, authCode string) (s
 	data.Set("code", authCode)
 
 	// Create request
-	req, err := http.NewRequest("POST", "https://api.fitbit.com/oauth2/token", strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.fitbit.com/oauth2/token", strings.NewReader(data.Encode()))
 	if err != nil {
 		return "", fmt.Errorf("failed to create token request: %w", err)
 	}
@@ -250,7 +273,7 @@ func (t *LoginTool) exchangeCodeForToken(cfg *config.Config, authCode string) (s
 	req.Header.Set("Authorization", "Basic "+t.basicAuth(cfg.FitbitClientID, cfg.FitbitClientSecret))
 
 	// Make request
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Timeout: 30 * time.Second, Transport: t.Transport}
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("token request failed: %w", err)
@@ -0,0 +1,254 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
+)
+
+// maxOutputBytes limits how much of a plugin's output is kept
+const maxOutputBytes = 1 << 20
+
+// validName matches the tool names LLM providers accept
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Manifest describes a plugin. It is stored as <name>.json next to the
+// executable, which is <name> unless the manifest names another command.
+type Manifest struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+	Command     string                 `json:"command,omitempty"`      // executable relative to the plugin directory
+	Timeout     string                 `json:"timeout,omitempty"`      // e.g. "10s", overrides the default
+	SideEffects bool                   `json:"side_effects,omitempty"` // ask before running, like meal logging
+}
+
+// Tool runs an external executable as a tool. The input is written to its
+// stdin as JSON and whatever it prints on stdout is the result; a non-zero
+// exit status is a failure, explained by what it printed on stderr.
+type Tool struct {
+	manifest Manifest
+	path     string
+	timeout  time.Duration
+}
+
+// Load reads the plugins in dir and returns those named in the allowlist. Other
+// plugins are ignored, and plugins that are invalid are reported in the errors.
+// A missing directory means no plugins, and a directory other users can change
+// is not used at all.
+func Load(dir string, allowlist []string, defaultTimeout time.Duration) ([]*Tool, []error) {
+	manifests, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, []error{err}
+	}
+	if len(manifests) == 0 {
+		return nil, nil
+	}
+	if err := checkOwnerOnly(dir); err != nil {
+		return nil, []error{fmt.Errorf("plugin directory: %w", err)}
+	}
+
+	allowed := map[string]bool{}
+	for _, name := range allowlist {
+		allowed[strings.TrimSpace(name)] = true
+	}
+
+	var tools []*Tool
+	var errs []error
+	for _, manifestPath := range manifests {
+		name := strings.TrimSuffix(filepath.Base(manifestPath), ".json")
+		if !allowed[name] {
+			continue
+		}
+
+		tool, err := loadTool(manifestPath, defaultTimeout)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s: %w", name, err))
+			continue
+		}
+		tools = append(tools, tool)
+	}
+	return tools, errs
+}
+
+// loadTool reads and checks a manifest and the executable it describes
+func loadTool(manifestPath string, defaultTimeout time.Duration) (*Tool, error) {
+	if err := checkOwnerOnly(manifestPath); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	fileName := strings.TrimSuffix(filepath.Base(manifestPath), ".json")
+	if manifest.Name != fileName || !validName.MatchString(manifest.Name) {
+		return nil, fmt.Errorf("the manifest name must be %q, using letters, digits, _ and -", fileName)
+	}
+	if manifest.Description == "" {
+		return nil, fmt.Errorf("the manifest needs a description")
+	}
+	if manifest.InputSchema == nil {
+		manifest.InputSchema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+
+	timeout := defaultTimeout
+	if manifest.Timeout != "" {
+		if timeout, err = time.ParseDuration(manifest.Timeout); err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q", manifest.Timeout)
+		}
+	}
+
+	command := manifest.Command
+	if command == "" {
+		command = manifest.Name
+	}
+	path, err := resolveCommand(filepath.Dir(manifestPath), command)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkExecutable(path); err != nil {
+		return nil, err
+	}
+
+	return &Tool{manifest: manifest, path: path, timeout: timeout}, nil
+}
+
+// resolveCommand returns the real path of command, following symlinks, and
+// rejects commands outside dir such as "../../bin/sh"
+func resolveCommand(dir, command string) (string, error) {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the plugin directory: %w", err)
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(dir, command))
+	if err != nil {
+		return "", fmt.Errorf("executable not found: %w", err)
+	}
+	if rel, err := filepath.Rel(realDir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("the command %q is outside the plugin directory", command)
+	}
+	return path, nil
+}
+
+// checkExecutable makes sure path is an executable only its owner can change
+func checkExecutable(path string) error {
+	if err := checkOwnerOnly(path); err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("executable not found: %w", err)
+	}
+	if !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("%s is not executable", path)
+	}
+	return nil
+}
+
+// checkOwnerOnly makes sure only the owner of path can change it, so another
+// user cannot swap in their own plugin
+func checkOwnerOnly(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("%s not found: %w", path, err)
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%s can be modified by other users", path)
+	}
+	return nil
+}
+
+// Name returns the tool name from the manifest
+func (t *Tool) Name() string {
+	return t.manifest.Name
+}
+
+// Description returns the tool description from the manifest
+func (t *Tool) Description() string {
+	return t.manifest.Description
+}
+
+// InputSchema returns the input schema from the manifest
+func (t *Tool) InputSchema() map[string]interface{} {
+	return t.manifest.InputSchema
+}
+
+// SideEffects reports whether the manifest says the plugin changes anything
+func (t *Tool) SideEffects() bool {
+	return t.manifest.SideEffects
+}
+
+// Timeout returns the time the plugin may run, from its manifest or the default
+func (t *Tool) Timeout() time.Duration {
+	return t.timeout
+}
+
+// Preview shows the input the plugin is about to run with
+func (t *Tool) Preview(input json.RawMessage) (*agent.ActionPreview, error) {
+	return &agent.ActionPreview{Action: fmt.Sprintf("Run %s with %s", t.manifest.Name, input)}, nil
+}
+
+// Execute runs the plugin with the input on stdin and returns its output
+func (t *Tool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, t.path)
+	cmd.Dir = filepath.Dir(t.path)
+	cmd.Stdin = bytes.NewReader(input)
+	stdout := &limitedBuffer{limit: maxOutputBytes}
+	stderr := &limitedBuffer{limit: 4096}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("%s timed out after %s", t.manifest.Name, t.timeout)
+	}
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return "", fmt.Errorf("%s failed: %s", t.manifest.Name, message)
+	}
+
+	output := strings.TrimSpace(stdout.String())
+	if stdout.truncated {
+		output += "\n[output truncated]"
+	}
+	return output, nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it and drops the rest
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write implements io.Writer
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room < len(p) {
+		b.truncated = true
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,126 @@
+package plugins_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/tools/plugins"
+)
+
+// writePlugin writes a shell script plugin and its manifest to dir
+func writePlugin(t *testing.T, dir, name, manifest, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name+".json"), []byte(manifest), 0600); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	if script != "" {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0700); err != nil {
+			t.Fatalf("failed to write plugin: %v", err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts in these tests")
+	}
+	dir := t.TempDir()
+
+	writePlugin(t, dir, "echo", `{"name": "echo", "description": "Echoes its input", "input_schema": {"type": "object"}}`, `cat`)
+	writePlugin(t, dir, "slow", `{"name": "slow", "description": "Takes too long", "timeout": "100ms"}`, `sleep 5`)
+	writePlugin(t, dir, "fails", `{"name": "fails", "description": "Always fails", "side_effects": true}`, `echo "no network" >&2; exit 1`)
+	writePlugin(t, dir, "unlisted", `{"name": "unlisted", "description": "Not in the allowlist"}`, `echo hi`)
+	writePlugin(t, dir, "missing", `{"name": "missing", "description": "Has no executable"}`, ``)
+	writePlugin(t, dir, "renamed", `{"name": "other", "description": "Name does not match the file"}`, `echo hi`)
+	writePlugin(t, dir, "shared", `{"name": "shared", "description": "Writable by others"}`, `echo hi`)
+	os.Chmod(filepath.Join(dir, "shared"), 0777)
+	writePlugin(t, dir, "loose", `{"name": "loose", "description": "Manifest writable by others"}`, `echo hi`)
+	os.Chmod(filepath.Join(dir, "loose.json"), 0666)
+
+	// Commands must stay inside the plugin directory, also through symlinks
+	os.WriteFile(filepath.Join(filepath.Dir(dir), "outside"), []byte("#!/bin/sh\necho hi\n"), 0700)
+	writePlugin(t, dir, "escapes", `{"name": "escapes", "description": "Runs a file outside", "command": "../outside"}`, ``)
+	writePlugin(t, dir, "linked", `{"name": "linked", "description": "Links to a shell"}`, ``)
+	os.Symlink("/bin/sh", filepath.Join(dir, "linked"))
+	writePlugin(t, dir, "alias", `{"name": "alias", "description": "Links to another plugin"}`, ``)
+	os.Symlink("echo", filepath.Join(dir, "alias"))
+
+	allowlist := []string{"echo", "slow", "fails", "missing", "renamed", "shared", "loose", "escapes", "linked", "alias"}
+	tools, errs := plugins.Load(dir, allowlist, time.Minute)
+
+	if len(errs) != 6 {
+		t.Errorf("expected the missing, renamed, shared, loose, escapes and linked plugins to fail, got %v", errs)
+	}
+	for _, err := range errs {
+		if strings.Contains(err.Error(), "escapes") || strings.Contains(err.Error(), "linked") {
+			if !strings.Contains(err.Error(), "outside the plugin directory") {
+				t.Errorf("expected the command to be rejected as outside the plugin directory, got %v", err)
+			}
+		}
+	}
+	byName := map[string]*plugins.Tool{}
+	for _, tool := range tools {
+		byName[tool.Name()] = tool
+	}
+	if len(byName) != 4 || byName["echo"] == nil || byName["slow"] == nil || byName["fails"] == nil || byName["alias"] == nil {
+		t.Fatalf("expected the echo, slow, fails and alias plugins, got %v", byName)
+	}
+	if byName["echo"].SideEffects() || !byName["fails"].SideEffects() {
+		t.Error("expected only the fails plugin to have side effects")
+	}
+
+	testCases := []struct {
+		name    string
+		tool    string
+		input   string
+		output  string
+		wantErr string
+	}{
+		{name: "Input is passed on stdin", tool: "echo", input: `{"food": "apple"}`, output: `{"food": "apple"}`},
+		{name: "Symlinks inside the directory are followed", tool: "alias", input: `{"food": "pear"}`, output: `{"food": "pear"}`},
+		{name: "Slow plugins time out", tool: "slow", input: `{}`, wantErr: "timed out after 100ms"},
+		{name: "Failures are explained by stderr", tool: "fails", input: `{}`, wantErr: "fails failed: no network"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, err := byName[tc.tool].Execute(context.Background(), json.RawMessage(tc.input))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil || output != tc.output {
+				t.Errorf("expected %q, got %q (%v)", tc.output, output, err)
+			}
+		})
+	}
+}
+
+func TestLoadWithoutDirectory(t *testing.T) {
+	tools, errs := plugins.Load(filepath.Join(t.TempDir(), "missing"), []string{"echo"}, time.Minute)
+	if len(tools) != 0 || len(errs) != 0 {
+		t.Errorf("expected no plugins and no errors, got %v %v", tools, errs)
+	}
+}
+
+func TestLoadSharedDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts in these tests")
+	}
+	dir := t.TempDir()
+	writePlugin(t, dir, "echo", `{"name": "echo", "description": "Echoes its input"}`, `cat`)
+	os.Chmod(dir, 0777)
+
+	tools, errs := plugins.Load(dir, []string{"echo"}, time.Minute)
+	if len(tools) != 0 || len(errs) != 1 || !strings.Contains(errs[0].Error(), "modified by other users") {
+		t.Errorf("expected the directory to be refused, got %v %v", tools, errs)
+	}
+}
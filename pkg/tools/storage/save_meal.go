@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/vhbfernandes/fitbit-agent/pkg/agent"
 )
 
 // SaveMealTool saves meals to local file storage
@@ -68,6 +70,43 @@ type MealRecord struct {
 	MealData  map[string]interface{} `json:"meal_data"`
 }
 
+// SideEffects reports that this tool writes meal records to disk
+func (t *SaveMealTool) SideEffects() bool {
+	return true
+}
+
+// Preview describes the meal record that would be saved
+func (t *SaveMealTool) Preview(input json.RawMessage) (*agent.ActionPreview, error) {
+	var saveInput SaveMealInput
+	if err := json.Unmarshal(input, &saveInput); err != nil {
+		return nil, fmt.Errorf("failed to parse input: %w", err)
+	}
+
+	date := saveInput.Date
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	}
+
+	preview := &agent.ActionPreview{Action: "Save locally", Dates: []string{date}}
+	preview.MealType, _ = saveInput.MealData["meal_type"].(string)
+
+	// Meal data is free-form, so only foods in the usual shape are listed
+	foods, _ := saveInput.MealData["foods"].([]interface{})
+	for _, item := range foods {
+		food, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := food["name"].(string)
+		unit, _ := food["unit"].(string)
+		quantity, _ := food["quantity"].(float64)
+		calories, _ := food["calories"].(float64)
+		preview.Foods = append(preview.Foods, agent.PreviewFood{Name: name, Quantity: quantity, Unit: unit, Calories: calories})
+	}
+
+	return preview, nil
+}
+
 // Execute saves the meal to local storage
 func (t *SaveMealTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
 	var saveInput SaveMealInput
@@ -110,7 +149,49 @@ func (t *SaveMealTool) Execute(ctx context.Context, input json.RawMessage) (stri
 	if err := os.WriteFile(filepath, data, 0644); err != nil {
 		return "", fmt.Errorf("failed to save meal: %w", err)
 	}
+	agent.RecordUndo(ctx, mealRecordUndo{Date: date, Timestamp: record.Timestamp})
 
 	return fmt.Sprintf("✅ Meal saved locally to %s\n📂 File: %s\n🕒 Total meals today: %d",
 		date, filepath, len(meals)), nil
 }
+
+// mealRecordUndo identifies a meal record saved by this tool
+type mealRecordUndo struct {
+	Date      string    `json:"date"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Undo removes a meal record saved by Execute
+func (t *SaveMealTool) Undo(ctx context.Context, step json.RawMessage) error {
+	var saved mealRecordUndo
+	if err := json.Unmarshal(step, &saved); err != nil {
+		return fmt.Errorf("invalid undo step: %w", err)
+	}
+
+	path := filepath.Join(t.dataDir, fmt.Sprintf("meals_%s.json", saved.Date))
+	existingData, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read meals: %w", err)
+	}
+
+	var meals []MealRecord
+	if err := json.Unmarshal(existingData, &meals); err != nil {
+		return fmt.Errorf("failed to parse meals: %w", err)
+	}
+
+	kept := meals[:0]
+	for _, meal := range meals {
+		if !meal.Timestamp.Equal(saved.Timestamp) {
+			kept = append(kept, meal)
+		}
+	}
+
+	data, err := json.MarshalIndent(kept, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal meal data: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}